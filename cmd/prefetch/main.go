@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gordpool/pkg/planner"
+)
+
+// prefetch warms the SQLite price cache and exits; intended for cron jobs.
+func main() {
+	if err := run(os.Args[1:], os.Getenv); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatal(err)
+	}
+}
+
+// run parses args, with getenv supplying CACHE_DB and AREA for flags not
+// passed, and warms or backfills the cache.
+func run(args []string, getenv func(string) string) error {
+	fs := flag.NewFlagSet("prefetch", flag.ContinueOnError)
+	var (
+		dbPath   = fs.String("db", "data/prices.db", "path to the SQLite price cache")
+		area     = fs.String("area", "LV", "delivery area")
+		market   = fs.String("market", "DayAhead", "market")
+		currency = fs.String("currency", "EUR", "currency")
+		apiBase  = fs.String("api", "https://dataportal-api.nordpoolgroup.com/api/DayAheadPrices", "DayAheadPrices endpoint")
+		timeout  = fs.Duration("timeout", 30*time.Second, "timeout for warming today+tomorrow; a backfill has no overall deadline, each request is bounded on its own")
+		from     = fs.String("from", "", "backfill start date (YYYY-MM-DD); empty warms today+tomorrow only")
+		to       = fs.String("to", "", "backfill end date (YYYY-MM-DD, inclusive); defaults to today")
+		force    = fs.Bool("force", false, "refetch today+tomorrow even if the cache is fresh")
+		retries  = fs.Int("tomorrow-retries", 0, "refetch an empty tomorrow this many times (for runs around publish time)")
+		retryGap = fs.Duration("tomorrow-retry-delay", 5*time.Second, "wait between -tomorrow-retries attempts; added to -timeout per retry")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Env fills in flags that were not passed explicitly (flags > env > defaults).
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if env := getenv("CACHE_DB"); env != "" && !set["db"] {
+		*dbPath = env
+	}
+	if env := getenv("AREA"); env != "" && !set["area"] {
+		*area = env
+	}
+
+	fetchOpts := planner.FetchOptionsFromEnv()
+	fetchOpts.BaseURL = *apiBase
+	fetchOpts.TomorrowRetries = *retries
+	fetchOpts.TomorrowRetryDelay = *retryGap
+	fetchOpts.OnMissingArea = func(day time.Time, stats planner.ParseStats) {
+		if stats.EmptyAreaMaps == stats.Entries {
+			log.Printf("warning: %s: %v", day.Format("2006-01-02"), planner.ErrNoAreaPrices)
+			return
+		}
+		log.Printf("warning: %s: %d of %d entries have no price for %s", day.Format("2006-01-02"), stats.MissingArea, stats.Entries, *area)
+	}
+
+	if *from != "" {
+		start, err := time.Parse("2006-01-02", *from)
+		if err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+		end := time.Now().UTC()
+		if *to != "" {
+			if end, err = time.Parse("2006-01-02", *to); err != nil {
+				return fmt.Errorf("invalid -to: %w", err)
+			}
+		}
+		// Backfill spaces its requests out, so a long range would outlast any
+		// fixed deadline; every upstream request carries its own timeout.
+		n, err := planner.BackfillCacheWithOptions(context.Background(), *dbPath, *area, *market, *currency, start, end, fetchOpts)
+		if err != nil {
+			return fmt.Errorf("backfill %s/%s/%s: %w (stored %d slots before failing)", *area, *market, *currency, err, n)
+		}
+		log.Printf("Backfilled %d slots for %s/%s/%s in %s", n, *area, *market, *currency, *dbPath)
+		return nil
+	}
+
+	// Retries wait between attempts; don't let that eat the fetch budget.
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout+time.Duration(*retries)**retryGap)
+	defer cancel()
+
+	// A warming job must not pass on stale data, so treat a fallback as failure.
+	var staleErr error
+	cacheOpts := planner.CacheOptions{
+		Fetch:   fetchOpts,
+		Force:   *force,
+		OnStale: func(err error) { staleErr = err },
+	}
+	prices, err := planner.FetchNordpoolPricesCachedWithOptions(ctx, *dbPath, *area, *market, *currency, cacheOpts)
+	if err == nil {
+		err = staleErr
+	}
+	if err != nil {
+		return fmt.Errorf("prefetch %s/%s/%s: %w", *area, *market, *currency, err)
+	}
+
+	log.Printf("Cached %d slots for %s/%s/%s in %s", len(prices), *area, *market, *currency, *dbPath)
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gordpool/pkg/planner"
+)
+
+// stubUpstream answers every DayAheadPrices request with three hourly LV
+// slots on the requested date and counts the requests.
+func stubUpstream(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		day, err := time.Parse("2006-01-02", r.URL.Query().Get("date"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var entries []string
+		for h := 0; h < 3; h++ {
+			start := day.Add(time.Duration(h) * time.Hour)
+			entries = append(entries, fmt.Sprintf(`{"deliveryStart": %q, "deliveryEnd": %q, "entryPerArea": {"LV": %d}}`,
+				start.Format(time.RFC3339), start.Add(time.Hour).Format(time.RFC3339), 50+h))
+		}
+		fmt.Fprintf(w, `{"deliveryDateCET": %q, "currency": "EUR", "multiAreaEntries": [%s]}`, day.Format("2006-01-02"), strings.Join(entries, ","))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func cachedSlots(t *testing.T, dbPath string) int {
+	t.Helper()
+	prices, err := planner.FetchNordpoolPricesCachedWithOptions(context.Background(), dbPath, "LV", "DayAhead", "EUR", planner.CacheOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(prices)
+}
+
+func TestRunStoresPrices(t *testing.T) {
+	var requests atomic.Int32
+	srv := stubUpstream(t, &requests)
+	dbPath := filepath.Join(t.TempDir(), "prices.db")
+
+	noEnv := func(string) string { return "" }
+	if err := run([]string{"-db", dbPath, "-api", srv.URL}, noEnv); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("upstream requests = %d, want 2 (today and tomorrow)", requests.Load())
+	}
+	if n := cachedSlots(t, dbPath); n != 6 {
+		t.Errorf("cached %d slots, want 6", n)
+	}
+}
+
+func TestRunEnvPrecedence(t *testing.T) {
+	var requests atomic.Int32
+	srv := stubUpstream(t, &requests)
+	dir := t.TempDir()
+	flagDB, envDB := filepath.Join(dir, "flag.db"), filepath.Join(dir, "env.db")
+	env := func(key string) string {
+		if key == "CACHE_DB" {
+			return envDB
+		}
+		return ""
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		wantDB string
+	}{
+		{"env fills an unset flag", []string{"-api", srv.URL}, envDB},
+		{"explicit flag wins", []string{"-api", srv.URL, "-db", flagDB}, flagDB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := run(tt.args, env); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(tt.wantDB); err != nil {
+				t.Fatalf("cache not written to %s: %v", tt.wantDB, err)
+			}
+		})
+	}
+}
+
+func TestRunBackfillIgnoresTimeout(t *testing.T) {
+	var requests atomic.Int32
+	srv := stubUpstream(t, &requests)
+	dbPath := filepath.Join(t.TempDir(), "prices.db")
+
+	// -timeout bounds warming only; a backfill outlasting it must still finish.
+	args := []string{"-db", dbPath, "-api", srv.URL, "-timeout", "1ns", "-from", "2025-01-14", "-to", "2025-01-15"}
+	if err := run(args, func(string) string { return "" }); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("upstream requests = %d, want one per day", requests.Load())
+	}
+}
+
+func TestRunFailsOnStaleFallback(t *testing.T) {
+	var requests atomic.Int32
+	srv := stubUpstream(t, &requests)
+	dbPath := filepath.Join(t.TempDir(), "prices.db")
+	noEnv := func(string) string { return "" }
+	if err := run([]string{"-db", dbPath, "-api", srv.URL}, noEnv); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stub's three slots a day never count as fresh, so the next run
+	// refreshes, hits the outage and must not report success on old data.
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "maintenance", http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	if err := run([]string{"-db", dbPath, "-api", down.URL}, noEnv); err == nil {
+		t.Fatal("run succeeded while upstream was down")
+	}
+	if n := cachedSlots(t, dbPath); n != 6 {
+		t.Errorf("cached %d slots, want the 6 from the first run", n)
+	}
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
 	"strings"
+	"time"
+
+	"gordpool/internal/proxyutil"
 )
 
 func main() {
+	var (
+		dialTimeout   = flag.Duration("dial-timeout", 5*time.Second, "max time to connect to the upstream (0 disables)")
+		headerTimeout = flag.Duration("response-header-timeout", 15*time.Second, "max time to wait for upstream response headers; 504 after (0 disables)")
+		maxIdleConns  = flag.Int("max-idle-conns", 100, "max idle upstream connections kept for reuse")
+		fixture       = flag.String("fixture", "", "serve this JSON file for every /api/ request instead of proxying (offline UI work)")
+	)
+	flag.Parse()
+
 	target := "https://dataportal-api.nordpoolgroup.com"
 	if v := os.Getenv("TARGET"); v != "" {
 		target = v
@@ -24,7 +36,31 @@ func main() {
 		log.Fatalf("invalid TARGET: %v", err)
 	}
 
+	var fixtureBody []byte
+	if *fixture != "" {
+		fixtureBody, err = os.ReadFile(*fixture)
+		if err != nil {
+			log.Fatalf("read fixture: %v", err)
+		}
+	}
+	handler := newHandler(u, proxyutil.NewUpstreamTransport(*dialTimeout, *headerTimeout, *maxIdleConns), fixtureBody)
+
+	if *fixture != "" {
+		log.Printf("Serving fixture %s for /api/ via %s; upstream is not contacted", *fixture, listen)
+	} else {
+		log.Printf("Proxying %s via %s (prefix /api)", target, listen)
+	}
+	if err := http.ListenAndServe(listen, handler); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newHandler proxies /api/ requests to u over transport, or serves fixture
+// for them when it is non-nil, behind permissive CORS for local development.
+func newHandler(u *url.URL, transport http.RoundTripper, fixture []byte) http.Handler {
 	proxy := httputil.NewSingleHostReverseProxy(u)
+	proxy.Transport = transport
+	proxy.ErrorHandler = proxyutil.ErrorHandler
 	originalDirector := proxy.Director
 	proxy.Director = func(r *http.Request) {
 		originalDirector(r)
@@ -37,7 +73,12 @@ func main() {
 		r.Header.Set("Accept", "application/json")
 	}
 
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var backend http.Handler = proxy
+	if fixture != nil {
+		backend = fixtureHandler(fixture)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// simple CORS for dev
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
@@ -46,13 +87,22 @@ func main() {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		proxy.ServeHTTP(w, r)
+		backend.ServeHTTP(w, r)
 	})
+}
 
-	log.Printf("Proxying %s via %s (prefix /api)", target, listen)
-	if err := http.ListenAndServe(listen, handler); err != nil {
-		log.Fatal(err)
-	}
+// fixtureHandler answers every /api/ request with body as JSON and logs it;
+// other paths are not found.
+func fixtureHandler(body []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("fixture: %s %s", r.Method, r.URL.RequestURI())
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
 }
 
 // singleSlashJoin joins base and path with exactly one slash.
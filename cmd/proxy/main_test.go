@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewHandler(t *testing.T) {
+	const fixture = `{"deliveryDateCET":"2025-01-15","multiAreaEntries":[]}`
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"upstream":true}`)
+	}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		fixture   []byte
+		path      string
+		wantCode  int
+		wantBody  string
+		wantHits  int32
+		wantCType string
+	}{
+		{"fixture api", []byte(fixture), "/api/DayAheadPrices?date=2025-01-15&deliveryArea=LV", http.StatusOK, fixture, 0, "application/json"},
+		{"fixture other path", []byte(fixture), "/index.html", http.StatusNotFound, "", 0, ""},
+		{"proxy", nil, "/api/DayAheadPrices?date=2025-01-15", http.StatusOK, `{"upstream":true}`, 1, "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits.Store(0)
+			srv := httptest.NewServer(newHandler(u, http.DefaultTransport, tt.fixture))
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if tt.wantCType != "" && resp.Header.Get("Content-Type") != tt.wantCType {
+				t.Errorf("Content-Type = %q, want %q", resp.Header.Get("Content-Type"), tt.wantCType)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "*" {
+				t.Errorf("CORS origin = %q, want *", got)
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("upstream contacted %d times, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
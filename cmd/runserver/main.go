@@ -1,28 +1,75 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"log"
 	"net/http"
 	"os"
+	"time"
 )
 
+// config holds the static server settings from flags and the environment.
+type config struct {
+	Port              string
+	WebDir            string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+}
+
+// loadConfig parses args and reads PORT and WEB_DIR through getenv.
+func loadConfig(args []string, getenv func(string) string) (config, error) {
+	var cfg config
+	fs := flag.NewFlagSet("runserver", flag.ContinueOnError)
+	fs.DurationVar(&cfg.ReadHeaderTimeout, "read-header-timeout", 5*time.Second, "max time to read request headers")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", 15*time.Second, "max time to read a whole request")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", 30*time.Second, "max time to write a response")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", 60*time.Second, "max keep-alive idle time")
+	if err := fs.Parse(args); err != nil {
+		return config{}, err
+	}
+
+	cfg.Port = getenv("PORT")
+	if cfg.Port == "" {
+		cfg.Port = "8080"
+	}
+	cfg.WebDir = getenv("WEB_DIR")
+	if cfg.WebDir == "" {
+		cfg.WebDir = "./web"
+	}
+	return cfg, nil
+}
+
+// newServer builds the HTTP server with cfg's port and timeouts.
+func newServer(cfg config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
 // Minimal handler to serve static web assets (built wasm) on Cloud Run.
 // If you want the reverse proxy too, deploy cmd/serve instead.
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	webDir := os.Getenv("WEB_DIR")
-	if webDir == "" {
-		webDir = "./web"
+	cfg, err := loadConfig(os.Args[1:], os.Getenv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatal(err)
 	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/", http.FileServer(http.Dir(webDir)))
+	mux.Handle("/", http.FileServer(http.Dir(cfg.WebDir)))
 
-	log.Printf("Listening on :%s, serving %s", port, webDir)
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
+	log.Printf("Listening on :%s, serving %s", cfg.Port, cfg.WebDir)
+	if err := newServer(cfg, mux).ListenAndServe(); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		wantAddr string
+		want     [4]time.Duration // read header, read, write, idle
+	}{
+		{"defaults", nil, nil, ":8080", [4]time.Duration{5 * time.Second, 15 * time.Second, 30 * time.Second, time.Minute}},
+		{"flags", []string{
+			"-read-header-timeout", "2s", "-read-timeout", "3s",
+			"-write-timeout", "4s", "-idle-timeout", "0",
+		}, map[string]string{"PORT": "9090"}, ":9090", [4]time.Duration{2 * time.Second, 3 * time.Second, 4 * time.Second, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadConfig(tt.args, func(k string) string { return tt.env[k] })
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := http.NotFoundHandler()
+			srv := newServer(cfg, handler)
+			got := [4]time.Duration{srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout}
+			if got != tt.want {
+				t.Errorf("timeouts = %v, want %v", got, tt.want)
+			}
+			if srv.Addr != tt.wantAddr {
+				t.Errorf("addr = %q, want %q", srv.Addr, tt.wantAddr)
+			}
+			if srv.Handler == nil {
+				t.Error("handler not set")
+			}
+		})
+	}
+}
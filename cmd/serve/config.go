@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// config holds the effective server settings.
+//
+// Precedence, highest first: command-line flags > environment variables >
+// the -config JSON file > built-in defaults.
+type config struct {
+	Listen         string   `json:"listen"`          // env LISTEN
+	WebDir         string   `json:"web_dir"`         // env WEB_DIR
+	Target         string   `json:"target"`          // env TARGET
+	APIBase        string   `json:"api_base"`        // env API_BASE
+	Cache          string   `json:"cache"`           // env CACHE_DB
+	CacheTTL       duration `json:"cache_ttl"`       // env CACHE_TTL; Cache-Control max-age for API responses
+	AllowedOrigins []string `json:"allowed_origins"` // env ALLOWED_ORIGINS (comma-separated)
+
+	// Routes maps extra path prefixes to their own upstreams, next to the
+	// default APIBase -> Target route. Env ROUTES takes comma-separated
+	// prefix=upstream pairs; the -route flag may be repeated.
+	Routes map[string]string `json:"routes"`
+
+	// RefreshSecret, when set, must be sent in the X-Refresh-Secret header
+	// to POST /refresh. Env REFRESH_SECRET.
+	RefreshSecret string `json:"refresh_secret"`
+
+	// HTTP server timeouts; 0 disables one. /events clears its write
+	// deadline so streams outlive WriteTimeout.
+	ReadHeaderTimeout duration `json:"read_header_timeout"`
+	ReadTimeout       duration `json:"read_timeout"`
+	WriteTimeout      duration `json:"write_timeout"`
+	IdleTimeout       duration `json:"idle_timeout"`
+
+	// Upstream client limits for the proxied routes; 0 disables a timeout.
+	// An upstream that misses UpstreamHeaderTimeout is answered with 504.
+	UpstreamDialTimeout   duration `json:"upstream_dial_timeout"`
+	UpstreamHeaderTimeout duration `json:"upstream_header_timeout"`
+	UpstreamMaxIdleConns  int      `json:"upstream_max_idle_conns"`
+
+	// AllowUpstreamHeader lets a request pick its upstream with an X-Upstream
+	// header naming the host of one of UpstreamAllowlist (full upstream URLs,
+	// env UPSTREAM_ALLOWLIST comma-separated), e.g. staging vs prod for QA.
+	// Unknown hosts get 400.
+	AllowUpstreamHeader bool     `json:"allow_upstream_header"`
+	UpstreamAllowlist   []string `json:"upstream_allowlist"`
+}
+
+// duration unmarshals from a Go duration string such as "5m".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func defaultConfig() config {
+	return config{
+		Listen:         ":8080",
+		WebDir:         "./web",
+		Target:         "https://dataportal-api.nordpoolgroup.com",
+		APIBase:        "/api/",
+		Cache:          "data/prices.db",
+		AllowedOrigins: []string{"*"},
+
+		ReadHeaderTimeout: duration(5 * time.Second),
+		ReadTimeout:       duration(15 * time.Second),
+		WriteTimeout:      duration(30 * time.Second),
+		IdleTimeout:       duration(60 * time.Second),
+
+		UpstreamDialTimeout:   duration(5 * time.Second),
+		UpstreamHeaderTimeout: duration(15 * time.Second),
+		UpstreamMaxIdleConns:  100,
+	}
+}
+
+// loadConfig resolves the configuration from args, the environment and an
+// optional -config file.
+func loadConfig(args []string, getenv func(string) string) (config, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	var (
+		configPath = fs.String("config", "", "JSON config file (flags > env > file > defaults)")
+		listen     = fs.String("listen", cfg.Listen, "address to listen on")
+		webDir     = fs.String("web", cfg.WebDir, "directory to serve static files from")
+		target     = fs.String("target", cfg.Target, "upstream API base")
+		apiBase    = fs.String("api-base", cfg.APIBase, "API prefix to proxy")
+		cache      = fs.String("cache", cfg.Cache, "SQLite price cache used by /next")
+		cacheTTL   = fs.Duration("cache-ttl", 0, "Cache-Control max-age for API responses (0 disables)")
+		origins    = fs.String("allowed-origins", "*", "comma-separated CORS origins, or *")
+		secret     = fs.String("refresh-secret", "", "shared secret required by POST /refresh (empty allows anyone)")
+		routes     = map[string]string{}
+
+		readHeaderTimeout = fs.Duration("read-header-timeout", time.Duration(cfg.ReadHeaderTimeout), "max time to read request headers")
+		readTimeout       = fs.Duration("read-timeout", time.Duration(cfg.ReadTimeout), "max time to read a whole request")
+		writeTimeout      = fs.Duration("write-timeout", time.Duration(cfg.WriteTimeout), "max time to write a response")
+		idleTimeout       = fs.Duration("idle-timeout", time.Duration(cfg.IdleTimeout), "max keep-alive idle time")
+
+		upstreamDialTimeout   = fs.Duration("upstream-dial-timeout", time.Duration(cfg.UpstreamDialTimeout), "max time to connect to an upstream")
+		upstreamHeaderTimeout = fs.Duration("upstream-header-timeout", time.Duration(cfg.UpstreamHeaderTimeout), "max time to wait for upstream response headers (504 after)")
+		upstreamMaxIdleConns  = fs.Int("upstream-max-idle-conns", cfg.UpstreamMaxIdleConns, "max idle upstream connections kept for reuse")
+
+		allowUpstreamHeader = fs.Bool("allow-upstream-header", false, "honor an X-Upstream header naming an allowlisted upstream host")
+		upstreamAllowlist   = fs.String("upstream-allowlist", "", "comma-separated upstream URLs selectable via X-Upstream")
+	)
+	fs.Func("route", "extra proxy route as prefix=upstream (repeatable)", func(v string) error {
+		return addRoute(routes, v)
+	})
+	if err := fs.Parse(args); err != nil {
+		return config{}, err
+	}
+
+	if *configPath != "" {
+		f, err := os.Open(*configPath)
+		if err != nil {
+			return config{}, fmt.Errorf("open config: %w", err)
+		}
+		defer f.Close()
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil && err != io.EOF {
+			return config{}, fmt.Errorf("parse config %s: %w", *configPath, err)
+		}
+	}
+
+	envString := func(key string, dst *string) {
+		if v := getenv(key); v != "" {
+			*dst = v
+		}
+	}
+	envString("LISTEN", &cfg.Listen)
+	envString("WEB_DIR", &cfg.WebDir)
+	envString("TARGET", &cfg.Target)
+	envString("API_BASE", &cfg.APIBase)
+	envString("CACHE_DB", &cfg.Cache)
+	envString("REFRESH_SECRET", &cfg.RefreshSecret)
+	if v := getenv("CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid CACHE_TTL: %w", err)
+		}
+		cfg.CacheTTL = duration(d)
+	}
+	if v := getenv("UPSTREAM_ALLOWLIST"); v != "" {
+		cfg.UpstreamAllowlist = splitList(v)
+	}
+	if v := getenv("ALLOWED_ORIGINS"); v != "" {
+		cfg.AllowedOrigins = splitList(v)
+	}
+	if v := getenv("ROUTES"); v != "" {
+		cfg.Routes = map[string]string{}
+		for _, pair := range splitList(v) {
+			if err := addRoute(cfg.Routes, pair); err != nil {
+				return config{}, fmt.Errorf("invalid ROUTES: %w", err)
+			}
+		}
+	}
+
+	// Only flags given explicitly override file and env values.
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			cfg.Listen = *listen
+		case "web":
+			cfg.WebDir = *webDir
+		case "target":
+			cfg.Target = *target
+		case "api-base":
+			cfg.APIBase = *apiBase
+		case "cache":
+			cfg.Cache = *cache
+		case "cache-ttl":
+			cfg.CacheTTL = duration(*cacheTTL)
+		case "allowed-origins":
+			cfg.AllowedOrigins = splitList(*origins)
+		case "route":
+			cfg.Routes = routes
+		case "refresh-secret":
+			cfg.RefreshSecret = *secret
+		case "read-header-timeout":
+			cfg.ReadHeaderTimeout = duration(*readHeaderTimeout)
+		case "read-timeout":
+			cfg.ReadTimeout = duration(*readTimeout)
+		case "write-timeout":
+			cfg.WriteTimeout = duration(*writeTimeout)
+		case "idle-timeout":
+			cfg.IdleTimeout = duration(*idleTimeout)
+		case "upstream-dial-timeout":
+			cfg.UpstreamDialTimeout = duration(*upstreamDialTimeout)
+		case "upstream-header-timeout":
+			cfg.UpstreamHeaderTimeout = duration(*upstreamHeaderTimeout)
+		case "upstream-max-idle-conns":
+			cfg.UpstreamMaxIdleConns = *upstreamMaxIdleConns
+		case "allow-upstream-header":
+			cfg.AllowUpstreamHeader = *allowUpstreamHeader
+		case "upstream-allowlist":
+			cfg.UpstreamAllowlist = splitList(*upstreamAllowlist)
+		}
+	})
+	return cfg, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if p := strings.TrimSpace(part); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// newServer builds the HTTP server with cfg's timeouts.
+func newServer(cfg config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.IdleTimeout),
+	}
+}
+
+// addRoute parses a prefix=upstream pair into routes.
+func addRoute(routes map[string]string, pair string) error {
+	prefix, upstream, ok := strings.Cut(pair, "=")
+	prefix, upstream = strings.TrimSpace(prefix), strings.TrimSpace(upstream)
+	if !ok || prefix == "" || upstream == "" {
+		return fmt.Errorf("route %q: want prefix=upstream", pair)
+	}
+	routes[prefix] = upstream
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(t *testing.T, cfg config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg config) {
+				if !reflect.DeepEqual(cfg, defaultConfig()) {
+					t.Errorf("got %+v, want defaults", cfg)
+				}
+			},
+		},
+		{
+			name: "file",
+			args: []string{"-config", "testdata/config.json"},
+			check: func(t *testing.T, cfg config) {
+				want := defaultConfig()
+				want.Listen = ":9000"
+				want.WebDir = "/srv/web"
+				want.Target = "https://file.example"
+				want.APIBase = "/v1/"
+				want.CacheTTL = duration(5 * time.Minute)
+				want.AllowedOrigins = []string{"https://a.example", "https://b.example"}
+				if !reflect.DeepEqual(cfg, want) {
+					t.Errorf("got %+v, want %+v", cfg, want)
+				}
+			},
+		},
+		{
+			name: "env over file",
+			args: []string{"-config", "testdata/config.json"},
+			env:  map[string]string{"LISTEN": ":9100", "CACHE_TTL": "1m"},
+			check: func(t *testing.T, cfg config) {
+				if cfg.Listen != ":9100" || cfg.CacheTTL != duration(time.Minute) {
+					t.Errorf("listen %q ttl %v, want env values", cfg.Listen, time.Duration(cfg.CacheTTL))
+				}
+				if cfg.WebDir != "/srv/web" {
+					t.Errorf("web dir %q, want file value", cfg.WebDir)
+				}
+			},
+		},
+		{
+			name: "flags over env",
+			args: []string{"-config", "testdata/config.json", "-listen", ":9200", "-allowed-origins", "https://c.example"},
+			env:  map[string]string{"LISTEN": ":9100"},
+			check: func(t *testing.T, cfg config) {
+				if cfg.Listen != ":9200" {
+					t.Errorf("listen %q, want flag value", cfg.Listen)
+				}
+				if want := []string{"https://c.example"}; !reflect.DeepEqual(cfg.AllowedOrigins, want) {
+					t.Errorf("origins %v, want %v", cfg.AllowedOrigins, want)
+				}
+				if cfg.Target != "https://file.example" {
+					t.Errorf("target %q, want file value", cfg.Target)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadConfig(tt.args, func(k string) string { return tt.env[k] })
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{"missing file", []string{"-config", "testdata/missing.json"}, nil},
+		{"bad env duration", nil, map[string]string{"CACHE_TTL": "soon"}},
+		{"bad route", []string{"-route", "nohost"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadConfig(tt.args, func(k string) string { return tt.env[k] }); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+}
+
+func TestNewServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want [4]time.Duration // read header, read, write, idle
+	}{
+		{"defaults", nil, [4]time.Duration{5 * time.Second, 15 * time.Second, 30 * time.Second, time.Minute}},
+		{"flags", []string{
+			"-read-header-timeout", "2s", "-read-timeout", "3s",
+			"-write-timeout", "4s", "-idle-timeout", "0",
+		}, [4]time.Duration{2 * time.Second, 3 * time.Second, 4 * time.Second, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadConfig(tt.args, func(string) string { return "" })
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := newServer(cfg, http.NotFoundHandler())
+			got := [4]time.Duration{srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout}
+			if got != tt.want {
+				t.Errorf("timeouts = %v, want %v", got, tt.want)
+			}
+			if srv.Addr != cfg.Listen {
+				t.Errorf("addr = %q, want %q", srv.Addr, cfg.Listen)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httputil"
@@ -9,79 +12,175 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"gordpool/internal/proxyutil"
+	"gordpool/pkg/planner"
 )
 
 // serve combines static file hosting for /web and a /api/* reverse proxy to avoid CORS.
 func main() {
-	var (
-		listen  = flag.String("listen", ":8080", "address to listen on")
-		webDir  = flag.String("web", "./web", "directory to serve static files from")
-		target  = flag.String("target", "https://dataportal-api.nordpoolgroup.com", "upstream API base")
-		apiBase = flag.String("api-base", "/api/", "API prefix to proxy")
-	)
-	flag.Parse()
+	cfg, err := loadConfig(os.Args[1:], os.Getenv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	if err := addRoutes(mux, cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	dayAheadURL := singleSlashJoin(strings.TrimSuffix(cfg.Target, "/"), "/api/DayAheadPrices")
+	fetchOpts := planner.FetchOptionsFromEnv()
+	fetchOpts.BaseURL = dayAheadURL
+	source := func(ctx context.Context, area, market, currency string) ([]planner.PriceSlot, error) {
+		opts := planner.CacheOptions{
+			Fetch: fetchOpts,
+			OnStale: func(err error) {
+				log.Printf("refresh %s failed, serving cached prices: %v", area, err)
+			},
+		}
+		return planner.FetchNordpoolPricesCachedWithOptions(ctx, cfg.Cache, area, market, currency, opts)
+	}
+	refresh := func(ctx context.Context, area, market, currency string) ([]planner.PriceSlot, error) {
+		opts := planner.CacheOptions{Fetch: fetchOpts, Force: true}
+		return planner.FetchNordpoolPricesCachedWithOptions(ctx, cfg.Cache, area, market, currency, opts)
+	}
+	mux.Handle("/next", cors(cfg, nextHandler(source, time.Now)))
+	mux.Handle("/events", cors(cfg, eventsHandler(source, time.Now, eventsInterval)))
+	mux.Handle("/refresh", cors(cfg, refreshHandler(refresh, cfg.RefreshSecret)))
+	mux.Handle("/params/{id}", cors(cfg, paramsHandler(sqliteParamsStore(cfg.Cache))))
+
+	absWeb, err := filepath.Abs(cfg.WebDir)
+	if err != nil {
+		log.Fatalf("resolve web dir: %v", err)
+	}
+	fs := http.FileServer(http.Dir(absWeb))
+	mux.Handle("/", fs)
+
+	log.Printf("Serving static files from %s at %s", absWeb, cfg.Listen)
+	log.Printf("Proxying %s at %s*", cfg.Target, cfg.APIBase)
+	for prefix, upstream := range cfg.Routes {
+		log.Printf("Proxying %s at %s*", upstream, prefix)
+	}
+	if cfg.AllowUpstreamHeader {
+		log.Printf("X-Upstream may select: %s", strings.Join(cfg.UpstreamAllowlist, ", "))
+	}
+	if err := newServer(cfg, mux).ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	if env := os.Getenv("LISTEN"); env != "" {
-		*listen = env
+// addRoutes registers a reverse proxy for the default APIBase -> Target route
+// and for every extra route; the mux dispatches to the longest matching prefix.
+func addRoutes(mux *http.ServeMux, cfg config) error {
+	routes := map[string]string{cfg.APIBase: cfg.Target}
+	for prefix, upstream := range cfg.Routes {
+		routes[prefix] = upstream
 	}
-	if env := os.Getenv("WEB_DIR"); env != "" {
-		*webDir = env
+
+	// alternates are the upstreams selectable per request via X-Upstream.
+	alternates := map[string]*httputil.ReverseProxy{}
+	if cfg.AllowUpstreamHeader {
+		for _, upstream := range cfg.UpstreamAllowlist {
+			u, err := parseUpstream(upstream)
+			if err != nil {
+				return fmt.Errorf("invalid allowlisted upstream: %w", err)
+			}
+			alternates[strings.ToLower(u.Host)] = newProxy(u, cfg)
+		}
 	}
-	if env := os.Getenv("TARGET"); env != "" {
-		*target = env
+
+	for prefix, upstream := range routes {
+		u, err := parseUpstream(upstream)
+		if err != nil {
+			return fmt.Errorf("invalid upstream for %s: %w", prefix, err)
+		}
+		proxy := newProxy(u, cfg)
+		mux.Handle(prefix, cors(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, prefix) {
+				http.NotFound(w, r)
+				return
+			}
+			if host := r.Header.Get("X-Upstream"); host != "" && cfg.AllowUpstreamHeader {
+				alt, ok := alternates[strings.ToLower(host)]
+				if !ok {
+					http.Error(w, fmt.Sprintf("upstream %q not allowed", host), http.StatusBadRequest)
+					return
+				}
+				alt.ServeHTTP(w, r)
+				return
+			}
+			proxy.ServeHTTP(w, r)
+		})))
 	}
+	return nil
+}
 
-	u, err := url.Parse(*target)
+// parseUpstream parses an upstream base URL, which must name a host.
+func parseUpstream(upstream string) (*url.URL, error) {
+	u, err := url.Parse(upstream)
 	if err != nil {
-		log.Fatalf("invalid target: %v", err)
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q has no host", upstream)
 	}
+	return u, nil
+}
 
+// newProxy forwards requests to u with cfg's upstream limits. The default
+// director already keeps the full request path joined with the upstream base
+// path.
+func newProxy(u *url.URL, cfg config) *httputil.ReverseProxy {
 	proxy := httputil.NewSingleHostReverseProxy(u)
+	proxy.Transport = proxyutil.NewUpstreamTransport(time.Duration(cfg.UpstreamDialTimeout), time.Duration(cfg.UpstreamHeaderTimeout), cfg.UpstreamMaxIdleConns)
+	proxy.ErrorHandler = proxyutil.ErrorHandler
 	orig := proxy.Director
 	proxy.Director = func(r *http.Request) {
 		orig(r)
-		// keep the full /api/... path when forwarding, joined with upstream base path
-		r.URL.Path = singleSlashJoin(u.Path, r.URL.Path)
+		r.Header.Del("X-Upstream")
 		r.Host = u.Host
 		if r.Header.Get("User-Agent") == "" {
 			r.Header.Set("User-Agent", "gordpool-proxy/1.0")
 		}
 		r.Header.Set("Accept", "application/json")
 	}
+	return proxy
+}
 
-	mux := http.NewServeMux()
-	mux.Handle(*apiBase, cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.HasPrefix(r.URL.Path, *apiBase) {
-			http.NotFound(w, r)
-			return
+// cors wraps a handler with CORS headers for cfg.AllowedOrigins ("*" allows
+// any origin) and, when cfg.CacheTTL is set, a Cache-Control max-age.
+func cors(cfg config, next http.Handler) http.Handler {
+	anyOrigin := len(cfg.AllowedOrigins) == 0
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			anyOrigin = true
 		}
-		proxy.ServeHTTP(w, r)
-	})))
-
-	absWeb, err := filepath.Abs(*webDir)
-	if err != nil {
-		log.Fatalf("resolve web dir: %v", err)
-	}
-	fs := http.FileServer(http.Dir(absWeb))
-	mux.Handle("/", fs)
-
-	log.Printf("Serving static files from %s at %s", absWeb, *listen)
-	log.Printf("Proxying %s at %s*", *target, *apiBase)
-	if err := http.ListenAndServe(*listen, mux); err != nil {
-		log.Fatal(err)
+		allowed[o] = true
 	}
-}
-
-// cors wraps a handler with permissive CORS (dev only).
-func cors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		if anyOrigin {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); allowed[origin] {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Refresh-Secret, X-Upstream")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
+		if ttl := time.Duration(cfg.CacheTTL); ttl > 0 {
+			w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(ttl/time.Second)))
+		}
 		next.ServeHTTP(w, r)
 	})
 }
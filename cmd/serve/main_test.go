@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// namedUpstream answers every request with its name and the path it saw.
+func namedUpstream(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name+" "+r.URL.Path)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAddRoutes(t *testing.T) {
+	prices := namedUpstream(t, "prices")
+	other := namedUpstream(t, "other")
+
+	cfg := defaultConfig()
+	cfg.Target = prices.URL
+	cfg.Routes = map[string]string{"/other/": other.URL}
+	mux := http.NewServeMux()
+	if err := addRoutes(mux, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/DayAheadPrices", "prices /api/DayAheadPrices"},
+		{"/other/Intraday", "other /other/Intraday"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != http.StatusOK || rec.Body.String() != tt.want {
+				t.Errorf("got %d %q, want 200 %q", rec.Code, rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestUpstreamHeader(t *testing.T) {
+	prod := namedUpstream(t, "prod")
+	staging := namedUpstream(t, "staging")
+	stagingHost := strings.TrimPrefix(staging.URL, "http://")
+
+	tests := []struct {
+		name     string
+		allow    bool
+		header   string
+		wantCode int
+		wantBody string
+	}{
+		{"no header", true, "", http.StatusOK, "prod /api/DayAheadPrices"},
+		{"allowlisted host", true, stagingHost, http.StatusOK, "staging /api/DayAheadPrices"},
+		{"host case ignored", true, strings.ToUpper(stagingHost), http.StatusOK, "staging /api/DayAheadPrices"},
+		{"disallowed host", true, "evil.example:443", http.StatusBadRequest, ""},
+		{"header ignored when disabled", false, stagingHost, http.StatusOK, "prod /api/DayAheadPrices"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Target = prod.URL
+			cfg.AllowUpstreamHeader = tt.allow
+			cfg.UpstreamAllowlist = []string{staging.URL}
+			mux := http.NewServeMux()
+			if err := addRoutes(mux, cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/DayAheadPrices", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Upstream", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
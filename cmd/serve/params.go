@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"gordpool/pkg/planner"
+)
+
+// maxParamsBody bounds PUT /params bodies.
+const maxParamsBody = 64 << 10
+
+// paramsStore persists strategy params by opaque id (normally the SQLite cache).
+type paramsStore struct {
+	load func(ctx context.Context, id string) (planner.BatteryStrategyParams, bool, error)
+	save func(ctx context.Context, id string, params planner.BatteryStrategyParams) error
+}
+
+// sqliteParamsStore stores params in the cache DB at dbPath.
+func sqliteParamsStore(dbPath string) paramsStore {
+	return paramsStore{
+		load: func(ctx context.Context, id string) (planner.BatteryStrategyParams, bool, error) {
+			return planner.LoadParams(ctx, dbPath, id)
+		},
+		save: func(ctx context.Context, id string, params planner.BatteryStrategyParams) error {
+			return planner.SaveParams(ctx, dbPath, id, params)
+		},
+	}
+}
+
+// paramsHandler serves GET and PUT /params/{id}: the BatteryStrategyParams
+// saved under an opaque id. There is no auth beyond knowing the id.
+func paramsHandler(store paramsStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if id == "" || len(id) > 128 {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			params, ok, err := store.load(r.Context(), id)
+			if err != nil {
+				log.Printf("params: load %s: %v", id, err)
+				http.Error(w, "load failed", http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, params)
+		case http.MethodPut:
+			var params planner.BatteryStrategyParams
+			dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxParamsBody))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&params); err != nil {
+				http.Error(w, "invalid params: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := store.save(r.Context(), id, params); err != nil {
+				log.Printf("params: save %s: %v", id, err)
+				http.Error(w, "save failed", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, params)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gordpool/pkg/planner"
+)
+
+// memParamsStore keeps params in a map, or fails every call with err.
+func memParamsStore(err error) paramsStore {
+	var mu sync.Mutex
+	saved := map[string]planner.BatteryStrategyParams{}
+	return paramsStore{
+		load: func(ctx context.Context, id string) (planner.BatteryStrategyParams, bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			p, ok := saved[id]
+			return p, ok, err
+		},
+		save: func(ctx context.Context, id string, params planner.BatteryStrategyParams) error {
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			saved[id] = params
+			return nil
+		},
+	}
+}
+
+func TestParamsRoundTrip(t *testing.T) {
+	stores := []struct {
+		name  string
+		store func(t *testing.T) paramsStore
+	}{
+		{"memory", func(t *testing.T) paramsStore { return memParamsStore(nil) }},
+		{"sqlite", func(t *testing.T) paramsStore {
+			return sqliteParamsStore(filepath.Join(t.TempDir(), "prices.db"))
+		}},
+	}
+	for _, st := range stores {
+		t.Run(st.name, func(t *testing.T) {
+			testParamsRoundTrip(t, st.store(t))
+		})
+	}
+}
+
+func testParamsRoundTrip(t *testing.T, store paramsStore) {
+	mux := http.NewServeMux()
+	mux.Handle("/params/{id}", paramsHandler(store))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/params/alice", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET before PUT = %d, want 404", rec.Code)
+	}
+	if rec := do(http.MethodPut, "/params/alice", `{"Area": "LV", "MaxChargeHours": 3, "Epsilon": 0.5}`); rec.Code != http.StatusOK {
+		t.Fatalf("PUT = %d (%s)", rec.Code, rec.Body)
+	}
+	rec := do(http.MethodGet, "/params/alice", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET = %d (%s)", rec.Code, rec.Body)
+	}
+	var got planner.BatteryStrategyParams
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Area != "LV" || got.MaxChargeHours != 3 || got.Epsilon != 0.5 {
+		t.Errorf("got %+v, want the saved params", got)
+	}
+	if rec := do(http.MethodGet, "/params/bob", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of another id = %d, want 404", rec.Code)
+	}
+}
+
+func TestParamsHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		store    paramsStore
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{"unknown field", memParamsStore(nil), http.MethodPut, "/params/a", `{"Nope": 1}`, http.StatusBadRequest},
+		{"malformed body", memParamsStore(nil), http.MethodPut, "/params/a", `{`, http.StatusBadRequest},
+		{"id too long", memParamsStore(nil), http.MethodGet, "/params/" + strings.Repeat("x", 129), "", http.StatusBadRequest},
+		{"wrong method", memParamsStore(nil), http.MethodDelete, "/params/a", "", http.StatusMethodNotAllowed},
+		{"load failure", memParamsStore(errors.New("disk")), http.MethodGet, "/params/a", "", http.StatusInternalServerError},
+		{"save failure", memParamsStore(errors.New("disk")), http.MethodPut, "/params/a", `{}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.Handle("/params/{id}", paramsHandler(tt.store))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gordpool/pkg/planner"
+)
+
+// priceSource loads prices for an area/market/currency (normally the SQLite cache).
+type priceSource func(ctx context.Context, area, market, currency string) ([]planner.PriceSlot, error)
+
+// paramsFromQuery reads strategy params from the query string; unset values
+// fall back to the area's defaults, as in the TUI and browser build.
+func paramsFromQuery(q url.Values) (planner.BatteryStrategyParams, error) {
+	str := func(key, def string) string {
+		if v := q.Get(key); v != "" {
+			return v
+		}
+		return def
+	}
+	var firstErr error
+	num := func(key string, def float64) float64 {
+		v := q.Get(key)
+		if v == "" {
+			return def
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("invalid %s: %q", key, v)
+		}
+		return f
+	}
+	flag := func(key string) bool {
+		v := q.Get(key)
+		if v == "" {
+			return false
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("invalid %s: %q", key, v)
+		}
+		return b
+	}
+
+	defaults := planner.DefaultParamsForArea(str("area", "LV"))
+	params := planner.BatteryStrategyParams{
+		Area:              defaults.Area,
+		Market:            str("market", defaults.Market),
+		Currency:          str("currency", defaults.Currency),
+		MaxChargeHours:    num("maxChargeHours", defaults.MaxChargeHours),
+		MaxDischargeHours: num("maxDischargeHours", defaults.MaxDischargeHours),
+		LastPriceCharged:  num("lastPriceCharged", defaults.LastPriceCharged),
+		Epsilon:           num("epsilon", defaults.Epsilon),
+		TomorrowOnly:      flag("tomorrowOnly"),
+	}
+	return params, firstErr
+}
+
+// nextHandler serves GET /next: the upcoming charge/discharge action of a
+// fresh plan, or {"action":"idle"}.
+func nextHandler(source priceSource, now func() time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		params, err := paramsFromQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		prices, err := source(r.Context(), params.Area, params.Market, params.Currency)
+		if err != nil {
+			log.Printf("next: fetch %s: %v", params.Area, err)
+			http.Error(w, "price fetch failed", http.StatusBadGateway)
+			return
+		}
+
+		t := now().UTC()
+		schedule := planner.BuildBatterySchedule(prices, params, t)
+		writeJSON(w, planner.NextAction(schedule, t))
+	}
+}
+
+// refreshHandler serves POST /refresh: refetch the area's prices ignoring
+// freshness and report how many slots are cached. When secret is set the
+// request must carry it in X-Refresh-Secret.
+func refreshHandler(refresh priceSource, secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Refresh-Secret")), []byte(secret)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		params, err := paramsFromQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		prices, err := refresh(r.Context(), params.Area, params.Market, params.Currency)
+		if err != nil {
+			log.Printf("refresh: fetch %s: %v", params.Area, err)
+			http.Error(w, "price fetch failed", http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, map[string]any{"area": params.Area, "slots": len(prices)})
+	}
+}
+
+// eventsInterval is how often /events replans and pushes a changed schedule.
+const eventsInterval = time.Minute
+
+// eventsHandler serves GET /events: a Server-Sent Events stream carrying the
+// ScheduleJSON on connect and again whenever a replan differs from the last
+// event. Each connection ends when the client disconnects.
+func eventsHandler(source priceSource, now func() time.Time, interval time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		params, err := paramsFromQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Streams are long-lived; lift the server's write deadline.
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			log.Printf("events: clear write deadline: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		ctx := r.Context()
+		var last []byte
+		push := func() error {
+			prices, err := source(ctx, params.Area, params.Market, params.Currency)
+			if err != nil {
+				log.Printf("events: fetch %s: %v", params.Area, err)
+				return nil // keep the stream open and retry on the next tick
+			}
+			data, err := json.Marshal(planner.BuildBatterySchedule(prices, params, now().UTC()))
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(data, last) {
+				return nil
+			}
+			last = data
+			if _, err := fmt.Fprintf(w, "event: schedule\ndata: %s\n\n", data); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		}
+
+		if err := push(); err != nil {
+			return
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := push(); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write json: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gordpool/pkg/planner"
+)
+
+var testDay = time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+// seededSource serves hourly prices from testDay, or err when set.
+func seededSource(err error, prices ...float64) priceSource {
+	return func(ctx context.Context, area, market, currency string) ([]planner.PriceSlot, error) {
+		if err != nil {
+			return nil, err
+		}
+		out := make([]planner.PriceSlot, len(prices))
+		for i, p := range prices {
+			out[i] = planner.PriceSlot{Timestamp: testDay.Add(time.Duration(i) * time.Hour), Price: p}
+		}
+		return out, nil
+	}
+}
+
+func TestNextHandler(t *testing.T) {
+	const query = "/next?area=LV&maxChargeHours=1&maxDischargeHours=1&lastPriceCharged=10&epsilon=1"
+	now := func() time.Time { return testDay.Add(30 * time.Minute) }
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		source   priceSource
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "seeded plan",
+			method:   http.MethodGet,
+			url:      query,
+			source:   seededSource(nil, 10, 10, 1, 10, 30),
+			wantCode: http.StatusOK,
+			wantBody: `{"action":"charge","at":"2025-01-15T02:00:00Z","price":1,"in_seconds":5400}`,
+		},
+		{
+			// Discharge starts at 8c whatever lastPriceCharged+epsilon says.
+			name:     "nothing planned",
+			method:   http.MethodGet,
+			url:      "/next?area=LV&lastPriceCharged=7&epsilon=1",
+			source:   seededSource(nil, 7, 7, 7),
+			wantCode: http.StatusOK,
+			wantBody: `{"action":"idle"}`,
+		},
+		{
+			name:     "bad param",
+			method:   http.MethodGet,
+			url:      "/next?epsilon=lots",
+			source:   seededSource(nil, 10),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "upstream failure",
+			method:   http.MethodGet,
+			url:      query,
+			source:   seededSource(errors.New("down")),
+			wantCode: http.StatusBadGateway,
+		},
+		{
+			name:     "wrong method",
+			method:   http.MethodPost,
+			url:      query,
+			source:   seededSource(nil, 10),
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			nextHandler(tt.source, now).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.url, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantBody == "" {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.wantBody {
+				t.Errorf("body = %s, want %s", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEventsHandler(t *testing.T) {
+	now := func() time.Time { return testDay.Add(30 * time.Minute) }
+	srv := httptest.NewServer(eventsHandler(seededSource(nil, 10, 10, 1, 10, 30), now, time.Hour))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events?area=LV&maxChargeHours=1&maxDischargeHours=1&lastPriceCharged=10&epsilon=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var event, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break // end of the first event
+		}
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			event = v
+		}
+		if v, ok := strings.CutPrefix(line, "data: "); ok {
+			data = v
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if event != "schedule" {
+		t.Errorf("event = %q, want schedule", event)
+	}
+	var schedule planner.ScheduleJSON
+	if err := json.Unmarshal([]byte(data), &schedule); err != nil {
+		t.Fatalf("data %q: %v", data, err)
+	}
+	if len(schedule.ChargeSlots) != 1 || schedule.ChargeSlots[0].Timestamp != "2025-01-15T02:00:00Z" {
+		t.Errorf("charge slots = %+v, want 02:00", schedule.ChargeSlots)
+	}
+}
+
+func TestRefreshHandler(t *testing.T) {
+	const secret = "s3cret"
+	tests := []struct {
+		name     string
+		method   string
+		header   string
+		source   priceSource
+		wantCode int
+		wantBody string
+	}{
+		{"refreshed", http.MethodPost, secret, seededSource(nil, 1, 2, 3), http.StatusOK, `{"area":"LV","slots":3}`},
+		{"missing secret", http.MethodPost, "", seededSource(nil, 1), http.StatusForbidden, ""},
+		{"wrong secret", http.MethodPost, "guess", seededSource(nil, 1), http.StatusForbidden, ""},
+		{"upstream failure", http.MethodPost, secret, seededSource(errors.New("down")), http.StatusBadGateway, ""},
+		{"wrong method", http.MethodGet, secret, seededSource(nil, 1), http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/refresh?area=LV", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Refresh-Secret", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			refreshHandler(tt.source, secret).ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); tt.wantBody != "" && got != tt.wantBody {
+				t.Errorf("body = %s, want %s", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRefreshReplacesFreshCache(t *testing.T) {
+	// upstream serves 24 hourly LV slots a day at the current price (EUR/MWh).
+	var price atomic.Int32
+	price.Store(50)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		day, err := time.Parse("2006-01-02", r.URL.Query().Get("date"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var entries []string
+		for h := 0; h < 24; h++ {
+			start := day.Add(time.Duration(h) * time.Hour)
+			entries = append(entries, fmt.Sprintf(`{"deliveryStart": %q, "deliveryEnd": %q, "entryPerArea": {"LV": %d}}`,
+				start.Format(time.RFC3339), start.Add(time.Hour).Format(time.RFC3339), price.Load()))
+		}
+		fmt.Fprintf(w, `{"deliveryDateCET": %q, "currency": "EUR", "multiAreaEntries": [%s]}`, day.Format("2006-01-02"), strings.Join(entries, ","))
+	}))
+	defer upstream.Close()
+
+	dbPath := filepath.Join(t.TempDir(), "prices.db")
+	fetch := planner.FetchOptions{BaseURL: upstream.URL, Anchor: testDay.Add(13 * time.Hour)}
+	cached := func(force bool) priceSource {
+		return func(ctx context.Context, area, market, currency string) ([]planner.PriceSlot, error) {
+			opts := planner.CacheOptions{Fetch: fetch, Force: force}
+			return planner.FetchNordpoolPricesCachedWithOptions(ctx, dbPath, area, market, currency, opts)
+		}
+	}
+	firstPrice := func() float64 {
+		t.Helper()
+		prices, err := cached(false)(context.Background(), "LV", "DayAhead", "EUR")
+		if err != nil || len(prices) == 0 {
+			t.Fatalf("read cache: %d slots, %v", len(prices), err)
+		}
+		return prices[0].Price
+	}
+
+	if got := firstPrice(); got != 5 {
+		t.Fatalf("warm price = %v, want 5", got)
+	}
+	// A correction upstream is not picked up while the cache is fresh.
+	price.Store(90)
+	if got := firstPrice(); got != 5 {
+		t.Fatalf("fresh cache price = %v, want 5", got)
+	}
+
+	rec := httptest.NewRecorder()
+	refreshHandler(cached(true), "").ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/refresh?area=LV", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh status = %d (%s)", rec.Code, rec.Body)
+	}
+	if got := firstPrice(); got != 9 {
+		t.Errorf("price after refresh = %v, want 9", got)
+	}
+}
@@ -0,0 +1,44 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"gordpool/pkg/planner"
+)
+
+// localStorage stores prices as JSON in window.localStorage. Storage errors
+// (quota, disabled storage) are ignored; the cache is best-effort.
+type localStorage struct{}
+
+func (localStorage) load(key string) ([]planner.PriceSlot, bool) {
+	ls := js.Global().Get("localStorage")
+	if ls.Type() != js.TypeObject {
+		return nil, false
+	}
+	v := ls.Call("getItem", key)
+	if v.Type() != js.TypeString {
+		return nil, false
+	}
+	var prices []planner.PriceSlot
+	if err := json.Unmarshal([]byte(v.String()), &prices); err != nil {
+		return nil, false
+	}
+	return prices, true
+}
+
+func (localStorage) save(key string, prices []planner.PriceSlot) {
+	ls := js.Global().Get("localStorage")
+	if ls.Type() != js.TypeObject {
+		return
+	}
+	b, err := json.Marshal(prices)
+	if err != nil {
+		return
+	}
+	defer func() { recover() }() // setItem throws when the quota is exceeded
+	ls.Call("setItem", key, string(b))
+}
@@ -47,17 +47,20 @@ func planPromise(this js.Value, args []js.Value) any {
 		return f
 	}
 
+	// unset fields fall back to the area's defaults
+	defaults := planner.DefaultParamsForArea(toString("area", "LV"))
 	params := planner.BatteryStrategyParams{
-		Area:              toString("area", "LV"),
-		Market:            toString("market", "DayAhead"),
-		Currency:          toString("currency", "EUR"),
-		MaxChargeHours:    toFloat("maxChargeHours", 3),
-		MaxDischargeHours: toFloat("maxDischargeHours", 3),
-		LastPriceCharged:  toFloat("lastPriceCharged", 15),
-		Epsilon:           toFloat("epsilon", 2),
+		Area:              defaults.Area,
+		Market:            toString("market", defaults.Market),
+		Currency:          toString("currency", defaults.Currency),
+		MaxChargeHours:    toFloat("maxChargeHours", defaults.MaxChargeHours),
+		MaxDischargeHours: toFloat("maxDischargeHours", defaults.MaxDischargeHours),
+		LastPriceCharged:  toFloat("lastPriceCharged", defaults.LastPriceCharged),
+		Epsilon:           toFloat("epsilon", defaults.Epsilon),
 	}
 
 	baseURL := toString("baseURL", "https://dataportal-api.nordpoolgroup.com/api/DayAheadPrices")
+	policy := toString("cachePolicy", policyNetwork)
 
 	promiseBody := js.FuncOf(func(_ js.Value, innerArgs []js.Value) any {
 		resolve := innerArgs[0]
@@ -67,12 +70,15 @@ func planPromise(this js.Value, args []js.Value) any {
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
 
-			prices, err := planner.FetchNordpoolPricesWithBase(ctx, baseURL, params.Area, params.Market, params.Currency)
+			now := time.Now().UTC()
+			key := cacheKey(params.Area, params.Market, params.Currency)
+			prices, err := pricesWithPolicy(ctx, policy, localStorage{}, key, now, func(ctx context.Context) ([]planner.PriceSlot, error) {
+				return planner.FetchNordpoolPricesWithBase(ctx, baseURL, params.Area, params.Market, params.Currency)
+			})
 			if err != nil {
 				reject.Invoke(err.Error())
 				return
 			}
-			now := time.Now().UTC()
 			schedule := planner.BuildBatterySchedule(prices, params, now)
 			chart := textchart.Build(prices, schedule, now, textchart.FilterAll, textchart.Options{Colorize: true})
 
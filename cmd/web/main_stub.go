@@ -0,0 +1,16 @@
+//go:build !(js && wasm)
+// +build !js !wasm
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// main exists so the package builds on the host, where the cache policy in
+// policy.go is tested; the app itself only runs as WebAssembly.
+func main() {
+	fmt.Fprintln(os.Stderr, "cmd/web runs in the browser; build it with GOOS=js GOARCH=wasm")
+	os.Exit(2)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gordpool/pkg/planner"
+)
+
+// Cache policies accepted as the plan() param cachePolicy, after fetch's
+// cache modes.
+const (
+	policyNetwork    = "network"     // always fetch; store the result
+	policyCacheFirst = "cache-first" // use stored prices if still useful, else fetch
+	policyCacheOnly  = "cache-only"  // never fetch; fail when nothing is stored
+)
+
+// priceStore persists fetched prices between page loads.
+type priceStore interface {
+	load(key string) ([]planner.PriceSlot, bool)
+	save(key string, prices []planner.PriceSlot)
+}
+
+func cacheKey(area, market, currency string) string {
+	return fmt.Sprintf("gordpool:prices:%s|%s|%s", area, market, currency)
+}
+
+// pricesWithPolicy resolves prices for key under policy, reading and updating
+// store around fetch. Stored prices count only while they still have a slot
+// at or after now.
+func pricesWithPolicy(ctx context.Context, policy string, store priceStore, key string, now time.Time, fetch func(context.Context) ([]planner.PriceSlot, error)) ([]planner.PriceSlot, error) {
+	stored, ok := store.load(key)
+	ok = ok && hasFuture(stored, now)
+
+	switch policy {
+	case "", policyNetwork:
+	case policyCacheFirst:
+		if ok {
+			return stored, nil
+		}
+	case policyCacheOnly:
+		if !ok {
+			return nil, errors.New("no cached prices stored (cachePolicy cache-only)")
+		}
+		return stored, nil
+	default:
+		return nil, fmt.Errorf("unknown cachePolicy %q (want %s, %s or %s)", policy, policyNetwork, policyCacheFirst, policyCacheOnly)
+	}
+
+	prices, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(prices) > 0 {
+		store.save(key, prices)
+	}
+	return prices, nil
+}
+
+func hasFuture(prices []planner.PriceSlot, now time.Time) bool {
+	for _, p := range prices {
+		if !p.Timestamp.Before(now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gordpool/pkg/planner"
+)
+
+// memStore is an in-memory priceStore.
+type memStore map[string][]planner.PriceSlot
+
+func (m memStore) load(key string) ([]planner.PriceSlot, bool) {
+	p, ok := m[key]
+	return p, ok
+}
+
+func (m memStore) save(key string, prices []planner.PriceSlot) { m[key] = prices }
+
+func TestPricesWithPolicy(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	stale := []planner.PriceSlot{{Timestamp: now.Add(-time.Hour), Price: 1}}
+	fresh := []planner.PriceSlot{{Timestamp: now.Add(time.Hour), Price: 2}}
+	fetched := []planner.PriceSlot{{Timestamp: now.Add(time.Hour), Price: 3}}
+	fetchErr := errors.New("offline")
+
+	tests := []struct {
+		name      string
+		policy    string
+		stored    []planner.PriceSlot
+		fetchFail bool
+		want      float64 // price of the first returned slot
+		wantErr   bool
+		wantFetch bool
+	}{
+		{name: "network fetches", policy: policyNetwork, stored: fresh, want: 3, wantFetch: true},
+		{name: "default is network", policy: "", stored: fresh, want: 3, wantFetch: true},
+		{name: "network error", policy: policyNetwork, fetchFail: true, wantErr: true, wantFetch: true},
+		{name: "cache-first hit", policy: policyCacheFirst, stored: fresh, want: 2},
+		{name: "cache-first stale", policy: policyCacheFirst, stored: stale, want: 3, wantFetch: true},
+		{name: "cache-first empty", policy: policyCacheFirst, want: 3, wantFetch: true},
+		{name: "cache-only hit", policy: policyCacheOnly, stored: fresh, want: 2},
+		{name: "cache-only stale", policy: policyCacheOnly, stored: stale, wantErr: true},
+		{name: "unknown policy", policy: "sometimes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memStore{}
+			if tt.stored != nil {
+				store["k"] = tt.stored
+			}
+			fetchedOnce := false
+			got, err := pricesWithPolicy(context.Background(), tt.policy, store, "k", now, func(context.Context) ([]planner.PriceSlot, error) {
+				fetchedOnce = true
+				if tt.fetchFail {
+					return nil, fetchErr
+				}
+				return fetched, nil
+			})
+			if fetchedOnce != tt.wantFetch {
+				t.Errorf("fetched = %t, want %t", fetchedOnce, tt.wantFetch)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("want error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) == 0 || got[0].Price != tt.want {
+				t.Fatalf("got %v, want first price %v", got, tt.want)
+			}
+			if tt.wantFetch && store["k"][0].Price != 3 {
+				t.Errorf("fetched prices were not stored")
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+// Package proxyutil holds the upstream plumbing shared by the reverse
+// proxies in cmd/proxy and cmd/serve.
+package proxyutil
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// NewUpstreamTransport is the proxies' client transport; a zero timeout
+// disables it.
+func NewUpstreamTransport(dialTimeout, headerTimeout time.Duration, maxIdleConns int) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	t.ResponseHeaderTimeout = headerTimeout
+	t.MaxIdleConns = maxIdleConns
+	t.MaxIdleConnsPerHost = maxIdleConns
+	return t
+}
+
+// ErrorHandler is a httputil.ReverseProxy ErrorHandler that answers upstream
+// timeouts with 504 and other failures with 502.
+func ErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("proxy %s: %v", r.URL.Path, err)
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
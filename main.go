@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -16,23 +21,47 @@ import (
 
 // ---------- TUI ----------
 
+// Form field labels; fields are looked up by label.
+const (
+	fieldArea          = "Area"
+	fieldOtherArea     = "Other area"
+	fieldMarket        = "Market"
+	fieldOtherMarket   = "Other market"
+	fieldCurrency      = "Currency"
+	fieldOtherCurrency = "Other currency"
+	fieldMaxCharge     = "Max charge hours"
+	fieldMaxDischarge  = "Max discharge hours"
+	fieldPriceUnit     = "Price unit"
+	fieldLastPrice     = "Last price charged"
+	fieldEpsilon       = "Epsilon"
+	fieldFillGaps      = "Fill small gaps"
+
+	// otherOption ends each picker; choosing it reads the free-text field
+	// below the picker instead.
+	otherOption = "Other…"
+)
+
+// Units accepted for the price fields; the planner always works in c/kWh.
+const (
+	unitCentsPerKWh = "c/kWh"
+	unitEURPerMWh   = "EUR/MWh"
+)
+
+var priceUnits = []string{unitCentsPerKWh, unitEURPerMWh}
+
+// maxFillGapSlots is the longest hole "Fill small gaps" interpolates over.
+const maxFillGapSlots = 2
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	app := tview.NewApplication()
 
 	const cachePath = "data/prices.db"
-
-	counter := 0
-	counterView := tview.NewTextView().
-		SetDynamicColors(true).
-		SetWrap(true).
-		SetChangedFunc(func() {
-			app.Draw()
-		})
-	counterView.SetBorder(true).SetTitle("Counter (demo)")
-	updateCounter := func() {
-		counterView.SetText(fmt.Sprintf("Value: [yellow]%d[-:-:-]\nHotkeys: + / - / 0 (reset)", counter))
-	}
-	updateCounter()
+	const exportDir = "exports"
+	const formWidth = 48
 
 	output := tview.NewTextView().
 		SetDynamicColors(true).
@@ -41,52 +70,169 @@ func main() {
 		SetChangedFunc(func() {
 			app.Draw()
 		})
-	output.SetBorder(true).SetTitle("Schedule chart (A/C/D to filter)")
+	output.SetBorder(true).SetTitle("Schedule chart (A/C/D to filter, P for params)")
 
+	defaults := planner.DefaultParamsForArea("LV")
+	formatFloat := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
 	form := tview.NewForm().
-		AddInputField("Area", "LV", 4, nil, nil).
-		AddInputField("Market", "DayAhead", 10, nil, nil).
-		AddInputField("Currency", "EUR", 4, nil, nil).
-		// values below — in HOURS and CENTS/kWh:
-		AddInputField("Max charge hours", "3", 5, nil, nil).
-		AddInputField("Max discharge hours", "3", 5, nil, nil).
-		AddInputField("Last price charged (c/kWh)", "15", 10, nil, nil).
-		AddInputField("Epsilon (c/kWh)", "2", 10, nil, nil)
+		AddDropDown(fieldArea, withOther(planner.KnownAreas), indexOf(planner.KnownAreas, defaults.Area), nil).
+		AddInputField(fieldOtherArea, "", 6, nil, nil).
+		AddDropDown(fieldMarket, withOther(planner.SupportedMarkets()), indexOf(planner.SupportedMarkets(), defaults.Market), nil).
+		AddInputField(fieldOtherMarket, "", 10, nil, nil).
+		AddDropDown(fieldCurrency, withOther(planner.SupportedCurrencies(defaults.Area)), indexOf(planner.SupportedCurrencies(defaults.Area), defaults.Currency), nil).
+		AddInputField(fieldOtherCurrency, "", 4, nil, nil).
+		// values below — in HOURS, then prices in the chosen unit:
+		AddInputField(fieldMaxCharge, formatFloat(defaults.MaxChargeHours), 5, nil, nil).
+		AddInputField(fieldMaxDischarge, formatFloat(defaults.MaxDischargeHours), 5, nil, nil).
+		AddDropDown(fieldPriceUnit, priceUnits, 0, nil).
+		AddInputField(fieldLastPrice, formatFloat(defaults.LastPriceCharged), 10, nil, nil).
+		AddInputField(fieldEpsilon, formatFloat(defaults.Epsilon), 10, nil, nil).
+		AddCheckbox(fieldFillGaps, false, nil)
 
 	form.SetBorder(true).SetTitle("Params (TAB to move, ENTER to edit)")
 
-	getFieldText := func(idx int) string {
-		item := form.GetFormItem(idx)
-		if input, ok := item.(*tview.InputField); ok {
-			return input.GetText()
+	getFieldText := func(label string) string {
+		switch item := form.GetFormItemByLabel(label).(type) {
+		case *tview.InputField:
+			return item.GetText()
+		case *tview.DropDown:
+			_, opt := item.GetCurrentOption()
+			return opt
 		}
 		return ""
 	}
+	setFieldText := func(label, text string) {
+		if input, ok := form.GetFormItemByLabel(label).(*tview.InputField); ok {
+			input.SetText(text)
+		}
+	}
+	// selected returns the choice of the dropdown labelled label, or the
+	// trimmed text of otherLabel when "Other…" is picked.
+	selected := func(label, otherLabel string) string {
+		dd, ok := form.GetFormItemByLabel(label).(*tview.DropDown)
+		if !ok {
+			return ""
+		}
+		_, opt := dd.GetCurrentOption()
+		if opt == otherOption {
+			return strings.TrimSpace(getFieldText(otherLabel))
+		}
+		return opt
+	}
+
+	// switching the price unit converts the entered prices so they keep
+	// their meaning
+	unit := unitCentsPerKWh
+	if dd, ok := form.GetFormItemByLabel(fieldPriceUnit).(*tview.DropDown); ok {
+		dd.SetSelectedFunc(func(text string, _ int) {
+			if text == unit {
+				return
+			}
+			for _, label := range []string{fieldLastPrice, fieldEpsilon} {
+				if v, err := strconv.ParseFloat(getFieldText(label), 64); err == nil {
+					v = fromCentsPerKWh(toCentsPerKWh(v, unit), text)
+					setFieldText(label, strconv.FormatFloat(v, 'f', -1, 64))
+				}
+			}
+			unit = text
+		})
+	}
+
+	// picking an area offers its currencies and prefills its default params
+	if dd, ok := form.GetFormItemByLabel(fieldArea).(*tview.DropDown); ok {
+		dd.SetSelectedFunc(func(text string, _ int) {
+			if text == otherOption {
+				return
+			}
+			d := planner.DefaultParamsForArea(text)
+			if cur, ok := form.GetFormItemByLabel(fieldCurrency).(*tview.DropDown); ok {
+				currencies := planner.SupportedCurrencies(text)
+				cur.SetOptions(withOther(currencies), nil).SetCurrentOption(indexOf(currencies, d.Currency))
+			}
+			setFieldText(fieldMaxCharge, formatFloat(d.MaxChargeHours))
+			setFieldText(fieldMaxDischarge, formatFloat(d.MaxDischargeHours))
+			setFieldText(fieldLastPrice, formatFloat(fromCentsPerKWh(d.LastPriceCharged, unit)))
+			setFieldText(fieldEpsilon, formatFloat(fromCentsPerKWh(d.Epsilon, unit)))
+		})
+	}
+
+	status := tview.NewTextView().SetDynamicColors(true)
 
 	// state for hotkeys
 	var lastPrices []planner.PriceSlot
+	var lastHistory []planner.PriceSlot
+	var lastParams *planner.BatteryStrategyParams
 	var lastSchedule *planner.ScheduleJSON
+	var note string // one-shot message shown above the next chart render
 	filterMode := textchart.FilterAll
 
+	updateStatus := func() {
+		if lastParams == nil {
+			status.SetText(" Fetch & Plan to start tuning")
+			return
+		}
+		horizon := "all"
+		if lastParams.TomorrowOnly {
+			horizon = "tomorrow"
+		}
+		status.SetText(fmt.Sprintf(" Epsilon: [yellow]%.2f[-:-:-] %s ([ / ])   Last price: [yellow]%.2f[-:-:-] %s ({ / })   Horizon: [yellow]%s[-:-:-] (T)",
+			fromCentsPerKWh(lastParams.Epsilon, unit), unit, fromCentsPerKWh(lastParams.LastPriceCharged, unit), unit, horizon))
+	}
+	updateStatus()
+
 	renderIfReady := func() {
 		if lastSchedule == nil || len(lastPrices) == 0 {
 			return
 		}
 		now := time.Now().UTC()
 		output.Clear()
-		chart := textchart.Build(lastPrices, *lastSchedule, now, filterMode, textchart.Options{Colorize: true})
+		if note != "" {
+			fmt.Fprintf(output, "%s\n\n", note)
+			note = ""
+		}
+		chart := textchart.Build(lastPrices, *lastSchedule, now, filterMode, textchart.Options{Colorize: true, History: lastHistory, TomorrowOnly: lastParams.TomorrowOnly})
 		fmt.Fprint(output, chart)
 	}
 
+	// tune re-plans the fetched prices with adjusted thresholds; no refetch.
+	const tuneStep = 0.5 // c/kWh
+	tune := func(dEpsilon, dLastPrice float64) {
+		if lastParams == nil || len(lastPrices) == 0 {
+			return
+		}
+		lastParams.Epsilon = max(0, lastParams.Epsilon+dEpsilon)
+		lastParams.LastPriceCharged += dLastPrice
+		setFieldText(fieldLastPrice, strconv.FormatFloat(fromCentsPerKWh(lastParams.LastPriceCharged, unit), 'f', -1, 64))
+		setFieldText(fieldEpsilon, strconv.FormatFloat(fromCentsPerKWh(lastParams.Epsilon, unit), 'f', -1, 64))
+
+		schedule := planner.BuildBatterySchedule(lastPrices, *lastParams, time.Now().UTC())
+		lastSchedule = &schedule
+		updateStatus()
+		renderIfReady()
+	}
+
+	// toggleTomorrow switches between planning the whole horizon and only
+	// tomorrow's published prices; no refetch.
+	toggleTomorrow := func() {
+		if lastParams == nil || len(lastPrices) == 0 {
+			return
+		}
+		lastParams.TomorrowOnly = !lastParams.TomorrowOnly
+		schedule := planner.BuildBatterySchedule(lastPrices, *lastParams, time.Now().UTC())
+		lastSchedule = &schedule
+		updateStatus()
+		renderIfReady()
+	}
+
 	form.AddButton("Fetch & Plan", func() {
-		area := getFieldText(0)
-		market := getFieldText(1)
-		currency := getFieldText(2)
+		area := strings.ToUpper(selected(fieldArea, fieldOtherArea))
+		market := selected(fieldMarket, fieldOtherMarket)
+		currency := strings.ToUpper(selected(fieldCurrency, fieldOtherCurrency))
 
-		maxChargeStr := getFieldText(3)
-		maxDischargeStr := getFieldText(4)
-		lastPriceStr := getFieldText(5)
-		epsilonStr := getFieldText(6)
+		maxChargeStr := getFieldText(fieldMaxCharge)
+		maxDischargeStr := getFieldText(fieldMaxDischarge)
+		lastPriceStr := getFieldText(fieldLastPrice)
+		epsilonStr := getFieldText(fieldEpsilon)
 
 		maxCharge, err1 := strconv.ParseFloat(maxChargeStr, 64)
 		maxDischarge, err2 := strconv.ParseFloat(maxDischargeStr, 64)
@@ -99,62 +245,146 @@ func main() {
 			fmt.Fprintf(output, "[red]Error parsing numeric inputs.[-:-:-]\n")
 			return
 		}
+		if area == "" {
+			fmt.Fprintf(output, "[red]Pick an area or type one under %q.[-:-:-]\n", fieldOtherArea)
+			return
+		}
+		if market == "" || currency == "" {
+			fmt.Fprintf(output, "[red]Pick a market and currency, or type them under %q and %q.[-:-:-]\n", fieldOtherMarket, fieldOtherCurrency)
+			return
+		}
 
 		params := planner.BatteryStrategyParams{
 			Area:              area,
 			MaxChargeHours:    maxCharge,
 			MaxDischargeHours: maxDischarge,
-			LastPriceCharged:  lastPrice, // cents/kWh
-			Epsilon:           epsilon,   // cents/kWh
+			LastPriceCharged:  toCentsPerKWh(lastPrice, unit),
+			Epsilon:           toCentsPerKWh(epsilon, unit),
 			Market:            market,
 			Currency:          currency,
+			TomorrowOnly:      lastParams != nil && lastParams.TomorrowOnly, // keep the T toggle across fetches
 		}
 
 		fmt.Fprintf(output, "[yellow]Fetching prices for %s (using local cache)...[-:-:-]\n\n", area)
 
-		prices, err := planner.FetchNordpoolPricesCached(context.Background(), cachePath, area, market, currency)
+		var staleErr error
+		var noAreaPrices atomic.Bool
+		fetchOpts := planner.FetchOptionsFromEnv()
+		fetchOpts.OnMissingArea = func(_ time.Time, stats planner.ParseStats) {
+			if stats.EmptyAreaMaps == stats.Entries {
+				noAreaPrices.Store(true)
+			}
+		}
+		if cb, ok := form.GetFormItemByLabel(fieldFillGaps).(*tview.Checkbox); ok && cb.IsChecked() {
+			fetchOpts.FillGapSlots = maxFillGapSlots
+		}
+		cacheOpts := planner.CacheOptions{
+			Fetch:   fetchOpts,
+			OnStale: func(err error) { staleErr = err },
+		}
+		prices, err := planner.FetchNordpoolPricesCachedWithOptions(context.Background(), cachePath, area, market, currency, cacheOpts)
 		if err != nil {
 			fmt.Fprintf(output, "[red]Fetch error: %v[-:-:-]\n", err)
 			return
 		}
 		if len(prices) == 0 {
-			fmt.Fprintf(output, "[red]No prices returned.[-:-:-]\n")
+			if noAreaPrices.Load() {
+				fmt.Fprintf(output, "[red]No prices returned for %s: Nordpool %v.[-:-:-]\nThe data looks malformed or mid-update rather than missing; try again shortly.\n", area, planner.ErrNoAreaPrices)
+				return
+			}
+			if planner.IsKnownArea(area) {
+				fmt.Fprintf(output, "[red]No prices returned for %s (%s, %s).[-:-:-]\nNordpool may not have published yet, or the market/currency is not offered for this area.\n", area, market, currency)
+			} else {
+				fmt.Fprintf(output, "[red]No prices returned for %q.[-:-:-]\nIt is not a known Nordpool delivery area; check the spelling.\n", area)
+			}
 			return
 		}
 
+		if staleErr != nil {
+			note = fmt.Sprintf("[orange]Refresh failed, showing cached prices: %v[-:-:-]", staleErr)
+		}
+
+		// History is optional context for the chart; ignore cache read errors.
+		history, _ := planner.LoadRecentPrices(context.Background(), cachePath, area, market, currency, 7)
+
 		now := time.Now().UTC()
 		schedule := planner.BuildBatterySchedule(prices, params, now)
 
 		lastPrices = prices
+		lastHistory = history
+		lastParams = &params
 		lastSchedule = &schedule
 		filterMode = textchart.FilterAll
+		updateStatus()
 
 		output.Clear()
-		chart := textchart.Build(prices, schedule, now, filterMode, textchart.Options{Colorize: true})
+		chart := textchart.Build(prices, schedule, now, filterMode, textchart.Options{Colorize: true, History: history, TomorrowOnly: params.TomorrowOnly})
 		fmt.Fprint(output, chart)
 	})
 
+	// export writes the current plan to timestamped files under exportDir.
+	export := func(kind string) {
+		if lastSchedule == nil {
+			output.Clear()
+			fmt.Fprintf(output, "[red]Nothing to export yet; Fetch & Plan first.[-:-:-]\n")
+			return
+		}
+		paths, err := exportPlan(exportDir, kind, *lastSchedule, lastPrices, time.Now())
+		if err != nil {
+			note = fmt.Sprintf("[red]Export failed: %v[-:-:-]", err)
+		} else {
+			note = fmt.Sprintf("[green]Saved %s[-:-:-]", strings.Join(paths, ", "))
+		}
+		renderIfReady()
+	}
+
+	// short labels keep all buttons on one row of the form
+	form.AddButton("JSON", func() { export("json") })
+	form.AddButton("CSV", func() { export("csv") })
+
 	form.AddButton("Quit", func() {
 		app.Stop()
 	})
 
-	flex := tview.NewFlex().
-		AddItem(form, 40, 0, true).
+	body := tview.NewFlex().
+		AddItem(form, formWidth, 0, true).
 		AddItem(output, 0, 1, false)
 
 	root := tview.NewFlex().
 		SetDirection(tview.FlexRow).
-		AddItem(counterView, 4, 0, false).
-		AddItem(flex, 0, 1, true)
+		AddItem(body, 0, 1, true).
+		AddItem(status, 1, 0, false)
+
+	// compact layout: hide the form so the chart gets the full width
+	formVisible := true
+	toggleForm := func() {
+		formVisible = !formVisible
+		if formVisible {
+			body.ResizeItem(form, formWidth, 0)
+			app.SetFocus(form)
+		} else {
+			body.ResizeItem(form, 0, 0)
+			app.SetFocus(output)
+		}
+	}
 
-	// hotkeys: Esc = quit, A/C/D = filter, +/-/0 = counter demo
+	// hotkeys: Esc = quit, A/C/D = filter, P = show/hide params,
+	// [ / ] = epsilon down/up, { / } = last price down/up, T = tomorrow only
 	root.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyEsc {
 			app.Stop()
 			return nil
 		}
+		// let input fields and the area picker receive letters as text
+		switch app.GetFocus().(type) {
+		case *tview.InputField, *tview.DropDown:
+			return event
+		}
 		if event.Key() == tcell.KeyRune {
 			switch event.Rune() {
+			case 'p', 'P':
+				toggleForm()
+				return nil
 			case 'a', 'A':
 				filterMode = textchart.FilterAll
 				renderIfReady()
@@ -167,17 +397,20 @@ func main() {
 				filterMode = textchart.FilterDischargeOnly
 				renderIfReady()
 				return nil
-			case '+':
-				counter++
-				updateCounter()
+			case 't', 'T':
+				toggleTomorrow()
+				return nil
+			case '[':
+				tune(-tuneStep, 0)
 				return nil
-			case '-':
-				counter--
-				updateCounter()
+			case ']':
+				tune(tuneStep, 0)
 				return nil
-			case '0':
-				counter = 0
-				updateCounter()
+			case '{':
+				tune(0, -tuneStep)
+				return nil
+			case '}':
+				tune(0, tuneStep)
 				return nil
 			}
 		}
@@ -189,3 +422,84 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// exportPlan writes the schedule and prices as JSON (one file) or CSV (two
+// files) into dir and returns the written paths.
+func exportPlan(dir, kind string, schedule planner.ScheduleJSON, prices []planner.PriceSlot, now time.Time) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create %s: %w", dir, err)
+	}
+	stamp := now.Format("20060102-150405")
+
+	files := map[string][]byte{}
+	switch kind {
+	case "json":
+		pricesJSON, err := planner.PricesToJSON(prices)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.MarshalIndent(map[string]any{
+			"schedule": schedule,
+			"prices":   json.RawMessage(pricesJSON),
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files[fmt.Sprintf("plan-%s-%s.json", schedule.Area, stamp)] = b
+	case "csv":
+		scheduleCSV, err := planner.ScheduleToCSV(schedule)
+		if err != nil {
+			return nil, err
+		}
+		pricesCSV, err := planner.PricesToCSV(prices)
+		if err != nil {
+			return nil, err
+		}
+		files[fmt.Sprintf("schedule-%s-%s.csv", schedule.Area, stamp)] = []byte(scheduleCSV)
+		files[fmt.Sprintf("prices-%s-%s.csv", schedule.Area, stamp)] = []byte(pricesCSV)
+	default:
+		return nil, fmt.Errorf("unknown export format %q", kind)
+	}
+
+	var paths []string
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return paths, fmt.Errorf("write %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// toCentsPerKWh converts a price entered in unit to c/kWh.
+func toCentsPerKWh(v float64, unit string) float64 {
+	if unit == unitEURPerMWh {
+		return planner.EURPerMWhToCentsPerKWh(v)
+	}
+	return v
+}
+
+// fromCentsPerKWh converts a c/kWh price to unit for display.
+func fromCentsPerKWh(v float64, unit string) float64 {
+	if unit == unitEURPerMWh {
+		return planner.CentsPerKWhToEURPerMWh(v)
+	}
+	return v
+}
+
+// withOther returns a copy of options with otherOption appended.
+func withOther(options []string) []string {
+	return append(append([]string{}, options...), otherOption)
+}
+
+// indexOf returns the position of v in list, or 0 when absent.
+func indexOf(list []string, v string) int {
+	for i, s := range list {
+		if s == v {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestPriceUnitConversion(t *testing.T) {
+	tests := []struct {
+		unit  string
+		in    float64
+		cents float64
+	}{
+		{unitCentsPerKWh, 12.5, 12.5},
+		{unitEURPerMWh, 100, 10},
+		{unitEURPerMWh, 0, 0},
+		{unitEURPerMWh, -30, -3},
+	}
+	for _, tt := range tests {
+		if got := toCentsPerKWh(tt.in, tt.unit); got != tt.cents {
+			t.Errorf("toCentsPerKWh(%v, %q) = %v, want %v", tt.in, tt.unit, got, tt.cents)
+		}
+		if got := fromCentsPerKWh(tt.cents, tt.unit); got != tt.in {
+			t.Errorf("fromCentsPerKWh(%v, %q) = %v, want %v", tt.cents, tt.unit, got, tt.in)
+		}
+	}
+}
+
+func TestWithOther(t *testing.T) {
+	// Spare capacity would let a plain append write into the caller's array.
+	in := make([]string, 2, 3)
+	in[0], in[1] = "EUR", "SEK"
+	got := withOther(in)
+	if len(got) != 3 || got[2] != otherOption {
+		t.Fatalf("withOther(%v) = %v, want the options followed by %q", in, got, otherOption)
+	}
+	if spare := in[:3][2]; spare != "" {
+		t.Fatalf("withOther wrote %q into its input's backing array", spare)
+	}
+}
@@ -0,0 +1,490 @@
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Crossing marks the first slot on the other side of a price threshold.
+type Crossing struct {
+	Timestamp time.Time
+	Threshold float64 // cents/kWh
+	Rising    bool    // true when the price moved above the threshold
+}
+
+// PriceCrossings reports every crossing of the low and high thresholds within
+// the future horizon. A price is "above" high when strictly greater and "below"
+// low when strictly less. The last slot before now, if any, seeds the state so
+// a crossing right at the start of the horizon is not missed.
+func PriceCrossings(prices []PriceSlot, now time.Time, low, high float64) []Crossing {
+	sorted := make([]PriceSlot, len(prices))
+	copy(sorted, prices)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	var out []Crossing
+	var prev *PriceSlot
+	for i := range sorted {
+		cur := sorted[i]
+		if cur.Timestamp.Before(now) {
+			prev = &sorted[i]
+			continue
+		}
+		if prev != nil {
+			switch {
+			case prev.Price <= high && cur.Price > high:
+				out = append(out, Crossing{Timestamp: cur.Timestamp, Threshold: high, Rising: true})
+			case prev.Price > high && cur.Price <= high:
+				out = append(out, Crossing{Timestamp: cur.Timestamp, Threshold: high, Rising: false})
+			}
+			switch {
+			case prev.Price >= low && cur.Price < low:
+				out = append(out, Crossing{Timestamp: cur.Timestamp, Threshold: low, Rising: false})
+			case prev.Price < low && cur.Price >= low:
+				out = append(out, Crossing{Timestamp: cur.Timestamp, Threshold: low, Rising: true})
+			}
+		}
+		prev = &sorted[i]
+	}
+	return out
+}
+
+// FillGaps inserts slots for holes of up to maxGapSlots missing slots at the
+// inferred resolution, linearly interpolating between the neighbours. Filled
+// slots are flagged so charts can mark them and planning skips them. Larger
+// gaps are left as-is. The input is not modified.
+func FillGaps(prices []PriceSlot, maxGapSlots int) []PriceSlot {
+	sorted := make([]PriceSlot, len(prices))
+	copy(sorted, prices)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	if len(sorted) < 2 || maxGapSlots <= 0 {
+		return sorted
+	}
+
+	step := time.Duration(inferResolutionMinutes(sorted)) * time.Minute
+	out := make([]PriceSlot, 0, len(sorted))
+	out = append(out, sorted[0])
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		missing := int(cur.Timestamp.Sub(prev.Timestamp)/step) - 1
+		if missing > 0 && missing <= maxGapSlots && cur.Timestamp.Sub(prev.Timestamp)%step == 0 {
+			for k := 1; k <= missing; k++ {
+				frac := float64(k) / float64(missing+1)
+				out = append(out, PriceSlot{
+					Timestamp:       prev.Timestamp.Add(time.Duration(k) * step),
+					Price:           prev.Price + (cur.Price-prev.Price)*frac,
+					Preliminary:     prev.Preliminary || cur.Preliminary,
+					ExchangeRate:    prev.ExchangeRate,
+					Filled:          true,
+					DurationMinutes: prev.DurationMinutes,
+					AreaAverage:     prev.AreaAverage,
+					HasAreaAverage:  prev.HasAreaAverage,
+				})
+			}
+		}
+		out = append(out, cur)
+	}
+	return out
+}
+
+// NextActionJSON describes the upcoming battery action for polling clients.
+// Only Action is set when nothing is planned.
+type NextActionJSON struct {
+	Action    string   `json:"action"` // "charge", "discharge" or "idle"
+	At        string   `json:"at,omitempty"`
+	Price     *float64 `json:"price,omitempty"`
+	InSeconds *int64   `json:"in_seconds,omitempty"`
+}
+
+// NextAction returns the earliest charge/discharge slot that has not ended by
+// now. A slot already in progress reports in_seconds 0.
+func NextAction(schedule ScheduleJSON, now time.Time) NextActionJSON {
+	resolution := 60
+	if schedule.ResolutionMinutes != nil && *schedule.ResolutionMinutes > 0 {
+		resolution = *schedule.ResolutionMinutes
+	}
+	step := time.Duration(resolution) * time.Minute
+
+	next := NextActionJSON{Action: "idle"}
+	var best time.Time
+	consider := func(action string, slots []SlotJSON) {
+		for _, s := range slots {
+			ts, err := time.Parse(time.RFC3339, s.Timestamp)
+			if err != nil || !ts.Add(step).After(now) {
+				continue
+			}
+			if next.Action != "idle" && !ts.Before(best) {
+				continue
+			}
+			price := s.Price
+			in := int64(max(0, ts.Sub(now)) / time.Second)
+			best = ts
+			next = NextActionJSON{Action: action, At: s.Timestamp, Price: &price, InSeconds: &in}
+		}
+	}
+	consider("charge", schedule.ChargeSlots)
+	consider("discharge", schedule.DischargeSlots)
+	return next
+}
+
+// SweepEpsilon plans the same prices once per epsilon value, keeping every
+// other parameter from base. Schedules are returned in the order of values.
+func SweepEpsilon(prices []PriceSlot, base BatteryStrategyParams, values []float64, now time.Time) []ScheduleJSON {
+	out := make([]ScheduleJSON, 0, len(values))
+	for _, eps := range values {
+		params := base
+		params.Epsilon = eps
+		out = append(out, BuildBatterySchedule(prices, params, now))
+	}
+	return out
+}
+
+// MarginalHourValue returns how much the planned spread (scheduleValue, in
+// cents) grows when MaxChargeHours and MaxDischargeHours both allow one more
+// hour. Both grow because an extra charge hour on its own only adds cost; the
+// value is that of one more hour of cycling. Slots are weighted by the energy
+// they move, so 15-minute and hourly prices give the same result. Zero means
+// the extra hour is unused or unprofitable.
+func MarginalHourValue(prices []PriceSlot, params BatteryStrategyParams, now time.Time) float64 {
+	base := scheduleValue(BuildBatterySchedule(prices, params, now), params)
+	more := params
+	more.MaxChargeHours++
+	more.MaxDischargeHours++
+	return scheduleValue(BuildBatterySchedule(prices, more, now), more) - base
+}
+
+// scheduleValue returns the cents a schedule's discharge slots are worth
+// minus what its charge slots cost, at their slot prices. Each slot moves
+// PowerKW (the capacity per hour with a battery model, else 1 kW) over the
+// schedule's resolution, scaled by its PowerFraction when set.
+func scheduleValue(schedule ScheduleJSON, params BatteryStrategyParams) float64 {
+	power := params.PowerKW
+	if power <= 0 && params.CapacityKWh > 0 {
+		power = params.CapacityKWh
+	}
+	energy := slotEnergy(schedule, power)
+	slotValue := func(s SlotJSON) float64 {
+		f := 1.0
+		if s.PowerFraction != nil {
+			f = *s.PowerFraction
+		}
+		return f * energy * s.Price
+	}
+	var value float64
+	for _, s := range schedule.DischargeSlots {
+		value += slotValue(s)
+	}
+	for _, s := range schedule.ChargeSlots {
+		value -= slotValue(s)
+	}
+	return value
+}
+
+// SlotDiff compares a planned price with the realized one for a timestamp.
+// Delta is Actual-Planned and only meaningful when both sides are present.
+type SlotDiff struct {
+	Timestamp time.Time
+	Planned   float64
+	Actual    float64
+	Delta     float64
+	InPlanned bool
+	InActual  bool
+}
+
+// ComparePlanned aligns planned and actual prices by timestamp and returns one
+// diff per timestamp seen on either side, ordered by time.
+func ComparePlanned(planned, actual []PriceSlot) []SlotDiff {
+	byTime := make(map[time.Time]*SlotDiff)
+	get := func(ts time.Time) *SlotDiff {
+		d, ok := byTime[ts]
+		if !ok {
+			d = &SlotDiff{Timestamp: ts}
+			byTime[ts] = d
+		}
+		return d
+	}
+	for _, p := range planned {
+		d := get(p.Timestamp.UTC())
+		d.Planned, d.InPlanned = p.Price, true
+	}
+	for _, a := range actual {
+		d := get(a.Timestamp.UTC())
+		d.Actual, d.InActual = a.Price, true
+	}
+
+	out := make([]SlotDiff, 0, len(byTime))
+	for _, d := range byTime {
+		if d.InPlanned && d.InActual {
+			d.Delta = d.Actual - d.Planned
+		}
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Timestamp.Before(out[j].Timestamp)
+	})
+	return out
+}
+
+// RealizedVsExpected returns the schedule's spread per kWh moved (discharge
+// prices minus charge prices, cents) as planned and as realized under actual
+// prices. Slots without an actual price keep their planned price.
+func RealizedVsExpected(schedule ScheduleJSON, actual []PriceSlot) (expected, realized float64) {
+	actualAt := make(map[time.Time]float64, len(actual))
+	for _, a := range actual {
+		actualAt[a.Timestamp.UTC()] = a.Price
+	}
+	priceOf := func(s SlotJSON) float64 {
+		ts, err := time.Parse(time.RFC3339, s.Timestamp)
+		if err != nil {
+			return s.Price
+		}
+		if p, ok := actualAt[ts.UTC()]; ok {
+			return p
+		}
+		return s.Price
+	}
+	for _, s := range schedule.DischargeSlots {
+		expected += s.Price
+		realized += priceOf(s)
+	}
+	for _, s := range schedule.ChargeSlots {
+		expected -= s.Price
+		realized -= priceOf(s)
+	}
+	return expected, realized
+}
+
+// Slot price classes returned by ClassifySlots.
+const (
+	ClassCheap     = "cheap"
+	ClassNormal    = "normal"
+	ClassExpensive = "expensive"
+)
+
+// ClassifySlots labels each slot by its price tertile within its UTC day: a
+// slot is cheap when fewer than a third of the day's slots are cheaper,
+// expensive when at least two thirds are, and normal otherwise. Equal prices
+// share a label.
+func ClassifySlots(prices []PriceSlot) map[time.Time]string {
+	byDay := make(map[string][]float64)
+	for _, p := range prices {
+		day := utcDayKey(p.Timestamp)
+		byDay[day] = append(byDay[day], p.Price)
+	}
+	for _, day := range byDay {
+		sort.Float64s(day)
+	}
+
+	out := make(map[time.Time]string, len(prices))
+	for _, p := range prices {
+		day := byDay[utcDayKey(p.Timestamp)]
+		cheaper := sort.SearchFloat64s(day, p.Price)
+		frac := float64(cheaper) / float64(len(day))
+		switch {
+		case frac < 1.0/3:
+			out[p.Timestamp] = ClassCheap
+		case frac >= 2.0/3:
+			out[p.Timestamp] = ClassExpensive
+		default:
+			out[p.Timestamp] = ClassNormal
+		}
+	}
+	return out
+}
+
+// ErrStaleNow reports that a planning "now" lags far behind the data or the
+// wall clock, so the planner would select slots that have already elapsed.
+var ErrStaleNow = errors.New("planning time is stale")
+
+// CheckNow returns an error wrapping ErrStaleNow when now is more than
+// tolerance behind the day before the latest slot's UTC day (the normal
+// today+tomorrow window), or more than tolerance behind wall. A zero wall
+// skips the clock check.
+func CheckNow(prices []PriceSlot, now, wall time.Time, tolerance time.Duration) error {
+	if !wall.IsZero() && wall.Sub(now) > tolerance {
+		return fmt.Errorf("%w: %s is %s behind the clock", ErrStaleNow, now.UTC().Format(time.RFC3339), wall.Sub(now).Round(time.Second))
+	}
+	var latest time.Time
+	for _, p := range prices {
+		if p.Timestamp.After(latest) {
+			latest = p.Timestamp
+		}
+	}
+	if latest.IsZero() {
+		return nil
+	}
+	l := latest.UTC()
+	windowStart := time.Date(l.Year(), l.Month(), l.Day()-1, 0, 0, 0, 0, time.UTC)
+	if lag := windowStart.Sub(now); lag > tolerance {
+		return fmt.Errorf("%w: %s is %s before the price window starting %s", ErrStaleNow, now.UTC().Format(time.RFC3339), lag.Round(time.Second), windowStart.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// priceWindow is a run of consecutive slots and its average price.
+type priceWindow struct {
+	start, end time.Time // end is exclusive
+	avg        float64
+}
+
+func (w priceWindow) interval() IntervalJSON {
+	return IntervalJSON{Start: w.start.Format(time.RFC3339), End: w.end.Format(time.RFC3339), AvgPrice: w.avg}
+}
+
+// slidingWindows returns every gap-free run of windowHours worth of slots, in
+// time order. With sameDay, runs may not cross UTC midnight.
+func slidingWindows(prices []PriceSlot, windowHours float64, sameDay bool) []priceWindow {
+	sorted := make([]PriceSlot, len(prices))
+	copy(sorted, prices)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	if len(sorted) == 0 || windowHours <= 0 {
+		return nil
+	}
+	resolution := inferResolutionMinutes(sorted)
+	step := time.Duration(resolution) * time.Minute
+	n := slotsForHours(windowHours, resolution, RoundCeil)
+
+	var out []priceWindow
+	for i := 0; i+n <= len(sorted); i++ {
+		first, last := sorted[i], sorted[i+n-1]
+		if last.Timestamp.Sub(first.Timestamp) != time.Duration(n-1)*step {
+			continue
+		}
+		if sameDay && utcDayKey(first.Timestamp) != utcDayKey(last.Timestamp) {
+			continue
+		}
+		sum := 0.0
+		for _, s := range sorted[i : i+n] {
+			sum += s.Price
+		}
+		out = append(out, priceWindow{start: first.Timestamp, end: last.Timestamp.Add(step), avg: sum / float64(n)})
+	}
+	return out
+}
+
+// DailyExtremeWindows returns the cheapest (trough) and dearest (peak)
+// contiguous windowHours windows, by average price, that lie within a single
+// UTC day. Pass one day's slots to get that day's windows; both results are
+// zero when no complete window fits. Ties go to the earlier window.
+func DailyExtremeWindows(prices []PriceSlot, windowHours float64) (trough, peak IntervalJSON) {
+	windows := slidingWindows(prices, windowHours, true)
+	if len(windows) == 0 {
+		return IntervalJSON{}, IntervalJSON{}
+	}
+	lo, hi := windows[0], windows[0]
+	for _, w := range windows[1:] {
+		if w.avg < lo.avg {
+			lo = w
+		}
+		if w.avg > hi.avg {
+			hi = w
+		}
+	}
+	return lo.interval(), hi.interval()
+}
+
+// CheapestWindow returns the contiguous window of the given length with the
+// lowest average price, e.g. to schedule an appliance. ok is false when no
+// gap-free window fits. Ties go to the earlier window.
+func CheapestWindow(prices []PriceSlot, hours float64) (IntervalJSON, bool) {
+	windows := slidingWindows(prices, hours, false)
+	if len(windows) == 0 {
+		return IntervalJSON{}, false
+	}
+	best := windows[0]
+	for _, w := range windows[1:] {
+		if w.avg < best.avg {
+			best = w
+		}
+	}
+	return best.interval(), true
+}
+
+// DownsamplePrices reduces prices to at most maxPoints by averaging runs of
+// consecutive slots (in time order), so narrow peaks still move their bucket
+// unlike picking every n-th slot. Each bucket keeps its first timestamp, sums
+// DurationMinutes and is preliminary/filled if any/all members are. Inputs
+// within the limit are returned sorted but otherwise unchanged.
+func DownsamplePrices(prices []PriceSlot, maxPoints int) []PriceSlot {
+	sorted := make([]PriceSlot, len(prices))
+	copy(sorted, prices)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	if maxPoints <= 0 || len(sorted) <= maxPoints {
+		return sorted
+	}
+
+	step := (len(sorted) + maxPoints - 1) / maxPoints
+	out := make([]PriceSlot, 0, maxPoints)
+	for i := 0; i < len(sorted); i += step {
+		bucket := sorted[i:min(i+step, len(sorted))]
+		agg := PriceSlot{Timestamp: bucket[0].Timestamp, ExchangeRate: bucket[0].ExchangeRate, Filled: true}
+		for _, s := range bucket {
+			agg.Price += s.Price
+			agg.Preliminary = agg.Preliminary || s.Preliminary
+			agg.Filled = agg.Filled && s.Filled
+			agg.DurationMinutes += s.DurationMinutes
+		}
+		agg.Price /= float64(len(bucket))
+		out = append(out, agg)
+	}
+	return out
+}
+
+// BucketizeHourly returns the average price of each UTC hour of the day
+// containing day, with nil for hours without slots. Sub-hour slots (e.g.
+// 15-minute data) are averaged into their hour; slots on other days are
+// ignored.
+func BucketizeHourly(prices []PriceSlot, day time.Time) [24]*float64 {
+	d := day.UTC()
+	start := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	var sum [24]float64
+	var count [24]int
+	for _, s := range prices {
+		ts := s.Timestamp.UTC()
+		if ts.Before(start) || !ts.Before(end) {
+			continue
+		}
+		sum[ts.Hour()] += s.Price
+		count[ts.Hour()]++
+	}
+
+	var out [24]*float64
+	for h := range out {
+		if count[h] > 0 {
+			avg := sum[h] / float64(count[h])
+			out[h] = &avg
+		}
+	}
+	return out
+}
+
+// SuggestEpsilon returns an epsilon (cents/kWh) under which roughly the
+// cheapest quarter of the unfilled slots from now on qualify for charging:
+// lastPriceCharged minus the horizon's 25th-percentile price, floored at 0.
+// It is 0 when there are no such slots.
+func SuggestEpsilon(prices []PriceSlot, lastPriceCharged float64, now time.Time) float64 {
+	var future []float64
+	for _, p := range prices {
+		if !p.Filled && !p.Timestamp.Before(now) {
+			future = append(future, p.Price)
+		}
+	}
+	if len(future) == 0 {
+		return 0
+	}
+	sort.Float64s(future)
+	q := future[(len(future)-1)/4]
+	return math.Max(0, lastPriceCharged-q)
+}
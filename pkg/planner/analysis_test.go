@@ -0,0 +1,459 @@
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPriceCrossings(t *testing.T) {
+	at := func(h int) time.Time { return testDay.Add(time.Duration(h) * time.Hour) }
+	tests := []struct {
+		name   string
+		prices []float64
+		now    time.Time
+		want   []Crossing
+	}{
+		{
+			name:   "rising through high",
+			prices: []float64{10, 12, 21, 25},
+			want:   []Crossing{{Timestamp: at(2), Threshold: 20, Rising: true}},
+		},
+		{
+			name:   "falling through low",
+			prices: []float64{10, 6, 4, 3},
+			want:   []Crossing{{Timestamp: at(2), Threshold: 5, Rising: false}},
+		},
+		{
+			name:   "both ways",
+			prices: []float64{10, 22, 10, 4, 10},
+			want: []Crossing{
+				{Timestamp: at(1), Threshold: 20, Rising: true},
+				{Timestamp: at(2), Threshold: 20, Rising: false},
+				{Timestamp: at(3), Threshold: 5, Rising: false},
+				{Timestamp: at(4), Threshold: 5, Rising: true},
+			},
+		},
+		{
+			name:   "past slot seeds the state",
+			prices: []float64{25, 10},
+			now:    at(1),
+			want:   []Crossing{{Timestamp: at(1), Threshold: 20, Rising: false}},
+		},
+		{
+			name:   "touching a threshold is not crossing it",
+			prices: []float64{10, 20, 5, 10},
+		},
+		{
+			name:   "flat",
+			prices: []float64{10, 10, 10, 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := tt.now
+			if now.IsZero() {
+				now = testDay
+			}
+			got := PriceCrossings(hourly(testDay, tt.prices...), now, 5, 20)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSweepEpsilon(t *testing.T) {
+	prices := hourly(testDay, 2, 4, 6, 7, 9, 12, 15)
+	base := BatteryStrategyParams{MaxChargeHours: 24, MaxDischargeHours: 24, LastPriceCharged: 10}
+	values := []float64{0, 1, 3, 5, 7, 9}
+
+	schedules := SweepEpsilon(prices, base, values, testDay)
+	if len(schedules) != len(values) {
+		t.Fatalf("got %d schedules for %d values", len(schedules), len(values))
+	}
+	for i, s := range schedules {
+		if s.Epsilon != values[i] {
+			t.Errorf("schedule %d has epsilon %v, want %v", i, s.Epsilon, values[i])
+		}
+		if i > 0 && len(s.ChargeSlots) > len(schedules[i-1].ChargeSlots) {
+			t.Errorf("epsilon %v charges %d slots, more than %d at epsilon %v",
+				values[i], len(s.ChargeSlots), len(schedules[i-1].ChargeSlots), values[i-1])
+		}
+	}
+	if first, last := len(schedules[0].ChargeSlots), len(schedules[len(schedules)-1].ChargeSlots); first <= last {
+		t.Errorf("charge slots went from %d to %d; the sweep should narrow them", first, last)
+	}
+}
+
+func TestComparePlanned(t *testing.T) {
+	h := func(n int) time.Time { return testDay.Add(time.Duration(n) * time.Hour) }
+	tests := []struct {
+		name    string
+		planned []PriceSlot
+		actual  []PriceSlot
+		want    []SlotDiff
+	}{
+		{
+			name:    "matching",
+			planned: hourly(testDay, 10, 20),
+			actual:  hourly(testDay, 12, 15),
+			want: []SlotDiff{
+				{Timestamp: h(0), Planned: 10, Actual: 12, Delta: 2, InPlanned: true, InActual: true},
+				{Timestamp: h(1), Planned: 20, Actual: 15, Delta: -5, InPlanned: true, InActual: true},
+			},
+		},
+		{
+			name:    "mismatched",
+			planned: hourly(testDay, 10, 20),
+			actual:  hourly(h(1), 25, 30),
+			want: []SlotDiff{
+				{Timestamp: h(0), Planned: 10, InPlanned: true},
+				{Timestamp: h(1), Planned: 20, Actual: 25, Delta: 5, InPlanned: true, InActual: true},
+				{Timestamp: h(2), Actual: 30, InActual: true},
+			},
+		},
+		{
+			name:    "no actual",
+			planned: hourly(testDay, 10),
+			want:    []SlotDiff{{Timestamp: h(0), Planned: 10, InPlanned: true}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ComparePlanned(tc.planned, tc.actual)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d diffs, want %d: %+v", len(got), len(tc.want), got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("diff %d = %+v, want %+v", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRealizedVsExpected(t *testing.T) {
+	schedule := ScheduleJSON{
+		ChargeSlots:    []SlotJSON{{Timestamp: "2025-01-15T02:00:00Z", Price: 5}},
+		DischargeSlots: []SlotJSON{{Timestamp: "2025-01-15T18:00:00Z", Price: 20}},
+	}
+	tests := []struct {
+		name         string
+		actual       []PriceSlot
+		wantRealized float64
+	}{
+		{"no actual", nil, 15},
+		{"both corrected", []PriceSlot{
+			{Timestamp: testDay.Add(2 * time.Hour), Price: 7},
+			{Timestamp: testDay.Add(18 * time.Hour), Price: 18},
+		}, 11},
+		{"other timestamps ignored", []PriceSlot{
+			{Timestamp: testDay.Add(3 * time.Hour), Price: 100},
+		}, 15},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			expected, realized := RealizedVsExpected(schedule, tc.actual)
+			if expected != 15 || realized != tc.wantRealized {
+				t.Errorf("got expected %v realized %v, want 15 and %v", expected, realized, tc.wantRealized)
+			}
+		})
+	}
+}
+
+func TestClassifySlots(t *testing.T) {
+	tests := []struct {
+		name   string
+		prices []PriceSlot
+		want   []string // per slot, in order
+	}{
+		{
+			// Exactly a third cheaper is normal; exactly two thirds is expensive.
+			name:   "tertile boundaries",
+			prices: hourly(testDay, 1, 2, 3, 4, 5, 6),
+			want:   []string{ClassCheap, ClassCheap, ClassNormal, ClassNormal, ClassExpensive, ClassExpensive},
+		},
+		{
+			name:   "ties share a label",
+			prices: hourly(testDay, 1, 1, 1, 9),
+			want:   []string{ClassCheap, ClassCheap, ClassCheap, ClassExpensive},
+		},
+		{
+			name:   "flat day",
+			prices: hourly(testDay, 5, 5, 5),
+			want:   []string{ClassCheap, ClassCheap, ClassCheap},
+		},
+		{
+			// 21:00-23:00 on one UTC day and 00:00-02:00 on the next: each
+			// day is ranked on its own.
+			name:   "per UTC day",
+			prices: hourly(testDay.Add(21*time.Hour), 10, 20, 30, 1, 2, 3),
+			want:   []string{ClassCheap, ClassNormal, ClassExpensive, ClassCheap, ClassNormal, ClassExpensive},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifySlots(tt.prices)
+			for i, p := range tt.prices {
+				if got[p.Timestamp] != tt.want[i] {
+					t.Errorf("%v (%.0f) = %q, want %q", p.Timestamp.Format("01-02 15:04"), p.Price, got[p.Timestamp], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckNow(t *testing.T) {
+	// Today and tomorrow, as fetched on testDay.
+	prices := hourly(testDay, make([]float64, 48)...)
+	weekAgo := testDay.Add(-7 * 24 * time.Hour)
+	tests := []struct {
+		name      string
+		prices    []PriceSlot
+		now, wall time.Time
+		wantStale bool
+	}{
+		{"current", prices, testDay.Add(10 * time.Hour), testDay.Add(10 * time.Hour), false},
+		{"week old now against the data", prices, weekAgo, time.Time{}, true},
+		{"week old now against the clock", nil, weekAgo, testDay, true},
+		{"within tolerance", prices, testDay.Add(-30 * time.Minute), testDay, false},
+		{"no prices and no clock", nil, weekAgo, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckNow(tt.prices, tt.now, tt.wall, time.Hour)
+			if got := errors.Is(err, ErrStaleNow); got != tt.wantStale {
+				t.Errorf("CheckNow = %v, want stale %t", err, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestDailyExtremeWindows(t *testing.T) {
+	// A clear 03:00-05:00 trough and 18:00-20:00 peak.
+	day := []float64{
+		6, 5, 4, 1, 1, 4, 6, 8, 9, 8, 7, 7,
+		7, 7, 8, 9, 11, 14, 20, 22, 12, 9, 7, 6,
+	}
+	tests := []struct {
+		name   string
+		prices []PriceSlot
+		hours  float64
+		trough IntervalJSON
+		peak   IntervalJSON
+	}{
+		{
+			name:   "two hours",
+			prices: hourly(testDay, day...),
+			hours:  2,
+			trough: IntervalJSON{Start: "2025-01-15T03:00:00Z", End: "2025-01-15T05:00:00Z", AvgPrice: 1},
+			peak:   IntervalJSON{Start: "2025-01-15T18:00:00Z", End: "2025-01-15T20:00:00Z", AvgPrice: 21},
+		},
+		{
+			name:   "one hour",
+			prices: hourly(testDay, day...),
+			hours:  1,
+			trough: IntervalJSON{Start: "2025-01-15T03:00:00Z", End: "2025-01-15T04:00:00Z", AvgPrice: 1},
+			peak:   IntervalJSON{Start: "2025-01-15T19:00:00Z", End: "2025-01-15T20:00:00Z", AvgPrice: 22},
+		},
+		{
+			// Windows may not straddle midnight.
+			name:   "across midnight",
+			prices: hourly(testDay.Add(22*time.Hour), 1, 1, 9, 9),
+			hours:  2,
+			trough: IntervalJSON{Start: "2025-01-15T22:00:00Z", End: "2025-01-16T00:00:00Z", AvgPrice: 1},
+			peak:   IntervalJSON{Start: "2025-01-16T00:00:00Z", End: "2025-01-16T02:00:00Z", AvgPrice: 9},
+		},
+		{
+			name:   "too short",
+			prices: hourly(testDay, 1, 2),
+			hours:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trough, peak := DailyExtremeWindows(tt.prices, tt.hours)
+			if trough != tt.trough {
+				t.Errorf("trough = %+v, want %+v", trough, tt.trough)
+			}
+			if peak != tt.peak {
+				t.Errorf("peak = %+v, want %+v", peak, tt.peak)
+			}
+		})
+	}
+}
+
+func TestDownsamplePrices(t *testing.T) {
+	// A flat day with a one-slot spike at 05:00.
+	series := hourly(testDay, 5, 5, 5, 5, 5, 50, 5, 5, 5, 5, 5, 5)
+	maxPrice := func(slots []PriceSlot) float64 {
+		m := math.Inf(-1)
+		for _, s := range slots {
+			m = math.Max(m, s.Price)
+		}
+		return m
+	}
+
+	// Picking every 3rd slot keeps 00, 03, 06 and 09 and loses the spike.
+	var picked []PriceSlot
+	for i := 0; i < len(series); i += 3 {
+		picked = append(picked, series[i])
+	}
+	if m := maxPrice(picked); m != 5 {
+		t.Fatalf("picking kept a max of %v; the fixture should hide the spike", m)
+	}
+
+	tests := []struct {
+		name      string
+		maxPoints int
+		wantLen   int
+		wantMax   float64
+	}{
+		{"averaged buckets keep the spike", 4, 4, 20}, // (5+5+50)/3
+		{"within the limit", 12, 12, 50},
+		{"no limit", 0, 12, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DownsamplePrices(series, tt.maxPoints)
+			if len(got) != tt.wantLen {
+				t.Fatalf("got %d points, want %d", len(got), tt.wantLen)
+			}
+			if m := maxPrice(got); m != tt.wantMax {
+				t.Errorf("max = %v, want %v", m, tt.wantMax)
+			}
+			if !got[1].Timestamp.Equal(series[len(series)/tt.wantLen].Timestamp) {
+				t.Errorf("second point at %v, want its bucket's first slot", got[1].Timestamp)
+			}
+		})
+	}
+}
+
+func TestBucketizeHourly(t *testing.T) {
+	hourlyDay := make([]float64, 24)
+	for h := range hourlyDay {
+		hourlyDay[h] = float64(h)
+	}
+	// Quarter hours of hour h priced h, h+1, h+2, h+3: the hour averages h+1.5.
+	var quarters []PriceSlot
+	for i := 0; i < 96; i++ {
+		quarters = append(quarters, PriceSlot{Timestamp: testDay.Add(time.Duration(i) * 15 * time.Minute), Price: float64(i/4 + i%4)})
+	}
+	// Only 00:00-02:00 plus slots on the neighbouring days.
+	partial := append(hourly(testDay.Add(-time.Hour), 100, 1, 2), hourly(testDay.Add(24*time.Hour), 100)...)
+
+	tests := []struct {
+		name   string
+		prices []PriceSlot
+		want   func(h int) *float64
+	}{
+		{"60 minutes", hourly(testDay, hourlyDay...), func(h int) *float64 { v := float64(h); return &v }},
+		{"15 minutes", quarters, func(h int) *float64 { v := float64(h) + 1.5; return &v }},
+		{"missing hours and other days", partial, func(h int) *float64 {
+			if h > 1 {
+				return nil
+			}
+			v := float64(h + 1)
+			return &v
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BucketizeHourly(tt.prices, testDay.Add(12*time.Hour))
+			for h, p := range got {
+				want := tt.want(h)
+				switch {
+				case (p == nil) != (want == nil):
+					t.Errorf("hour %d = %s, want %s", h, fmtPtr(p), fmtPtr(want))
+				case p != nil && math.Abs(*p-*want) > 1e-9:
+					t.Errorf("hour %d = %v, want %v", h, *p, *want)
+				}
+			}
+		})
+	}
+}
+
+func TestSuggestEpsilon(t *testing.T) {
+	flat := make([]float64, 24)
+	volatile := make([]float64, 24)
+	for h := range flat {
+		flat[h] = 8
+		volatile[h] = float64(h + 1) // 1..24
+	}
+	tests := []struct {
+		name       string
+		prices     []PriceSlot
+		last       float64
+		now        time.Time
+		want       float64
+		qualifying int // slots at least the suggestion below last
+	}{
+		{"flat day", hourly(testDay, flat...), 10, testDay, 2, 24},
+		// The 25th percentile of 1..24 is 6: six slots qualify.
+		{"volatile day", hourly(testDay, volatile...), 10, testDay, 4, 6},
+		// From 12:00 the prices are 13..24, whose 25th percentile is 15.
+		{"past slots ignored", hourly(testDay, volatile...), 20, testDay.Add(12 * time.Hour), 5, 3},
+		{"floored at zero", hourly(testDay, volatile...), 2, testDay, 0, 2},
+		{"nothing ahead", hourly(testDay, flat...), 10, testDay.Add(48 * time.Hour), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SuggestEpsilon(tt.prices, tt.last, tt.now)
+			if got != tt.want {
+				t.Fatalf("epsilon = %v, want %v", got, tt.want)
+			}
+			n := 0
+			for _, p := range tt.prices {
+				if !p.Timestamp.Before(tt.now) && tt.last-p.Price >= got {
+					n++
+				}
+			}
+			if n != tt.qualifying {
+				t.Errorf("%d slots qualify, want %d", n, tt.qualifying)
+			}
+		})
+	}
+}
+
+func TestMarginalHourValue(t *testing.T) {
+	// Charge candidates are 1, 2 and 3; discharge candidates 30, 20 and 15.
+	hourlyPrices := hourly(testDay, 1, 2, 3, 20, 15, 30)
+	var quarterPrices []PriceSlot
+	for _, p := range hourlyPrices {
+		for q := 0; q < 4; q++ {
+			quarterPrices = append(quarterPrices, PriceSlot{Timestamp: p.Timestamp.Add(time.Duration(q) * 15 * time.Minute), Price: p.Price})
+		}
+	}
+	tests := []struct {
+		name  string
+		hours float64
+		power float64
+		want  float64 // cents
+	}{
+		{"second hour", 1, 0, 20 - 2},
+		{"third hour", 2, 0, 15 - 3},
+		{"no candidates left", 3, 0, 0},
+		{"from zero", 0, 0, 30 - 1},
+		{"2 kW", 1, 2, 2 * (20 - 2)},
+	}
+	for _, tt := range tests {
+		for _, series := range []struct {
+			name   string
+			prices []PriceSlot
+		}{{"hourly", hourlyPrices}, {"15-minute", quarterPrices}} {
+			t.Run(tt.name+"/"+series.name, func(t *testing.T) {
+				params := BatteryStrategyParams{
+					MaxChargeHours: tt.hours, MaxDischargeHours: tt.hours,
+					LastPriceCharged: 5, Epsilon: 1, PowerKW: tt.power,
+				}
+				if got := MarginalHourValue(series.prices, params, testDay); math.Abs(got-tt.want) > 1e-9 {
+					t.Errorf("MarginalHourValue = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package planner
+
+import "strings"
+
+// KnownAreas lists Nordpool day-ahead delivery areas the frontends offer by default.
+var KnownAreas = []string{
+	"EE", "LT", "LV", "FI",
+	"SE1", "SE2", "SE3", "SE4",
+	"NO1", "NO2", "NO3", "NO4", "NO5",
+	"DK1", "DK2",
+	"AT", "BE", "FR", "GER", "NL", "PL",
+	"SYS",
+}
+
+// IsKnownArea reports whether area is one of KnownAreas.
+func IsKnownArea(area string) bool {
+	for _, a := range KnownAreas {
+		if a == area {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportedMarkets lists the market values the day-ahead API accepts.
+func SupportedMarkets() []string {
+	return []string{"DayAhead"}
+}
+
+// localCurrencies maps delivery-area prefixes to the local currency Nordpool
+// also quotes them in, besides EUR.
+var localCurrencies = map[string]string{
+	"DK": "DKK",
+	"NO": "NOK",
+	"SE": "SEK",
+	"PL": "PLN",
+}
+
+// SupportedCurrencies lists the currencies area can be priced in: EUR
+// first, then the area's local currency where Nordpool offers one.
+func SupportedCurrencies(area string) []string {
+	out := []string{"EUR"}
+	for prefix, cur := range localCurrencies {
+		if strings.HasPrefix(area, prefix) {
+			out = append(out, cur)
+		}
+	}
+	return out
+}
+
+// genericDefaults are the defaults for areas without an AreaDefaults entry.
+var genericDefaults = BatteryStrategyParams{
+	Market:            "DayAhead",
+	Currency:          "EUR",
+	MaxChargeHours:    3,
+	MaxDischargeHours: 3,
+	LastPriceCharged:  15,
+	Epsilon:           2,
+}
+
+// AreaDefaults holds starting params per delivery area, tuned to its usual
+// price level; frontends prefill their forms from it via
+// DefaultParamsForArea. Callers may edit or extend it at startup.
+var AreaDefaults = map[string]BatteryStrategyParams{
+	"LV":  genericDefaults,
+	"LT":  genericDefaults,
+	"EE":  withPrices(genericDefaults, 14, 2),
+	"FI":  withPrices(genericDefaults, 8, 1.5),
+	"SE1": withPrices(genericDefaults, 4, 1),
+	"SE2": withPrices(genericDefaults, 4, 1),
+	"SE3": withPrices(genericDefaults, 8, 1.5),
+	"SE4": withPrices(genericDefaults, 10, 2),
+	"NO1": withPrices(genericDefaults, 8, 1.5),
+	"NO2": withPrices(genericDefaults, 8, 1.5),
+	"NO3": withPrices(genericDefaults, 4, 1),
+	"NO4": withPrices(genericDefaults, 3, 1),
+	"NO5": withPrices(genericDefaults, 8, 1.5),
+	"DK1": withPrices(genericDefaults, 11, 2),
+	"DK2": withPrices(genericDefaults, 11, 2),
+	"GER": withPrices(genericDefaults, 11, 2),
+}
+
+func withPrices(p BatteryStrategyParams, lastPriceCharged, epsilon float64) BatteryStrategyParams {
+	p.LastPriceCharged, p.Epsilon = lastPriceCharged, epsilon
+	return p
+}
+
+// DefaultParamsForArea returns area's AreaDefaults entry, or generic
+// defaults for areas without one, with Area set.
+func DefaultParamsForArea(area string) BatteryStrategyParams {
+	p, ok := AreaDefaults[area]
+	if !ok {
+		p = genericDefaults
+	}
+	p.Area = area
+	return p
+}
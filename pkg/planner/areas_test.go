@@ -0,0 +1,92 @@
+package planner
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDefaultParamsForArea(t *testing.T) {
+	tests := []struct {
+		area                 string
+		wantLastPriceCharged float64
+		wantEpsilon          float64
+	}{
+		{"LV", 15, 2},
+		{"NO2", 8, 1.5},
+		{"NO4", 3, 1},
+		{"XX", genericDefaults.LastPriceCharged, genericDefaults.Epsilon}, // unknown: generic
+		{"", genericDefaults.LastPriceCharged, genericDefaults.Epsilon},
+	}
+	for _, tt := range tests {
+		t.Run(tt.area, func(t *testing.T) {
+			got := DefaultParamsForArea(tt.area)
+			if got.Area != tt.area {
+				t.Errorf("Area = %q, want %q", got.Area, tt.area)
+			}
+			if got.LastPriceCharged != tt.wantLastPriceCharged || got.Epsilon != tt.wantEpsilon {
+				t.Errorf("LastPriceCharged, Epsilon = %v, %v; want %v, %v",
+					got.LastPriceCharged, got.Epsilon, tt.wantLastPriceCharged, tt.wantEpsilon)
+			}
+			if got.Market != "DayAhead" || got.Currency != "EUR" || got.MaxChargeHours != 3 || got.MaxDischargeHours != 3 {
+				t.Errorf("shared defaults lost: %+v", got)
+			}
+		})
+	}
+
+	if lv, no2 := DefaultParamsForArea("LV"), DefaultParamsForArea("NO2"); lv.LastPriceCharged == no2.LastPriceCharged {
+		t.Errorf("LV and NO2 share LastPriceCharged %v", lv.LastPriceCharged)
+	}
+}
+
+func TestAreaDefaultsEditable(t *testing.T) {
+	saved, had := AreaDefaults["XX"]
+	t.Cleanup(func() {
+		if had {
+			AreaDefaults["XX"] = saved
+		} else {
+			delete(AreaDefaults, "XX")
+		}
+	})
+
+	AreaDefaults["XX"] = withPrices(genericDefaults, 42, 4)
+	if got := DefaultParamsForArea("XX"); got.LastPriceCharged != 42 || got.Epsilon != 4 {
+		t.Errorf("DefaultParamsForArea ignored an edited entry: %+v", got)
+	}
+	if AreaDefaults["XX"].Area != "" {
+		t.Errorf("DefaultParamsForArea wrote Area back into AreaDefaults")
+	}
+}
+
+func TestSupportedMarkets(t *testing.T) {
+	got := SupportedMarkets()
+	if len(got) == 0 || !slices.Contains(got, "DayAhead") {
+		t.Errorf("SupportedMarkets() = %q, want DayAhead included", got)
+	}
+}
+
+func TestSupportedCurrencies(t *testing.T) {
+	tests := []struct {
+		area string
+		want []string
+	}{
+		{"LV", []string{"EUR"}},
+		{"SE3", []string{"EUR", "SEK"}},
+		{"NO2", []string{"EUR", "NOK"}},
+		{"DK1", []string{"EUR", "DKK"}},
+		{"PL", []string{"EUR", "PLN"}},
+		{"XX", []string{"EUR"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.area, func(t *testing.T) {
+			if got := SupportedCurrencies(tt.area); !slices.Equal(got, tt.want) {
+				t.Errorf("SupportedCurrencies(%q) = %q, want %q", tt.area, got, tt.want)
+			}
+		})
+	}
+	// Every known area can be priced in EUR, listed first.
+	for _, area := range KnownAreas {
+		if got := SupportedCurrencies(area); len(got) == 0 || got[0] != "EUR" {
+			t.Errorf("SupportedCurrencies(%q) = %q, want EUR first", area, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package planner
+
+import "time"
+
+// Tariff describes the retail price components around the spot price.
+// All prices are in cents/kWh.
+type Tariff struct {
+	Markup     float64 // supplier margin added to spot
+	GridFee    float64 // network fee per imported kWh
+	Tax        float64 // excise per imported kWh
+	VATPercent float64 // applied to the full import price
+	FeedInRate float64 // paid per exported kWh; 0 pays the slot's export price, else spot
+	PowerKW    float64 // battery charge/discharge power; 0 means 1 kW (per-kW estimate)
+}
+
+// BillEstimate summarises the money moved by a schedule, in cents.
+type BillEstimate struct {
+	ImportCost    float64 `json:"import_cost"`    // paid for charging
+	AvoidedCost   float64 `json:"avoided_cost"`   // import avoided by discharging into the load
+	ExportRevenue float64 `json:"export_revenue"` // discharge beyond the load, sold back
+	Net           float64 `json:"net"`            // avoided + export - import; positive is a saving
+}
+
+// importPrice returns the all-in price of one imported kWh at the given spot price.
+func (t Tariff) importPrice(spot float64) float64 {
+	return (spot + t.Markup + t.GridFee + t.Tax) * (1 + t.VATPercent/100)
+}
+
+// EstimateBill prices a schedule under a tariff. load holds household
+// consumption per slot with Price carrying kWh; discharge first covers the
+// load in its slot and the rest is exported at FeedInRate, else the slot's
+// ExportPrice, else its spot price.
+func EstimateBill(schedule ScheduleJSON, tariff Tariff, load []SlotJSON) BillEstimate {
+	energy := slotEnergy(schedule, tariff.PowerKW)
+	loadAt := seriesByTime(load)
+
+	var est BillEstimate
+	for _, s := range schedule.ChargeSlots {
+		est.ImportCost += energy * tariff.importPrice(s.Price)
+	}
+	for _, s := range schedule.DischargeSlots {
+		selfUse := 0.0
+		if ts, err := time.Parse(time.RFC3339, s.Timestamp); err == nil {
+			selfUse = min(energy, loadAt[ts])
+		}
+		feedIn := tariff.FeedInRate
+		if feedIn == 0 {
+			feedIn = s.Price
+			if s.ExportPrice != nil {
+				feedIn = *s.ExportPrice
+			}
+		}
+		est.AvoidedCost += selfUse * tariff.importPrice(s.Price)
+		est.ExportRevenue += (energy - selfUse) * feedIn
+	}
+	est.Net = est.AvoidedCost + est.ExportRevenue - est.ImportCost
+	return est
+}
+
+// SelfConsumptionRatio returns the share (0..1) of discharged energy that
+// covers household load rather than being exported. load is as for
+// EstimateBill; powerKW <= 0 means 1 kW. It is 0 when nothing is discharged.
+func SelfConsumptionRatio(schedule ScheduleJSON, load []SlotJSON, powerKW float64) float64 {
+	energy := slotEnergy(schedule, powerKW)
+	loadAt := seriesByTime(load)
+
+	var total, selfUse float64
+	for _, s := range schedule.DischargeSlots {
+		total += energy
+		if ts, err := time.Parse(time.RFC3339, s.Timestamp); err == nil {
+			selfUse += min(energy, loadAt[ts])
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return selfUse / total
+}
+
+// CO2Savings estimates the grams of CO2 avoided by a schedule: discharged
+// energy displaces grid power at its slot's intensity, charged energy adds
+// grid power at its own. intensity carries gCO2/kWh per slot in Price;
+// slots without a value count as zero. powerKW <= 0 means 1 kW.
+func CO2Savings(schedule ScheduleJSON, intensity []SlotJSON, powerKW float64) float64 {
+	energy := slotEnergy(schedule, powerKW)
+	intensityAt := seriesByTime(intensity)
+
+	var saved float64
+	for _, s := range schedule.DischargeSlots {
+		if ts, err := time.Parse(time.RFC3339, s.Timestamp); err == nil {
+			saved += energy * intensityAt[ts]
+		}
+	}
+	for _, s := range schedule.ChargeSlots {
+		if ts, err := time.Parse(time.RFC3339, s.Timestamp); err == nil {
+			saved -= energy * intensityAt[ts]
+		}
+	}
+	return saved
+}
+
+// slotEnergy returns the kWh moved per slot at powerKW (<= 0 means 1 kW) and
+// the schedule's resolution (60 minutes when unknown).
+func slotEnergy(schedule ScheduleJSON, powerKW float64) float64 {
+	if powerKW <= 0 {
+		powerKW = 1
+	}
+	resolution := 60
+	if schedule.ResolutionMinutes != nil && *schedule.ResolutionMinutes > 0 {
+		resolution = *schedule.ResolutionMinutes
+	}
+	return powerKW * float64(resolution) / 60
+}
+
+// seriesByTime indexes a per-slot series (value in Price) by timestamp,
+// summing duplicates and skipping unparsable timestamps.
+func seriesByTime(series []SlotJSON) map[time.Time]float64 {
+	out := make(map[time.Time]float64, len(series))
+	for _, s := range series {
+		if ts, err := time.Parse(time.RFC3339, s.Timestamp); err == nil {
+			out[ts] += s.Price
+		}
+	}
+	return out
+}
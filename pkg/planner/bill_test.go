@@ -0,0 +1,130 @@
+package planner
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// slot returns a SlotJSON at hour h of testDay.
+func slot(h int, v float64) SlotJSON {
+	return SlotJSON{Timestamp: testDay.Add(time.Duration(h) * time.Hour).Format(time.RFC3339), Price: v}
+}
+
+// billSchedule charges at 00:00 (5c) and discharges at 18:00 (20c) and 19:00 (30c).
+var billSchedule = ScheduleJSON{
+	ChargeSlots:    []SlotJSON{slot(0, 5)},
+	DischargeSlots: []SlotJSON{slot(18, 20), slot(19, 30)},
+}
+
+func TestEstimateBill(t *testing.T) {
+	tariff := Tariff{Markup: 1, GridFee: 2, Tax: 1, VATPercent: 25, PowerKW: 2}
+	export := func(s SlotJSON, p float64) SlotJSON {
+		s.ExportPrice = &p
+		return s
+	}
+	tests := []struct {
+		name     string
+		schedule *ScheduleJSON // nil means billSchedule
+		tariff   Tariff
+		load     []SlotJSON
+		want     BillEstimate
+	}{
+		{
+			// 2 kWh per slot; import is (spot+4)*1.25. 18:00 covers 1.5 kWh
+			// of load and exports 0.5 at spot; 19:00 is all self-use.
+			name:   "load and spot feed-in",
+			tariff: tariff,
+			load:   []SlotJSON{slot(18, 1.5), slot(19, 5)},
+			want:   BillEstimate{ImportCost: 22.5, AvoidedCost: 130, ExportRevenue: 10, Net: 117.5},
+		},
+		{
+			name:   "no load, fixed feed-in",
+			tariff: Tariff{Markup: 1, GridFee: 2, Tax: 1, VATPercent: 25, PowerKW: 2, FeedInRate: 8},
+			want:   BillEstimate{ImportCost: 22.5, ExportRevenue: 32, Net: 9.5},
+		},
+		{
+			// Avoided import stays at spot; 18:00 exports its 0.5 kWh at the
+			// slot's 6c export price instead of its 20c spot.
+			name: "export price feed-in",
+			schedule: &ScheduleJSON{
+				ChargeSlots:    []SlotJSON{slot(0, 5)},
+				DischargeSlots: []SlotJSON{export(slot(18, 20), 6), slot(19, 30)},
+			},
+			tariff: tariff,
+			load:   []SlotJSON{slot(18, 1.5), slot(19, 5)},
+			want:   BillEstimate{ImportCost: 22.5, AvoidedCost: 130, ExportRevenue: 3, Net: 110.5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := billSchedule
+			if tt.schedule != nil {
+				schedule = *tt.schedule
+			}
+			got := EstimateBill(schedule, tt.tariff, tt.load)
+			if !closeBill(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func closeBill(a, b BillEstimate) bool {
+	near := func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	return near(a.ImportCost, b.ImportCost) && near(a.AvoidedCost, b.AvoidedCost) &&
+		near(a.ExportRevenue, b.ExportRevenue) && near(a.Net, b.Net)
+}
+
+func TestSelfConsumptionRatio(t *testing.T) {
+	tests := []struct {
+		name    string
+		load    []SlotJSON
+		powerKW float64
+		want    float64
+	}{
+		{"full self-consumption", []SlotJSON{slot(18, 2), slot(19, 3)}, 2, 1},
+		// 18:00 uses 1.5 of 2 kWh, 19:00 none: 1.5 of 4 kWh.
+		{"partial export", []SlotJSON{slot(18, 1.5)}, 2, 0.375},
+		{"no load", nil, 2, 0},
+		{"default power", []SlotJSON{slot(18, 1), slot(19, 0.5)}, 0, 0.75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SelfConsumptionRatio(billSchedule, tt.load, tt.powerKW); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ratio = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := SelfConsumptionRatio(ScheduleJSON{}, []SlotJSON{slot(18, 5)}, 2); got != 0 {
+		t.Errorf("ratio without discharge = %v, want 0", got)
+	}
+}
+
+func TestCO2Savings(t *testing.T) {
+	quarter := 15
+	quarterly := billSchedule
+	quarterly.ResolutionMinutes = &quarter
+
+	tests := []struct {
+		name      string
+		schedule  ScheduleJSON
+		intensity []SlotJSON
+		powerKW   float64
+		want      float64
+	}{
+		// 2 kWh per slot: (300+400)*2 displaced, 100*2 added.
+		{"dirty evening", billSchedule, []SlotJSON{slot(0, 100), slot(18, 300), slot(19, 400)}, 2, 1200},
+		{"dirty night", billSchedule, []SlotJSON{slot(0, 500), slot(18, 100), slot(19, 100)}, 2, -600},
+		{"missing intensity", billSchedule, []SlotJSON{slot(18, 300)}, 2, 600},
+		{"default power", billSchedule, []SlotJSON{slot(0, 100), slot(18, 300)}, 0, 200},
+		{"quarter-hour slots", quarterly, []SlotJSON{slot(18, 400)}, 4, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CO2Savings(tt.schedule, tt.intensity, tt.powerKW); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("saved = %v g, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package planner
+
+import "time"
+
+// utcDayKey returns the UTC delivery day of ts as YYYY-MM-DD.
+func utcDayKey(ts time.Time) string {
+	return ts.UTC().Format("2006-01-02")
+}
+
+// applyChargeBudget keeps charge slots, in the given (cheapest-first) order,
+// while each UTC day's charging cost stays within params.DailyChargeBudget.
+// It is a no-op without a budget or battery model.
+func applyChargeBudget(charge []PriceSlot, params BatteryStrategyParams, resolutionMinutes int) []PriceSlot {
+	_, perSlot, ok := batteryModel(params, resolutionMinutes)
+	if !ok || params.DailyChargeBudget <= 0 {
+		return charge
+	}
+	spent := make(map[string]float64)
+	full := make(map[string]bool)
+	var out []PriceSlot
+	for _, s := range charge {
+		day := utcDayKey(s.Timestamp)
+		if full[day] {
+			continue
+		}
+		cost := s.Price * perSlot / 100 // cents/kWh * kWh -> currency
+		if spent[day]+cost > params.DailyChargeBudget {
+			full[day] = true
+			continue
+		}
+		spent[day] += cost
+		out = append(out, s)
+	}
+	return out
+}
+
+// budgetRemaining returns the unspent charging budget per UTC day touched by
+// future, or nil without a budget or battery model.
+func budgetRemaining(future, charge []PriceSlot, params BatteryStrategyParams, resolutionMinutes int) map[string]float64 {
+	_, perSlot, ok := batteryModel(params, resolutionMinutes)
+	if !ok || params.DailyChargeBudget <= 0 {
+		return nil
+	}
+	out := make(map[string]float64)
+	for _, s := range future {
+		out[utcDayKey(s.Timestamp)] = params.DailyChargeBudget
+	}
+	for _, s := range charge {
+		out[utcDayKey(s.Timestamp)] -= s.Price * perSlot / 100
+	}
+	return out
+}
+
+// chargeFitsBudget reports whether charging in every slot of charge keeps each
+// UTC day within params.DailyChargeBudget. It always holds without a budget or
+// battery model. Steps that add charge slots after applyChargeBudget (min run,
+// end-SoC target) check it so ChargeBudgetRemaining never goes negative.
+func chargeFitsBudget(charge []PriceSlot, params BatteryStrategyParams, resolutionMinutes int) bool {
+	for _, left := range budgetRemaining(charge, charge, params, resolutionMinutes) {
+		if left < -1e-9 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package planner
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDailyChargeBudget(t *testing.T) {
+	// 10 kWh per hourly slot, so the cheapest three slots cost 0.20, 0.30
+	// and 0.40 EUR.
+	full := 100.0
+	tests := []struct {
+		name          string
+		budget        float64
+		tweak         func(*BatteryStrategyParams)
+		charge        []int
+		wantRemaining float64
+	}{
+		{"no budget", 0, nil, []int{0, 1, 2}, 0},
+		{"budget below MaxChargeHours", 0.6, nil, []int{0, 1}, 0.1},
+		{"budget covers all", 1, nil, []int{0, 1, 2}, 0.1},
+		{"budget below the cheapest slot", 0.1, nil, []int{}, 0.1},
+		// Min run may only extend a run within the budget: at 0.4 the
+		// budget keeps 00:00 alone and 00:00+01:00 would cost 0.5.
+		{"min run within budget", 0.5, func(p *BatteryStrategyParams) {
+			p.MaxChargeHours, p.MinRunSlots = 2, 2
+		}, []int{0, 1}, 0},
+		{"min run over budget", 0.4, func(p *BatteryStrategyParams) {
+			p.MaxChargeHours, p.MinRunSlots = 2, 2
+		}, []int{}, 0.4},
+		// The end-SoC target wants every slot but stops at the budget.
+		{"end SoC target", 0.6, func(p *BatteryStrategyParams) {
+			p.MaxChargeHours, p.EndSoCTarget = 1, &full
+		}, []int{0, 1}, 0.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := BatteryStrategyParams{
+				MaxChargeHours: 3, LastPriceCharged: 10, Epsilon: 1,
+				CapacityKWh: 100, PowerKW: 10, DailyChargeBudget: tt.budget,
+			}
+			if tt.tweak != nil {
+				tt.tweak(&params)
+			}
+			s := BuildBatterySchedule(hourly(testDay, 2, 3, 4, 9), params, testDay)
+			if got := slotHours(t, s.ChargeSlots); !equalInts(got, tt.charge) {
+				t.Errorf("charge hours = %v, want %v", got, tt.charge)
+			}
+			if tt.budget == 0 {
+				if s.ChargeBudgetRemaining != nil {
+					t.Errorf("remaining = %v, want none without a budget", s.ChargeBudgetRemaining)
+				}
+				return
+			}
+			if got := s.ChargeBudgetRemaining["2025-01-15"]; math.Abs(got-tt.wantRemaining) > 1e-9 {
+				t.Errorf("remaining = %v, want %v", got, tt.wantRemaining)
+			}
+		})
+	}
+}
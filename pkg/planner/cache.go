@@ -6,9 +6,12 @@ package planner
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	_ "modernc.org/sqlite" // SQLite driver (CGO-free)
@@ -17,6 +20,9 @@ import (
 const (
 	// Allow a bit of slack for DST hours; Nordpool usually publishes 24 slots.
 	minSlotsPerDay = 20
+
+	// backfillDelay spaces out upstream requests when backfilling many days.
+	backfillDelay = 500 * time.Millisecond
 )
 
 // FetchNordpoolPricesCached fetches prices using a SQLite-backed cache.
@@ -28,22 +34,51 @@ func FetchNordpoolPricesCached(ctx context.Context, dbPath, area, market, curren
 
 // FetchNordpoolPricesCachedWithBase is like FetchNordpoolPricesCached but allows overriding the API base URL.
 func FetchNordpoolPricesCachedWithBase(ctx context.Context, dbPath, baseURL, area, market, currency string) ([]PriceSlot, error) {
-	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
-		return nil, fmt.Errorf("creating cache dir: %w", err)
+	return FetchNordpoolPricesCachedWithOptions(ctx, dbPath, area, market, currency, CacheOptions{Fetch: FetchOptions{BaseURL: baseURL}})
+}
+
+// FetchNordpoolPricesCachedWithOptions is like FetchNordpoolPricesCached with full control over caching and fetching.
+func FetchNordpoolPricesCachedWithOptions(ctx context.Context, dbPath, area, market, currency string, opts CacheOptions) ([]PriceSlot, error) {
+	if n := opts.Fetch.FillGapSlots; n > 0 {
+		// Keep interpolated slots out of the cache.
+		opts.Fetch.FillGapSlots = 0
+		prices, err := FetchNordpoolPricesCachedWithOptions(ctx, dbPath, area, market, currency, opts)
+		if err != nil {
+			return nil, err
+		}
+		return FillGaps(prices, n), nil
 	}
 
-	db, err := openCacheDB(ctx, dbPath)
+	db, err := openCacheDir(ctx, dbPath)
 	if err != nil {
+		if opts.FallbackUncached && !opts.ReadOnly && errors.Is(err, ErrCacheUnavailable) {
+			if opts.Fetch.Logger != nil {
+				opts.Fetch.Logger.Warn("price cache unavailable; fetching without cache", "err", err)
+			}
+			return FetchNordpoolPricesWithOptions(ctx, area, market, currency, opts.Fetch)
+		}
 		return nil, err
 	}
 	defer db.Close()
 
-	now := time.Now().UTC()
-	today, tomorrow := getTodayAndTomorrowUTC()
+	if opts.ReadOnly {
+		return loadPrices(ctx, db, area, market, currency, opts.Fetch.now())
+	}
+
+	now := opts.Fetch.now()
+	today, tomorrow := getTodayAndTomorrowUTC(now)
 	dates := []time.Time{today, tomorrow}
 
-	needsRefresh := false
+	needsRefresh := opts.Force
 	for _, day := range dates {
+		if needsRefresh {
+			break
+		}
+		// Tomorrow cannot be fetched before it is published; don't refetch
+		// in a loop while it's merely expected.
+		if day.Equal(tomorrow) && now.Before(publishTime(tomorrow, opts.PublishAt)) {
+			continue
+		}
 		fresh, err := hasFreshDay(ctx, db, area, market, currency, day, now)
 		if err != nil {
 			return nil, err
@@ -55,43 +90,205 @@ func FetchNordpoolPricesCachedWithBase(ctx context.Context, dbPath, baseURL, are
 	}
 
 	if needsRefresh {
-		prices, err := FetchNordpoolPricesWithBase(ctx, baseURL, area, market, currency)
+		// Concurrent misses for the same key share one upstream fetch. The
+		// anchor day and Force are part of the key: a caller asking about a
+		// different day, or insisting on a refetch, must not ride along on a
+		// call that answers a different question.
+		key := fmt.Sprintf("%s|%s|%s|%s|%s|%t", dbPath, area, market, currency, today.Format("2006-01-02"), opts.Force)
+		err := refreshGroup.do(ctx, key, func() error {
+			prices, err := FetchNordpoolPricesWithOptions(ctx, area, market, currency, opts.Fetch)
+			if err != nil {
+				return err
+			}
+			return storePrices(ctx, db, prices, area, market, currency)
+		})
 		if err != nil {
-			return nil, err
+			// Upstream is down: serve what the cache still holds, if anything.
+			// A forced refresh exists to replace that data, so it fails instead.
+			if opts.Force {
+				return nil, err
+			}
+			cached, loadErr := loadPrices(ctx, db, area, market, currency, opts.Fetch.now())
+			if loadErr != nil || len(cached) == 0 {
+				return nil, err
+			}
+			if opts.OnStale != nil {
+				opts.OnStale(err)
+			}
+			return cached, nil
+		}
+	}
+
+	return loadPrices(ctx, db, area, market, currency, opts.Fetch.now())
+}
+
+// refreshGroup coalesces concurrent cache refreshes per key.
+var refreshGroup flightGroup
+
+// flightGroup runs at most one call per key at a time; callers arriving while
+// a call is in flight wait for it and share its error. A waiter whose own
+// context ends first returns that context's error instead, and one whose
+// leader was cancelled retries rather than inherit the leader's context error.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	err  error
+}
+
+func (g *flightGroup) do(ctx context.Context, key string, fn func() error) error {
+	for {
+		g.mu.Lock()
+		if g.calls == nil {
+			g.calls = make(map[string]*flightCall)
+		}
+		c, ok := g.calls[key]
+		if !ok {
+			break
+		}
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		// The leader's own deadline or cancellation says nothing about this
+		// caller's; try again rather than inherit it.
+		if isContextErr(c.err) && ctx.Err() == nil {
+			continue
+		}
+		return c.err
+	}
+	c := &flightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.err = fn()
+
+	// Forget the call before waking waiters so a retrying waiter starts a
+	// fresh one instead of finding this one again.
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+	return c.err
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// BackfillCache fetches every UTC day in [from, to] and stores it in the cache,
+// skipping days that are already complete. Requests are spaced by backfillDelay
+// to stay friendly with the upstream. It returns the number of slots stored.
+func BackfillCache(ctx context.Context, dbPath, area, market, currency string, from, to time.Time) (int, error) {
+	return backfillCache(ctx, dbPath, area, market, currency, from, to, FetchOptions{}, backfillDelay)
+}
+
+// BackfillCacheWithOptions is like BackfillCache with control over the upstream requests.
+func BackfillCacheWithOptions(ctx context.Context, dbPath, area, market, currency string, from, to time.Time, opts FetchOptions) (int, error) {
+	return backfillCache(ctx, dbPath, area, market, currency, from, to, opts, backfillDelay)
+}
+
+func backfillCache(ctx context.Context, dbPath, area, market, currency string, from, to time.Time, opts FetchOptions, delay time.Duration) (int, error) {
+	db, err := openCacheDir(ctx, dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	opts = opts.withDefaults()
+	client := &http.Client{Timeout: 10 * time.Second}
+	now := time.Now().UTC()
+
+	from = from.UTC()
+	to = to.UTC()
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+
+	stored := 0
+	fetched := false
+	for day := start; !day.After(end); day = day.Add(24 * time.Hour) {
+		// Past days never change, so any complete copy counts as fresh.
+		checkAt := now
+		if !day.Add(24 * time.Hour).After(now) {
+			checkAt = day
+		}
+		fresh, err := hasFreshDay(ctx, db, area, market, currency, day, checkAt)
+		if err != nil {
+			return stored, err
+		}
+		if fresh {
+			continue
+		}
+
+		if fetched && delay > 0 {
+			select {
+			case <-ctx.Done():
+				return stored, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		fetched = true
+
+		prices, err := fetchDay(ctx, client, opts, area, market, currency, day)
+		if err != nil {
+			return stored, err
 		}
 		if err := storePrices(ctx, db, prices, area, market, currency); err != nil {
-			return nil, err
+			return stored, err
 		}
+		stored += len(prices)
 	}
+	return stored, nil
+}
 
-	return loadPrices(ctx, db, area, market, currency)
+// openCacheDir creates dbPath's directory, then opens the cache there.
+func openCacheDir(ctx context.Context, dbPath string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return nil, cacheUnavailable(dbPath, fmt.Errorf("creating cache dir: %w", err))
+	}
+	return openCacheDB(ctx, dbPath)
 }
 
+// openCacheDB opens the cache and ensures its schema. Failures wrap
+// ErrCacheUnavailable.
 func openCacheDB(ctx context.Context, dbPath string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("open cache db: %w", err)
+		return nil, cacheUnavailable(dbPath, fmt.Errorf("open cache db: %w", err))
 	}
 	db.SetMaxOpenConns(1)
 
 	if err := applyPragmas(ctx, db); err != nil {
 		db.Close()
-		return nil, err
+		return nil, cacheUnavailable(dbPath, err)
 	}
 	if err := ensureSchema(ctx, db); err != nil {
 		db.Close()
-		return nil, err
+		return nil, cacheUnavailable(dbPath, err)
 	}
 	return db, nil
 }
 
+// cacheUnavailable wraps an open failure with ErrCacheUnavailable and a hint
+// at the uncached fallback.
+func cacheUnavailable(dbPath string, err error) error {
+	return fmt.Errorf("%w at %s (fetch without the cache, e.g. CacheOptions.FallbackUncached): %w", ErrCacheUnavailable, dbPath, err)
+}
+
+// applyPragmas sets busy_timeout first so that switching a new database to
+// WAL waits for concurrent openers instead of failing with SQLITE_BUSY.
 func applyPragmas(ctx context.Context, db *sql.DB) error {
-	if _, err := db.ExecContext(ctx, "PRAGMA journal_mode=WAL;"); err != nil {
-		return fmt.Errorf("set WAL: %w", err)
-	}
 	if _, err := db.ExecContext(ctx, "PRAGMA busy_timeout=5000;"); err != nil {
 		return fmt.Errorf("set busy_timeout: %w", err)
 	}
+	if _, err := db.ExecContext(ctx, "PRAGMA journal_mode=WAL;"); err != nil {
+		return fmt.Errorf("set WAL: %w", err)
+	}
 	return nil
 }
 
@@ -103,16 +300,76 @@ func ensureSchema(ctx context.Context, db *sql.DB) error {
 		currency TEXT NOT NULL,
 		ts DATETIME NOT NULL,
 		price_cents REAL NOT NULL,
+		preliminary INTEGER NOT NULL DEFAULT 0,
+		exchange_rate REAL NOT NULL DEFAULT 0,
+		duration_minutes INTEGER NOT NULL DEFAULT 0,
+		area_average REAL NOT NULL DEFAULT 0,
+		has_area_average INTEGER NOT NULL DEFAULT 0,
 		fetched_at DATETIME NOT NULL,
 		valid_until DATETIME NOT NULL,
 		PRIMARY KEY (area, market, currency, ts)
 	);
 	CREATE INDEX IF NOT EXISTS idx_prices_area_ts ON prices(area, market, currency, ts);
 	CREATE INDEX IF NOT EXISTS idx_prices_valid_until ON prices(area, market, currency, valid_until);
+	CREATE TABLE IF NOT EXISTS params (
+		id TEXT PRIMARY KEY,
+		body TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
 	`
 	if _, err := db.ExecContext(ctx, create); err != nil {
 		return fmt.Errorf("ensure schema: %w", err)
 	}
+	if err := ensureColumn(ctx, db, "prices", "preliminary", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "prices", "exchange_rate", "REAL NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "prices", "duration_minutes", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "prices", "area_average", "REAL NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "prices", "has_area_average", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureColumn adds a column to caches created before it existed.
+func ensureColumn(ctx context.Context, db *sql.DB, table, column, decl string) error {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s);", table))
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			typ       string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("scan %s columns: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s columns: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, decl)); err != nil {
+		return fmt.Errorf("add column %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
@@ -144,10 +401,15 @@ func storePrices(ctx context.Context, db *sql.DB, prices []PriceSlot, area, mark
 	}
 
 	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO prices(area, market, currency, ts, price_cents, fetched_at, valid_until)
-		VALUES(?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO prices(area, market, currency, ts, price_cents, preliminary, exchange_rate, duration_minutes, area_average, has_area_average, fetched_at, valid_until)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(area, market, currency, ts) DO UPDATE SET
 			price_cents = excluded.price_cents,
+			preliminary = excluded.preliminary,
+			exchange_rate = excluded.exchange_rate,
+			duration_minutes = excluded.duration_minutes,
+			area_average = excluded.area_average,
+			has_area_average = excluded.has_area_average,
 			fetched_at = excluded.fetched_at,
 			valid_until = excluded.valid_until`)
 	if err != nil {
@@ -161,7 +423,7 @@ func storePrices(ctx context.Context, db *sql.DB, prices []PriceSlot, area, mark
 		dayStart := time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC)
 		validUntil := dayStart.Add(24 * time.Hour)
 
-		if _, err := stmt.ExecContext(ctx, area, market, currency, ts, p.Price, now, validUntil); err != nil {
+		if _, err := stmt.ExecContext(ctx, area, market, currency, ts, p.Price, p.Preliminary, p.ExchangeRate, p.DurationMinutes, p.AreaAverage, p.HasAreaAverage, now, validUntil); err != nil {
 			tx.Rollback()
 			return fmt.Errorf("insert price %s: %w", ts, err)
 		}
@@ -173,13 +435,15 @@ func storePrices(ctx context.Context, db *sql.DB, prices []PriceSlot, area, mark
 	return nil
 }
 
-func loadPrices(ctx context.Context, db *sql.DB, area, market, currency string) ([]PriceSlot, error) {
-	today, tomorrow := getTodayAndTomorrowUTC()
-	start := today
-	end := tomorrow.Add(24 * time.Hour)
+func loadPrices(ctx context.Context, db *sql.DB, area, market, currency string, now time.Time) ([]PriceSlot, error) {
+	today, tomorrow := getTodayAndTomorrowUTC(now)
+	return loadPriceRange(ctx, db, area, market, currency, today, tomorrow.Add(24*time.Hour))
+}
 
+// loadPriceRange loads the cached slots in [start, end), ordered by time.
+func loadPriceRange(ctx context.Context, db *sql.DB, area, market, currency string, start, end time.Time) ([]PriceSlot, error) {
 	rows, err := db.QueryContext(ctx, `
-		SELECT ts, price_cents FROM prices
+		SELECT ts, price_cents, preliminary, exchange_rate, duration_minutes, area_average, has_area_average FROM prices
 		WHERE area = ? AND market = ? AND currency = ?
 		  AND ts >= ? AND ts < ?
 		ORDER BY ts ASC`, area, market, currency, start, end)
@@ -192,10 +456,17 @@ func loadPrices(ctx context.Context, db *sql.DB, area, market, currency string)
 	for rows.Next() {
 		var ts time.Time
 		var price float64
-		if err := rows.Scan(&ts, &price); err != nil {
+		var preliminary bool
+		var rate float64
+		var duration int
+		var average float64
+		var hasAverage bool
+		if err := rows.Scan(&ts, &price, &preliminary, &rate, &duration, &average, &hasAverage); err != nil {
 			return nil, fmt.Errorf("scan price: %w", err)
 		}
-		slots = append(slots, PriceSlot{Timestamp: ts.UTC(), Price: price})
+		// Rows cached before has_area_average existed only know a nonzero
+		// average was present.
+		slots = append(slots, PriceSlot{Timestamp: ts.UTC(), Price: price, Preliminary: preliminary, ExchangeRate: rate, DurationMinutes: duration, AreaAverage: average, HasAreaAverage: hasAverage || average != 0})
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows error: %w", err)
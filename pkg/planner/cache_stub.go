@@ -4,10 +4,7 @@ package planner
 
 import (
 	"context"
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 )
 
@@ -21,43 +18,51 @@ func FetchNordpoolPricesCachedWithBase(ctx context.Context, baseURL, area, marke
 	return FetchNordpoolPricesWithBase(ctx, baseURL, area, market, currency)
 }
 
+// FetchNordpoolPricesCachedWithOptions has nothing cached in wasm: ReadOnly yields no
+// prices, otherwise it falls back to direct fetch.
+func FetchNordpoolPricesCachedWithOptions(ctx context.Context, _ string, area, market, currency string, opts CacheOptions) ([]PriceSlot, error) {
+	if opts.ReadOnly {
+		return nil, nil
+	}
+	return FetchNordpoolPricesWithOptions(ctx, area, market, currency, opts.Fetch)
+}
+
 // LoadRecentPrices is not supported in wasm (no sqlite); returns an error.
 func LoadRecentPrices(_ context.Context, _, _, _, _ string, _ int) ([]PriceSlot, error) {
 	return nil, fmt.Errorf("LoadRecentPrices not available in wasm build")
 }
 
-// PricesToCSV is available in wasm as a formatting helper.
+// BackfillCache is not supported in wasm (no sqlite); returns an error.
+func BackfillCache(_ context.Context, _, _, _, _ string, _, _ time.Time) (int, error) {
+	return 0, fmt.Errorf("BackfillCache not available in wasm build")
+}
+
+// BackfillCacheWithOptions is not supported in wasm (no sqlite); returns an error.
+func BackfillCacheWithOptions(_ context.Context, _, _, _, _ string, _, _ time.Time, _ FetchOptions) (int, error) {
+	return 0, fmt.Errorf("BackfillCacheWithOptions not available in wasm build")
+}
+
+// CyclesOverRange is not supported in wasm (no sqlite); returns an error.
+func CyclesOverRange(_ context.Context, _ string, _ BatteryStrategyParams, _, _ time.Time) (float64, error) {
+	return 0, fmt.Errorf("CyclesOverRange not available in wasm build")
+}
+
+// PricesToCSV is available in wasm as a formatting helper. Timestamps are UTC.
 func PricesToCSV(prices []PriceSlot) (string, error) {
-	var b strings.Builder
-	w := csv.NewWriter(&b)
-	if err := w.Write([]string{"timestamp", "price_cents"}); err != nil {
-		return "", fmt.Errorf("write header: %w", err)
-	}
-	for _, p := range prices {
-		record := []string{p.Timestamp.Format(time.RFC3339), fmt.Sprintf("%.6f", p.Price)}
-		if err := w.Write(record); err != nil {
-			return "", fmt.Errorf("write record: %w", err)
-		}
-	}
-	w.Flush()
-	if err := w.Error(); err != nil {
-		return "", fmt.Errorf("flush csv: %w", err)
-	}
-	return b.String(), nil
+	return PricesToCSVIn(prices, nil)
 }
 
-// PricesToJSON is available in wasm as a formatting helper.
+// PricesToJSON is available in wasm as a formatting helper. Timestamps are UTC.
 func PricesToJSON(prices []PriceSlot) ([]byte, error) {
-	type row struct {
-		Timestamp string  `json:"timestamp"`
-		Price     float64 `json:"price_cents"`
-	}
-	out := make([]row, 0, len(prices))
-	for _, p := range prices {
-		out = append(out, row{
-			Timestamp: p.Timestamp.Format(time.RFC3339),
-			Price:     p.Price,
-		})
-	}
-	return json.Marshal(out)
+	return PricesToJSONIn(prices, nil)
+}
+
+// SaveParams is not supported in wasm (no sqlite); returns an error.
+func SaveParams(_ context.Context, _, _ string, _ BatteryStrategyParams) error {
+	return fmt.Errorf("SaveParams not available in wasm build")
+}
+
+// LoadParams is not supported in wasm (no sqlite); returns an error.
+func LoadParams(_ context.Context, _, _ string) (BatteryStrategyParams, bool, error) {
+	return BatteryStrategyParams{}, false, fmt.Errorf("LoadParams not available in wasm build")
 }
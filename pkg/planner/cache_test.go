@@ -0,0 +1,577 @@
+//go:build !js && !wasm
+// +build !js,!wasm
+
+package planner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightGroupCoalesces(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fail := errors.New("upstream down")
+
+	fn := func() error {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return fail
+	}
+
+	const callers = 5
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs[0] = g.do(context.Background(), "k", fn)
+	}()
+	<-started
+	for i := 1; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = g.do(context.Background(), "k", fn)
+		}(i)
+	}
+	// Give the waiters time to find the call in flight.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("fn ran %d times, want 1", n)
+	}
+	for i, err := range errs {
+		if !errors.Is(err, fail) {
+			t.Errorf("caller %d: err = %v, want %v", i, err, fail)
+		}
+	}
+}
+
+func TestFlightGroupWaiterContext(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name      string
+		leaderErr error
+		cancel    bool // cancel the waiter's own context while it waits
+		wantErr   error
+		wantCalls int32
+	}{
+		{name: "waiter cancelled", cancel: true, wantErr: context.Canceled, wantCalls: 1},
+		{name: "leader cancelled", leaderErr: context.DeadlineExceeded, wantCalls: 2},
+		{name: "leader failed", leaderErr: boom, wantErr: boom, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g flightGroup
+			var calls atomic.Int32
+			started := make(chan struct{})
+			release := make(chan struct{})
+
+			go g.do(context.Background(), "k", func() error {
+				calls.Add(1)
+				close(started)
+				<-release
+				return tt.leaderErr
+			})
+			<-started
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() {
+				done <- g.do(ctx, "k", func() error {
+					calls.Add(1)
+					return nil
+				})
+			}()
+			time.Sleep(20 * time.Millisecond)
+			if tt.cancel {
+				cancel()
+			} else {
+				close(release)
+			}
+
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(time.Second):
+				t.Fatal("waiter did not return")
+			}
+			if tt.cancel {
+				close(release)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Fatalf("fn ran %d times, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCacheKeepsZeroAreaAverage(t *testing.T) {
+	ctx := context.Background()
+	db, err := openCacheDir(ctx, filepath.Join(t.TempDir(), "prices.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	in := hourly(testDay, 0, 1)
+	in[0].HasAreaAverage = true // a zero average that upstream did send
+	if err := storePrices(ctx, db, in, "SE3", "DayAhead", "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := loadPriceRange(ctx, db, "SE3", "DayAhead", "EUR", testDay, testDay.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("loaded %d slots, want 2", len(out))
+	}
+	if !out[0].HasAreaAverage || out[1].HasAreaAverage {
+		t.Fatalf("HasAreaAverage = %t, %t; want true, false", out[0].HasAreaAverage, out[1].HasAreaAverage)
+	}
+}
+
+func TestCachedFetchFillsGapsWithoutStoringThem(t *testing.T) {
+	const body = `{"deliveryDateCET": "2025-01-15", "currency": "EUR", "multiAreaEntries": [
+		{"deliveryStart": "2025-01-15T00:00:00Z", "deliveryEnd": "2025-01-15T01:00:00Z", "entryPerArea": {"LV": 40}},
+		{"deliveryStart": "2025-01-15T02:00:00Z", "deliveryEnd": "2025-01-15T03:00:00Z", "entryPerArea": {"LV": 60}},
+		{"deliveryStart": "2025-01-15T03:00:00Z", "deliveryEnd": "2025-01-15T04:00:00Z", "entryPerArea": {"LV": 60}}
+	]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "prices.db")
+	opts := CacheOptions{Fetch: FetchOptions{BaseURL: srv.URL, Anchor: testDay.Add(12 * time.Hour), FillGapSlots: 1}}
+	got, err := FetchNordpoolPricesCachedWithOptions(ctx, dbPath, "LV", "DayAhead", "EUR", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || !got[1].Filled || got[1].Price != 5 {
+		t.Fatalf("got %+v, want 01:00 filled at 5", got)
+	}
+
+	db, err := openCacheDir(ctx, dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	stored, err := loadPriceRange(ctx, db, "LV", "DayAhead", "EUR", testDay, testDay.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 3 {
+		t.Fatalf("cache holds %d slots, want the 3 upstream sent", len(stored))
+	}
+}
+
+func TestBackfillCache(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		prices := make([]float64, 24)
+		for i := range prices {
+			prices[i] = float64(50 + i)
+		}
+		io.WriteString(w, dayAheadBody(requestDay(t, r), "LV", prices...))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "prices.db")
+	from, to := testDay, testDay.Add(2*24*time.Hour)
+	opts := FetchOptions{BaseURL: srv.URL}
+
+	n, err := backfillCache(ctx, dbPath, "LV", "DayAhead", "EUR", from, to, opts, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 72 || requests.Load() != 3 {
+		t.Fatalf("stored %d slots in %d requests, want 72 in 3", n, requests.Load())
+	}
+
+	// A second run finds every day complete and fetches nothing.
+	n, err = backfillCache(ctx, dbPath, "LV", "DayAhead", "EUR", from, to, opts, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || requests.Load() != 3 {
+		t.Fatalf("rerun stored %d slots and made %d requests in total, want 0 and 3", n, requests.Load())
+	}
+}
+
+func TestReadOnlyCacheNeverFetches(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		io.WriteString(w, dayAheadBody(requestDay(t, r), "LV", 10))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "prices.db")
+	db, err := openCacheDir(ctx, dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One stale slot: a normal read would refresh.
+	if err := storePrices(ctx, db, hourly(testDay, 7), "LV", "DayAhead", "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	opts := CacheOptions{ReadOnly: true, Fetch: FetchOptions{BaseURL: srv.URL, Anchor: testDay.Add(12 * time.Hour)}}
+	got, err := FetchNordpoolPricesCachedWithOptions(ctx, dbPath, "LV", "DayAhead", "EUR", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 0 {
+		t.Fatalf("read-only cache made %d upstream requests", requests.Load())
+	}
+	if len(got) != 1 || got[0].Price != 7 {
+		t.Fatalf("got %+v, want the one stored slot", got)
+	}
+
+	// An empty area is returned as empty, still without fetching.
+	got, err = FetchNordpoolPricesCachedWithOptions(ctx, dbPath, "EE", "DayAhead", "EUR", opts)
+	if err != nil || len(got) != 0 || requests.Load() != 0 {
+		t.Fatalf("empty read: %d slots, err %v, %d requests", len(got), err, requests.Load())
+	}
+}
+
+func TestCachedFetchCoalescesColdMisses(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Slow enough that every caller finds the refresh in flight.
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, dayAheadBody(requestDay(t, r), "LV", 10, 20, 30))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "prices.db")
+	opts := CacheOptions{Fetch: FetchOptions{BaseURL: srv.URL, Anchor: testDay.Add(12 * time.Hour)}}
+
+	// One call alone gives the number of upstream requests a refresh makes.
+	if _, err := FetchNordpoolPricesCachedWithOptions(ctx, filepath.Join(t.TempDir(), "solo.db"), "LV", "DayAhead", "EUR", opts); err != nil {
+		t.Fatal(err)
+	}
+	perRefresh := requests.Swap(0)
+
+	const callers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = FetchNordpoolPricesCachedWithOptions(ctx, dbPath, "LV", "DayAhead", "EUR", opts)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("caller %d: %v", i, err)
+		}
+	}
+	if n := requests.Load(); n != perRefresh {
+		t.Fatalf("%d callers made %d upstream requests, want %d (one refresh)", callers, n, perRefresh)
+	}
+}
+
+func TestCachedFetchWaitsForPublish(t *testing.T) {
+	// Tomorrow (2025-01-16) publishes at 12:45 CET, 11:45 UTC on testDay.
+	publish := testDay.Add(11*time.Hour + 45*time.Minute)
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantFetch bool
+	}{
+		{"after midnight", testDay.Add(time.Minute), false},
+		{"just before publish", publish.Add(-time.Minute), false},
+		{"just after publish", publish.Add(time.Minute), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				io.WriteString(w, dayAheadBody(requestDay(t, r), "LV", make([]float64, 24)...))
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			dbPath := filepath.Join(t.TempDir(), "prices.db")
+			db, err := openCacheDir(ctx, dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Today is complete; tomorrow is not cached yet.
+			if err := storePrices(ctx, db, hourly(testDay, make([]float64, 24)...), "LV", "DayAhead", "EUR"); err != nil {
+				t.Fatal(err)
+			}
+			db.Close()
+
+			opts := CacheOptions{Fetch: FetchOptions{BaseURL: srv.URL, Anchor: tt.now}}
+			if _, err := FetchNordpoolPricesCachedWithOptions(ctx, dbPath, "LV", "DayAhead", "EUR", opts); err != nil {
+				t.Fatal(err)
+			}
+			if fetched := requests.Load() > 0; fetched != tt.wantFetch {
+				t.Errorf("fetched = %t, want %t", fetched, tt.wantFetch)
+			}
+		})
+	}
+}
+
+func TestCachedFetchFallsBackToStale(t *testing.T) {
+	tests := []struct {
+		name      string
+		warm      bool
+		force     bool
+		wantErr   bool
+		wantStale bool
+	}{
+		{"warm cache", true, false, false, true},
+		{"cold cache", false, false, true, false},
+		{"forced refresh", true, true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "maintenance", http.StatusInternalServerError)
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			dbPath := filepath.Join(t.TempDir(), "prices.db")
+			db, err := openCacheDir(ctx, dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.warm {
+				// Too few slots to be fresh, so the fetch tries upstream.
+				if err := storePrices(ctx, db, hourly(testDay, 7, 8), "LV", "DayAhead", "EUR"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			db.Close()
+
+			var staleErr error
+			opts := CacheOptions{
+				Fetch:   FetchOptions{BaseURL: srv.URL, Anchor: testDay.Add(time.Hour)},
+				Force:   tt.force,
+				OnStale: func(err error) { staleErr = err },
+			}
+			got, err := FetchNordpoolPricesCachedWithOptions(ctx, dbPath, "LV", "DayAhead", "EUR", opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+			}
+			if (staleErr != nil) != tt.wantStale {
+				t.Errorf("OnStale err = %v, want called %t", staleErr, tt.wantStale)
+			}
+			if tt.wantStale && len(got) != 2 {
+				t.Errorf("got %d slots, want the 2 cached ones", len(got))
+			}
+		})
+	}
+}
+
+func TestForceOverwritesPrices(t *testing.T) {
+	var price atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prices := make([]float64, 24)
+		for i := range prices {
+			prices[i] = float64(price.Load())
+		}
+		io.WriteString(w, dayAheadBody(requestDay(t, r), "LV", prices...))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "prices.db")
+	storedCents := func() float64 {
+		t.Helper()
+		db, err := openCacheDir(ctx, dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		var cents float64
+		row := db.QueryRowContext(ctx, `SELECT price_cents FROM prices WHERE area = 'LV' AND ts = ?`, testDay)
+		if err := row.Scan(&cents); err != nil {
+			t.Fatal(err)
+		}
+		return cents
+	}
+
+	steps := []struct {
+		name      string
+		upstream  int32 // EUR/MWh
+		force     bool
+		wantCents float64
+	}{
+		{"cold fetch", 50, false, 5},
+		{"fresh cache ignores the correction", 70, false, 5},
+		{"forced fetch overwrites", 70, true, 7},
+	}
+	for _, st := range steps {
+		price.Store(st.upstream)
+		opts := CacheOptions{Fetch: FetchOptions{BaseURL: srv.URL, Anchor: testDay.Add(13 * time.Hour)}, Force: st.force}
+		if _, err := FetchNordpoolPricesCachedWithOptions(ctx, dbPath, "LV", "DayAhead", "EUR", opts); err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if got := storedCents(); got != st.wantCents {
+			t.Errorf("%s: price_cents = %v, want %v", st.name, got, st.wantCents)
+		}
+	}
+}
+
+func TestCyclesOverRange(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "prices.db")
+	db, err := openCacheDir(ctx, dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Three seeded days with two, one and no hours above the 8c threshold.
+	peaks := [][]float64{{20, 25}, {20}, nil}
+	for i, p := range peaks {
+		prices := make([]float64, 24)
+		for h := range prices {
+			prices[h] = 5
+		}
+		for j, v := range p {
+			prices[18+j] = v
+		}
+		day := testDay.AddDate(0, 0, i)
+		if err := storePrices(ctx, db, hourly(day, prices...), "LV", "DayAhead", "EUR"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	params := BatteryStrategyParams{
+		Area: "LV", Market: "DayAhead", Currency: "EUR",
+		MaxChargeHours: 2, MaxDischargeHours: 2, LastPriceCharged: 8, Epsilon: 0.5,
+	}
+	withModel := params
+	withModel.CapacityKWh, withModel.PowerKW = 10, 5
+
+	tests := []struct {
+		name     string
+		params   BatteryStrategyParams
+		from, to time.Time
+		want     float64
+	}{
+		{"all days", params, testDay, testDay.AddDate(0, 0, 2), 1.5},
+		{"mid-day bounds cover whole days", params, testDay.Add(20 * time.Hour), testDay.Add(30 * time.Hour), 1.5},
+		{"second day", params, testDay.AddDate(0, 0, 1), testDay.AddDate(0, 0, 1), 0.5},
+		{"no discharge day", params, testDay.AddDate(0, 0, 2), testDay.AddDate(0, 0, 2), 0},
+		{"before the cache", params, testDay.AddDate(0, 0, -5), testDay.AddDate(0, 0, -1), 0},
+		{"battery model", withModel, testDay, testDay.AddDate(0, 0, 2), 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CyclesOverRange(ctx, dbPath, tt.params, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("cycles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnopenableCache(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		io.WriteString(w, dayAheadBody(requestDay(t, r), "LV", 10, 20))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	// A regular file where the cache directory should be.
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	paths := []struct{ name, dbPath string }{
+		{"parent is a file", filepath.Join(blocker, "prices.db")},
+		{"path is a directory", dir},
+	}
+
+	tests := []struct {
+		name      string
+		fallback  bool
+		readOnly  bool
+		wantSlots int
+		wantErr   error
+	}{
+		{"no fallback", false, false, 0, ErrCacheUnavailable},
+		{"fallback", true, false, 4, nil},
+		{"fallback ignored when read-only", true, true, 0, ErrCacheUnavailable},
+	}
+	for _, p := range paths {
+		for _, tt := range tests {
+			t.Run(p.name+"/"+tt.name, func(t *testing.T) {
+				requests.Store(0)
+				var logs strings.Builder
+				opts := CacheOptions{
+					ReadOnly:         tt.readOnly,
+					FallbackUncached: tt.fallback,
+					Fetch: FetchOptions{
+						BaseURL: srv.URL,
+						Anchor:  testDay,
+						Logger:  slog.New(slog.NewTextHandler(&logs, nil)),
+					},
+				}
+				got, err := FetchNordpoolPricesCachedWithOptions(context.Background(), p.dbPath, "LV", "DayAhead", "EUR", opts)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if err != nil && !strings.Contains(err.Error(), "FallbackUncached") {
+					t.Errorf("error %q does not suggest the uncached fallback", err)
+				}
+				if len(got) != tt.wantSlots {
+					t.Errorf("got %d slots, want %d", len(got), tt.wantSlots)
+				}
+				if fetched := requests.Load() > 0; fetched != (tt.wantErr == nil) {
+					t.Errorf("upstream requests = %d", requests.Load())
+				}
+				if logged := strings.Contains(logs.String(), "price cache unavailable"); logged != (tt.wantErr == nil) {
+					t.Errorf("fallback warning logged = %t; logs:\n%s", logged, logs.String())
+				}
+			})
+		}
+	}
+}
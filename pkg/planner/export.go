@@ -4,10 +4,7 @@ package planner
 
 import (
 	"context"
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 )
 
@@ -27,7 +24,7 @@ func LoadRecentPrices(ctx context.Context, dbPath, area, market, currency string
 	start := end.Add(-time.Duration(days) * 24 * time.Hour)
 
 	rows, err := db.QueryContext(ctx, `
-		SELECT ts, price_cents FROM prices
+		SELECT ts, price_cents, preliminary, exchange_rate, duration_minutes, area_average, has_area_average FROM prices
 		WHERE area = ? AND market = ? AND currency = ?
 		  AND ts >= ? AND ts <= ?
 		ORDER BY ts ASC`, area, market, currency, start, end)
@@ -40,10 +37,17 @@ func LoadRecentPrices(ctx context.Context, dbPath, area, market, currency string
 	for rows.Next() {
 		var ts time.Time
 		var price float64
-		if err := rows.Scan(&ts, &price); err != nil {
+		var preliminary bool
+		var rate float64
+		var duration int
+		var average float64
+		var hasAverage bool
+		if err := rows.Scan(&ts, &price, &preliminary, &rate, &duration, &average, &hasAverage); err != nil {
 			return nil, fmt.Errorf("scan recent price: %w", err)
 		}
-		slots = append(slots, PriceSlot{Timestamp: ts.UTC(), Price: price})
+		// Rows cached before has_area_average existed only know a nonzero
+		// average was present.
+		slots = append(slots, PriceSlot{Timestamp: ts.UTC(), Price: price, Preliminary: preliminary, ExchangeRate: rate, DurationMinutes: duration, AreaAverage: average, HasAreaAverage: hasAverage || average != 0})
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("recent rows error: %w", err)
@@ -51,38 +55,42 @@ func LoadRecentPrices(ctx context.Context, dbPath, area, market, currency string
 	return slots, nil
 }
 
-// PricesToCSV renders price slots as CSV with header "timestamp,price_cents".
-func PricesToCSV(prices []PriceSlot) (string, error) {
-	var b strings.Builder
-	w := csv.NewWriter(&b)
-	if err := w.Write([]string{"timestamp", "price_cents"}); err != nil {
-		return "", fmt.Errorf("write header: %w", err)
+// CyclesOverRange replays BuildBatterySchedule day by day (UTC) over the
+// cached prices for params' area/market/currency from the day of from through
+// the day of to, and returns the summed equivalent cycles (ScheduleCycles).
+// Each day is planned on its own, as if run at its midnight.
+func CyclesOverRange(ctx context.Context, dbPath string, params BatteryStrategyParams, from, to time.Time) (float64, error) {
+	db, err := openCacheDB(ctx, dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	start, _ := getTodayAndTomorrowUTC(from)
+	_, end := getTodayAndTomorrowUTC(to)
+	prices, err := loadPriceRange(ctx, db, params.Area, params.Market, params.Currency, start, end)
+	if err != nil {
+		return 0, err
 	}
+
+	byDay := make(map[time.Time][]PriceSlot)
 	for _, p := range prices {
-		record := []string{p.Timestamp.Format(time.RFC3339), fmt.Sprintf("%.6f", p.Price)}
-		if err := w.Write(record); err != nil {
-			return "", fmt.Errorf("write record: %w", err)
-		}
+		day, _ := getTodayAndTomorrowUTC(p.Timestamp)
+		byDay[day] = append(byDay[day], p)
 	}
-	w.Flush()
-	if err := w.Error(); err != nil {
-		return "", fmt.Errorf("flush csv: %w", err)
+	var cycles float64
+	for day, slots := range byDay {
+		cycles += ScheduleCycles(BuildBatterySchedule(slots, params, day), params)
 	}
-	return b.String(), nil
+	return cycles, nil
+}
+
+// PricesToCSV renders price slots as CSV with header "timestamp,price_cents". Timestamps are UTC.
+func PricesToCSV(prices []PriceSlot) (string, error) {
+	return PricesToCSVIn(prices, nil)
 }
 
-// PricesToJSON renders price slots as JSON array with timestamp (RFC3339) and price_cents.
+// PricesToJSON renders price slots as JSON array with timestamp (RFC3339) and price_cents. Timestamps are UTC.
 func PricesToJSON(prices []PriceSlot) ([]byte, error) {
-	type row struct {
-		Timestamp string  `json:"timestamp"`
-		Price     float64 `json:"price_cents"`
-	}
-	out := make([]row, 0, len(prices))
-	for _, p := range prices {
-		out = append(out, row{
-			Timestamp: p.Timestamp.Format(time.RFC3339),
-			Price:     p.Price,
-		})
-	}
-	return json.Marshal(out)
+	return PricesToJSONIn(prices, nil)
 }
@@ -0,0 +1,53 @@
+package planner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PricesToCSVIn is PricesToCSV with timestamps rendered in loc (RFC3339, so
+// the offset is kept), e.g. for spreadsheets in local time. Nil means UTC.
+func PricesToCSVIn(prices []PriceSlot, loc *time.Location) (string, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	if err := w.Write([]string{"timestamp", "price_cents"}); err != nil {
+		return "", fmt.Errorf("write header: %w", err)
+	}
+	for _, p := range prices {
+		record := []string{p.Timestamp.In(loc).Format(time.RFC3339), fmt.Sprintf("%.6f", p.Price)}
+		if err := w.Write(record); err != nil {
+			return "", fmt.Errorf("write record: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("flush csv: %w", err)
+	}
+	return b.String(), nil
+}
+
+// PricesToJSONIn is PricesToJSON with timestamps rendered in loc (RFC3339,
+// so the offset is kept). Nil means UTC.
+func PricesToJSONIn(prices []PriceSlot, loc *time.Location) ([]byte, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	type row struct {
+		Timestamp string  `json:"timestamp"`
+		Price     float64 `json:"price_cents"`
+	}
+	out := make([]row, 0, len(prices))
+	for _, p := range prices {
+		out = append(out, row{
+			Timestamp: p.Timestamp.In(loc).Format(time.RFC3339),
+			Price:     p.Price,
+		})
+	}
+	return json.Marshal(out)
+}
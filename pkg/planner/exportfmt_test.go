@@ -0,0 +1,64 @@
+package planner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExportTimezone(t *testing.T) {
+	riga, err := time.LoadLocation("Europe/Riga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	winter := PriceSlot{Timestamp: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC), Price: 12.5}
+	summer := PriceSlot{Timestamp: time.Date(2025, 7, 15, 10, 0, 0, 0, time.UTC), Price: 3.25}
+
+	tests := []struct {
+		name     string
+		slot     PriceSlot
+		loc      *time.Location
+		wantTime string
+		csvPrice string
+		jsPrice  string
+	}{
+		{"nil is UTC", winter, nil, "2025-01-15T10:00:00Z", "12.500000", "12.5"},
+		{"riga winter", winter, riga, "2025-01-15T12:00:00+02:00", "12.500000", "12.5"},
+		{"riga summer", summer, riga, "2025-07-15T13:00:00+03:00", "3.250000", "3.25"},
+		{"input zone ignored", PriceSlot{Timestamp: winter.Timestamp.In(riga), Price: 12.5}, time.UTC, "2025-01-15T10:00:00Z", "12.500000", "12.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices := []PriceSlot{tt.slot}
+			csv, err := PricesToCSVIn(prices, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "timestamp,price_cents\n" + tt.wantTime + "," + tt.csvPrice + "\n"; csv != want {
+				t.Errorf("CSV = %q, want %q", csv, want)
+			}
+			js, err := PricesToJSONIn(prices, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := `[{"timestamp":"` + tt.wantTime + `","price_cents":` + tt.jsPrice + `}]`; string(js) != want {
+				t.Errorf("JSON = %s, want %s", js, want)
+			}
+		})
+	}
+
+	// The plain variants stay UTC.
+	csv, err := PricesToCSV([]PriceSlot{winter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "timestamp,price_cents\n2025-01-15T10:00:00Z,12.500000\n"; csv != want {
+		t.Errorf("PricesToCSV = %q, want %q", csv, want)
+	}
+	js, err := PricesToJSON([]PriceSlot{winter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"timestamp":"2025-01-15T10:00:00Z","price_cents":12.5}]`; string(js) != want {
+		t.Errorf("PricesToJSON = %s, want %s", js, want)
+	}
+}
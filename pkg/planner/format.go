@@ -0,0 +1,164 @@
+package planner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScheduleToCSV renders the selected slots as CSV with header
+// "timestamp,action,price_cents", ordered by time.
+func ScheduleToCSV(schedule ScheduleJSON) (string, error) {
+	type row struct {
+		ts     time.Time
+		raw    string
+		action string
+		price  float64
+	}
+	var rows []row
+	add := func(action string, slots []SlotJSON) error {
+		for _, s := range slots {
+			ts, err := time.Parse(time.RFC3339, s.Timestamp)
+			if err != nil {
+				return fmt.Errorf("parse %s slot %q: %w", action, s.Timestamp, err)
+			}
+			rows = append(rows, row{ts: ts, raw: s.Timestamp, action: action, price: s.Price})
+		}
+		return nil
+	}
+	if err := add("charge", schedule.ChargeSlots); err != nil {
+		return "", err
+	}
+	if err := add("discharge", schedule.DischargeSlots); err != nil {
+		return "", err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].ts.Before(rows[j].ts) })
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	if err := w.Write([]string{"timestamp", "action", "price_cents"}); err != nil {
+		return "", fmt.Errorf("write header: %w", err)
+	}
+	for _, r := range rows {
+		if err := w.Write([]string{r.raw, r.action, fmt.Sprintf("%.6f", r.price)}); err != nil {
+			return "", fmt.Errorf("write record: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("flush csv: %w", err)
+	}
+	return b.String(), nil
+}
+
+// compactScheduleJSON mirrors ScheduleJSON with every field omitempty; the
+// conversion in MarshalCompact stops compiling if the two drift apart.
+type compactScheduleJSON struct {
+	Area                  string             `json:"area,omitempty"`
+	LastPriceCharged      float64            `json:"last_price_charged"`
+	Epsilon               float64            `json:"epsilon"`
+	ResolutionMinutes     *int               `json:"resolution_minutes,omitempty"`
+	ChargeSlots           []SlotJSON         `json:"charge_slots,omitempty"`
+	DischargeSlots        []SlotJSON         `json:"discharge_slots,omitempty"`
+	ChargeIntervals       []IntervalJSON     `json:"charge_intervals,omitempty"`
+	DischargeIntervals    []IntervalJSON     `json:"discharge_intervals,omitempty"`
+	Preliminary           bool               `json:"preliminary,omitempty"`
+	EndSoC                *float64           `json:"end_soc,omitempty"`
+	SecondaryCurrency     string             `json:"secondary_currency,omitempty"`
+	SecondaryRate         float64            `json:"secondary_rate,omitempty"`
+	ChargeBudgetRemaining map[string]float64 `json:"charge_budget_remaining,omitempty"`
+	AreaAverages          map[string]float64 `json:"area_averages,omitempty"`
+	Warnings              []string           `json:"warnings,omitempty"`
+}
+
+// MarshalCompact encodes schedule like json.Marshal but drops null fields,
+// empty arrays and a false preliminary flag, for constrained clients. The
+// thresholds are always kept. Clients relying on field presence should use
+// the regular encoding.
+func MarshalCompact(schedule ScheduleJSON) ([]byte, error) {
+	return json.Marshal(compactScheduleJSON(schedule))
+}
+
+// lineProtocolEscaper escapes tag keys/values for InfluxDB line protocol.
+var lineProtocolEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// ScheduleToLineProtocol renders the selected slots as InfluxDB line protocol,
+// one line per slot ordered by time:
+//
+//	<measurement>,area=<area>,action=<charge|discharge> price=<cents> <unix ns>
+//
+// Slots with unparsable timestamps are skipped.
+func ScheduleToLineProtocol(schedule ScheduleJSON, measurement string) string {
+	type point struct {
+		ts     time.Time
+		action string
+		price  float64
+	}
+	var points []point
+	add := func(action string, slots []SlotJSON) {
+		for _, s := range slots {
+			ts, err := time.Parse(time.RFC3339, s.Timestamp)
+			if err != nil {
+				continue
+			}
+			points = append(points, point{ts: ts, action: action, price: s.Price})
+		}
+	}
+	add("charge", schedule.ChargeSlots)
+	add("discharge", schedule.DischargeSlots)
+	sort.SliceStable(points, func(i, j int) bool { return points[i].ts.Before(points[j].ts) })
+
+	name := strings.NewReplacer(",", `\,`, " ", `\ `).Replace(measurement)
+	var b strings.Builder
+	for _, p := range points {
+		b.WriteString(name)
+		if schedule.Area != "" {
+			fmt.Fprintf(&b, ",area=%s", lineProtocolEscaper.Replace(schedule.Area))
+		}
+		fmt.Fprintf(&b, ",action=%s price=%s %d\n", p.action, strconv.FormatFloat(p.price, 'f', -1, 64), p.ts.UnixNano())
+	}
+	return b.String()
+}
+
+// ScheduleToBitmap renders the horizon as one character per future slot in
+// time order: 'C' for charge, 'D' for discharge and '.' for idle. A full day
+// of hourly prices yields 24 characters, quarter-hourly 96.
+func ScheduleToBitmap(schedule ScheduleJSON, prices []PriceSlot, now time.Time) string {
+	return ScheduleToBitmapSymbols(schedule, prices, now, 'C', 'D', '.')
+}
+
+// ScheduleToBitmapSymbols is ScheduleToBitmap with custom charge, discharge
+// and idle symbols.
+func ScheduleToBitmapSymbols(schedule ScheduleJSON, prices []PriceSlot, now time.Time, charge, discharge, idle rune) string {
+	actions := make(map[time.Time]rune, len(schedule.ChargeSlots)+len(schedule.DischargeSlots))
+	mark := func(c rune, slots []SlotJSON) {
+		for _, s := range slots {
+			if ts, err := time.Parse(time.RFC3339, s.Timestamp); err == nil {
+				actions[ts.UTC()] = c
+			}
+		}
+	}
+	mark(charge, schedule.ChargeSlots)
+	mark(discharge, schedule.DischargeSlots)
+
+	var future []PriceSlot
+	for _, p := range prices {
+		if !p.Timestamp.Before(now) {
+			future = append(future, p)
+		}
+	}
+	sort.SliceStable(future, func(i, j int) bool { return future[i].Timestamp.Before(future[j].Timestamp) })
+
+	b := make([]rune, len(future))
+	for i, p := range future {
+		b[i] = idle
+		if c, ok := actions[p.Timestamp.UTC()]; ok {
+			b[i] = c
+		}
+	}
+	return string(b)
+}
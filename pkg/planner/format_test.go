@@ -0,0 +1,138 @@
+package planner
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestScheduleToLineProtocol(t *testing.T) {
+	schedule := ScheduleJSON{
+		Area:           "LV",
+		ChargeSlots:    []SlotJSON{{Timestamp: "2025-01-15T02:00:00Z", Price: 1.25}},
+		DischargeSlots: []SlotJSON{{Timestamp: "2025-01-15T18:00:00Z", Price: 18}},
+	}
+	tests := []struct {
+		name        string
+		schedule    ScheduleJSON
+		measurement string
+		want        string
+	}{
+		{
+			name:        "two slots",
+			schedule:    schedule,
+			measurement: "battery_plan",
+			want: "battery_plan,area=LV,action=charge price=1.25 1736906400000000000\n" +
+				"battery_plan,area=LV,action=discharge price=18 1736964000000000000\n",
+		},
+		{
+			name:        "escaped measurement and tag",
+			schedule:    ScheduleJSON{Area: "SE 3", ChargeSlots: schedule.ChargeSlots},
+			measurement: "plan,v2 x",
+			want:        "plan\\,v2\\ x,area=SE\\ 3,action=charge price=1.25 1736906400000000000\n",
+		},
+		{
+			name:        "no area tag",
+			schedule:    ScheduleJSON{DischargeSlots: schedule.DischargeSlots},
+			measurement: "plan",
+			want:        "plan,action=discharge price=18 1736964000000000000\n",
+		},
+		{
+			name:        "empty",
+			measurement: "plan",
+			want:        "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScheduleToLineProtocol(tt.schedule, tt.measurement); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleToBitmap(t *testing.T) {
+	day := []float64{
+		4.2, 3.8, 3.1, 2.9, 3.0, 4.5, 7.8, 11.2,
+		12.5, 10.1, 8.4, 7.9, 7.2, 6.8, 7.5, 9.3,
+		13.6, 16.8, 18.2, 15.4, 11.9, 8.7, 6.1, 5.0,
+	}
+	prices := hourly(testDay, day...)
+	params := BatteryStrategyParams{MaxChargeHours: 3, MaxDischargeHours: 3, LastPriceCharged: 8, Epsilon: 0.5}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{"full day", testDay, "..CCC............DDD...."},
+		// From noon the cheapest hours left are 13:00, 22:00 and 23:00.
+		{"from noon", testDay.Add(12 * time.Hour), ".C...DDD..CC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := BuildBatterySchedule(prices, params, tt.now)
+			if got := ScheduleToBitmap(schedule, prices, tt.now); got != tt.want {
+				t.Errorf("bitmap = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("custom symbols", func(t *testing.T) {
+		schedule := BuildBatterySchedule(prices, params, testDay)
+		if got, want := ScheduleToBitmapSymbols(schedule, prices, testDay, '+', '-', ' '), "  +++            ---    "; got != want {
+			t.Errorf("bitmap = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestMarshalCompact(t *testing.T) {
+	res := 60
+	tests := []struct {
+		name     string
+		schedule ScheduleJSON
+		full     string
+		compact  string
+	}{
+		{
+			name:     "empty",
+			schedule: ScheduleJSON{Area: "LV", ChargeSlots: []SlotJSON{}},
+			full: `{"area":"LV","last_price_charged":0,"epsilon":0,"resolution_minutes":null,` +
+				`"charge_slots":[],"discharge_slots":null,"charge_intervals":null,` +
+				`"discharge_intervals":null,"preliminary":false,"end_soc":null}`,
+			compact: `{"area":"LV","last_price_charged":0,"epsilon":0}`,
+		},
+		{
+			name: "populated",
+			schedule: ScheduleJSON{
+				Area: "LV", LastPriceCharged: 8, Epsilon: 0.5, ResolutionMinutes: &res,
+				DischargeSlots: []SlotJSON{{Timestamp: "2025-01-15T18:00:00Z", Price: 18}},
+				Preliminary:    true,
+			},
+			full: `{"area":"LV","last_price_charged":8,"epsilon":0.5,"resolution_minutes":60,` +
+				`"charge_slots":null,"discharge_slots":[{"timestamp":"2025-01-15T18:00:00Z","price":18}],` +
+				`"charge_intervals":null,"discharge_intervals":null,"preliminary":true,"end_soc":null}`,
+			compact: `{"area":"LV","last_price_charged":8,"epsilon":0.5,"resolution_minutes":60,` +
+				`"discharge_slots":[{"timestamp":"2025-01-15T18:00:00Z","price":18}],"preliminary":true}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			full, err := json.Marshal(tt.schedule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(full) != tt.full {
+				t.Errorf("full:\n got %s\nwant %s", full, tt.full)
+			}
+			compact, err := MarshalCompact(tt.schedule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(compact) != tt.compact {
+				t.Errorf("compact:\n got %s\nwant %s", compact, tt.compact)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const icsTimeLayout = "20060102T150405Z"
+
+// ScheduleToICS renders charge/discharge intervals as an iCalendar document
+// with one VEVENT per interval. Times are emitted in UTC.
+func ScheduleToICS(schedule ScheduleJSON) (string, error) {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\n")
+	b.WriteString("VERSION:2.0\r\n")
+	b.WriteString("PRODID:-//gordpool//battery schedule//EN\r\n")
+	b.WriteString("CALSCALE:GREGORIAN\r\n")
+
+	stamp := time.Now().UTC().Format(icsTimeLayout)
+	write := func(kind string, intervals []IntervalJSON) error {
+		for _, iv := range intervals {
+			start, err := time.Parse(time.RFC3339, iv.Start)
+			if err != nil {
+				return fmt.Errorf("parse %s start %q: %w", kind, iv.Start, err)
+			}
+			end, err := time.Parse(time.RFC3339, iv.End)
+			if err != nil {
+				return fmt.Errorf("parse %s end %q: %w", kind, iv.End, err)
+			}
+			startUTC := start.UTC().Format(icsTimeLayout)
+			b.WriteString("BEGIN:VEVENT\r\n")
+			fmt.Fprintf(&b, "UID:%s-%s-%s@gordpool\r\n", kind, startUTC, schedule.Area)
+			fmt.Fprintf(&b, "DTSTAMP:%s\r\n", stamp)
+			fmt.Fprintf(&b, "DTSTART:%s\r\n", startUTC)
+			fmt.Fprintf(&b, "DTEND:%s\r\n", end.UTC().Format(icsTimeLayout))
+			fmt.Fprintf(&b, "SUMMARY:Battery: %s @ %.1fc\r\n", kind, iv.AvgPrice)
+			b.WriteString("END:VEVENT\r\n")
+		}
+		return nil
+	}
+	if err := write("charge", schedule.ChargeIntervals); err != nil {
+		return "", err
+	}
+	if err := write("discharge", schedule.DischargeIntervals); err != nil {
+		return "", err
+	}
+
+	b.WriteString("END:VCALENDAR\r\n")
+	return b.String(), nil
+}
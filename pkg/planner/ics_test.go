@@ -0,0 +1,46 @@
+package planner
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestScheduleToICS(t *testing.T) {
+	schedule := ScheduleJSON{
+		Area: "LV",
+		ChargeIntervals: []IntervalJSON{
+			{Start: "2025-01-15T01:00:00+01:00", End: "2025-01-15T03:00:00+01:00", AvgPrice: 3.1},
+			{Start: "2025-01-15T13:00:00Z", End: "2025-01-15T14:00:00Z", AvgPrice: 5},
+		},
+		DischargeIntervals: []IntervalJSON{
+			{Start: "2025-01-15T17:00:00Z", End: "2025-01-15T19:00:00Z", AvgPrice: 18.25},
+		},
+	}
+	out, err := ScheduleToICS(schedule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out, "BEGIN:VEVENT\r\n"); n != 3 {
+		t.Errorf("got %d VEVENTs, want 3", n)
+	}
+	for _, line := range []string{
+		"DTSTART:20250115T000000Z\r\n", // +01:00 converted to UTC
+		"DTEND:20250115T020000Z\r\n",
+		"SUMMARY:Battery: discharge @ 18.2c\r\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("missing %q", line)
+		}
+	}
+	utc := regexp.MustCompile(`^(DTSTART|DTEND|DTSTAMP):\d{8}T\d{6}Z$`)
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if strings.HasPrefix(line, "DT") && !utc.MatchString(line) {
+			t.Errorf("%q is not a UTC timestamp", line)
+		}
+	}
+
+	if _, err := ScheduleToICS(ScheduleJSON{ChargeIntervals: []IntervalJSON{{Start: "soon", End: "later"}}}); err == nil {
+		t.Error("bad interval timestamps should fail")
+	}
+}
@@ -0,0 +1,65 @@
+//go:build !js
+
+package planner
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SaveParams stores params as JSON under an opaque id in the cache DB,
+// replacing any previous value.
+func SaveParams(ctx context.Context, dbPath, id string, params BatteryStrategyParams) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("encode params: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return fmt.Errorf("creating cache dir: %w", err)
+	}
+	db, err := openCacheDB(ctx, dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO params(id, body, updated_at) VALUES(?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET body = excluded.body, updated_at = excluded.updated_at`,
+		id, string(body), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("save params %s: %w", id, err)
+	}
+	return nil
+}
+
+// LoadParams returns the params stored under id; ok is false when none exist.
+func LoadParams(ctx context.Context, dbPath, id string) (params BatteryStrategyParams, ok bool, err error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return params, false, fmt.Errorf("creating cache dir: %w", err)
+	}
+	db, err := openCacheDB(ctx, dbPath)
+	if err != nil {
+		return params, false, err
+	}
+	defer db.Close()
+
+	var body string
+	err = db.QueryRowContext(ctx, `SELECT body FROM params WHERE id = ?`, id).Scan(&body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return params, false, nil
+	}
+	if err != nil {
+		return params, false, fmt.Errorf("load params %s: %w", id, err)
+	}
+	if err := json.Unmarshal([]byte(body), &params); err != nil {
+		return params, false, fmt.Errorf("decode params %s: %w", id, err)
+	}
+	return params, true, nil
+}
@@ -0,0 +1,36 @@
+//go:build parquet && !js
+
+package planner
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRow is one PricesToParquet row: the slot start and its price in
+// cents/kWh.
+type parquetRow struct {
+	Timestamp time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	Price     float64   `parquet:"price_cents"`
+}
+
+// PricesToParquet writes price slots as a Parquet file with columns
+// timestamp (UTC, millisecond precision) and price_cents. Only built with
+// -tags parquet.
+func PricesToParquet(w io.Writer, prices []PriceSlot) error {
+	rows := make([]parquetRow, 0, len(prices))
+	for _, p := range prices {
+		rows = append(rows, parquetRow{Timestamp: p.Timestamp.UTC(), Price: p.Price})
+	}
+	pw := parquet.NewGenericWriter[parquetRow](w)
+	if _, err := pw.Write(rows); err != nil {
+		return fmt.Errorf("write parquet rows: %w", err)
+	}
+	if err := pw.Close(); err != nil {
+		return fmt.Errorf("close parquet writer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !parquet || js
+
+package planner
+
+import (
+	"fmt"
+	"io"
+)
+
+// PricesToParquet needs a native build with -tags parquet.
+func PricesToParquet(_ io.Writer, _ []PriceSlot) error {
+	return fmt.Errorf("PricesToParquet not available: build with -tags parquet")
+}
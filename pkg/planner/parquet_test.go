@@ -0,0 +1,50 @@
+//go:build parquet && !js
+
+package planner
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestPricesToParquet(t *testing.T) {
+	riga, err := time.LoadLocation("Europe/Riga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		prices []PriceSlot
+	}{
+		{"empty", nil},
+		{"hourly", hourly(testDay, 4.25, -0.5, 0, 18.125)},
+		// Non-UTC input is written as the same instant in UTC.
+		{"local zone", hourly(testDay.In(riga), 7, 8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := PricesToParquet(&buf, tt.prices); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := parquet.Read[parquetRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(tt.prices) {
+				t.Fatalf("read %d rows, want %d", len(rows), len(tt.prices))
+			}
+			for i, p := range tt.prices {
+				if !rows[i].Timestamp.Equal(p.Timestamp) || rows[i].Price != p.Price {
+					t.Errorf("row %d = %v %v, want %v %v", i, rows[i].Timestamp, rows[i].Price, p.Timestamp, p.Price)
+				}
+				if rows[i].Timestamp.Location() != time.UTC {
+					t.Errorf("row %d timestamp in %v, want UTC", i, rows[i].Timestamp.Location())
+				}
+			}
+		})
+	}
+}
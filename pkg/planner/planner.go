@@ -3,11 +3,16 @@ package planner
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
 	"net/http"
+	"os"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,16 +26,105 @@ type BatteryStrategyParams struct {
 	Epsilon           float64 // cents/kWh
 	Market            string
 	Currency          string
+
+	// HoursRounding maps fractional Max*Hours to slots; the zero value rounds up.
+	HoursRounding SlotRounding
+
+	// MinRunSlots drops or extends charge/discharge runs shorter than this many
+	// consecutive slots to avoid inverter flapping; 0 or 1 disables.
+	MinRunSlots int
+
+	// MinChargeDischargeGapSlots drops a discharge slot starting within this
+	// many slots after a kept charge slot, and a charge slot within as many
+	// after a kept discharge slot, so the battery can settle; 0 disables.
+	MinChargeDischargeGapSlots int
+
+	// RoundToDecimals rounds prices to this many decimals before candidate
+	// selection so picks match billed prices; output keeps full precision.
+	// Nil compares raw prices.
+	RoundToDecimals *int
+
+	// SplitAtDayBoundary splits charge/discharge intervals at UTC midnight
+	// (e.g. for per-day billing); by default runs stay contiguous.
+	SplitAtDayBoundary bool
+
+	// PreferEarly adds this many cents/kWh per hour after now when ranking
+	// charge candidates, so among similarly priced slots earlier ones win and
+	// the battery fills sooner. 0 ranks by price alone.
+	PreferEarly float64
+
+	// DailyChargeBudget caps charging spend per UTC day, in currency units
+	// (e.g. EUR); cheapest slots are kept until the next would exceed it.
+	// Needs the battery model below; 0 disables.
+	DailyChargeBudget float64
+
+	// PreferEveningDischarge breaks discharge price ties in favour of slots
+	// in the evening window (hours of day in market time, start inclusive,
+	// end exclusive; may wrap past midnight). EveningMargin (cents/kWh)
+	// widens that: an evening slot outranks any slot at most that much
+	// pricier. Zero bounds mean 17–21.
+	PreferEveningDischarge bool
+	EveningStartHour       int
+	EveningEndHour         int
+	EveningMargin          float64
+
+	// ExportPrices, when set, is the feed-in price series (cents/kWh) that
+	// discharge candidates are judged and ranked by, while the spot prices
+	// keep driving charging. Discharge slots keep the spot price in Price and
+	// report the export price in ExportPrice. Slots missing from it fall back
+	// to spot. Not persisted by SaveParams.
+	ExportPrices []PriceSlot `json:"-"`
+
+	// SmoothingAlpha, in (0, 1), exponentially smooths the spot and export
+	// series before candidate selection (smaller is smoother) so single-slot
+	// spikes matter less; output slots keep their raw prices. 0 disables.
+	SmoothingAlpha float64
+
+	// ForecastOverride replaces the spot price (cents/kWh) of matching slots
+	// during candidate selection, e.g. with a better short-term forecast;
+	// output slots keep the day-ahead price. Applied after smoothing. Not
+	// persisted by SaveParams.
+	ForecastOverride map[time.Time]float64 `json:"-"`
+
+	// TomorrowOnly plans only the slots delivered on the next calendar day in
+	// market time (see TomorrowSlots), ignoring the rest of today.
+	TomorrowOnly bool
+
+	// Optional battery model; SoC modeling is disabled when CapacityKWh is 0.
+	CapacityKWh       float64
+	PowerKW           float64  // charge/discharge power; defaults to CapacityKWh (1C)
+	InitialSoCPercent float64  // state of charge at the start of the horizon
+	EndSoCTarget      *float64 // percent; when set, slots are added/dropped to end here
+	ReserveSoCPercent float64  // never discharge below this SoC (backup reserve)
+
+	// SecondaryCurrency adds a converted price to each output slot. The rate is
+	// SecondaryRate (units per primary unit) or, when 0, the response exchangeRate.
+	SecondaryCurrency string
+	SecondaryRate     float64
 }
 
 type PriceSlot struct {
-	Timestamp time.Time
-	Price     float64 // cents/kWh
+	Timestamp    time.Time
+	Price        float64 // cents/kWh
+	Preliminary  bool    // upstream has not marked the price as final yet
+	ExchangeRate float64 // response exchangeRate (local currency per EUR); 0 if unknown
+	Filled       bool    // synthesized by FillGaps; never selected for charge/discharge
+	// DurationMinutes is the delivery window length (deliveryEnd - deliveryStart);
+	// 0 if unknown, in which case resolution is inferred from timestamps.
+	DurationMinutes int
+	// AreaAverage is the response's daily average for the area (areaAverages),
+	// in cents/kWh. HasAreaAverage reports whether upstream sent one, since
+	// 0 is a real average on a day of zero prices.
+	AreaAverage    float64
+	HasAreaAverage bool
 }
 
 type SlotJSON struct {
-	Timestamp string  `json:"timestamp"`
-	Price     float64 `json:"price"`
+	Timestamp      string   `json:"timestamp"`
+	Price          float64  `json:"price"`
+	SecondaryPrice *float64 `json:"secondary_price,omitempty"`
+	PowerFraction  *float64 `json:"power_fraction,omitempty"` // share of full power actually used, when a battery model is set
+	ExportPrice    *float64 `json:"export_price,omitempty"`   // feed-in price of a discharge slot, when ExportPrices covers it
 }
 
 type IntervalJSON struct {
@@ -48,6 +142,22 @@ type ScheduleJSON struct {
 	DischargeSlots     []SlotJSON     `json:"discharge_slots"`
 	ChargeIntervals    []IntervalJSON `json:"charge_intervals"`
 	DischargeIntervals []IntervalJSON `json:"discharge_intervals"`
+	Preliminary        bool           `json:"preliminary"`
+	EndSoC             *float64       `json:"end_soc"` // percent, when a battery model is set
+	SecondaryCurrency  string         `json:"secondary_currency,omitempty"`
+	SecondaryRate      float64        `json:"secondary_rate,omitempty"` // override; 0 uses each slot's rate
+
+	// ChargeBudgetRemaining is the unspent DailyChargeBudget per UTC day
+	// (YYYY-MM-DD), when a budget is set.
+	ChargeBudgetRemaining map[string]float64 `json:"charge_budget_remaining,omitempty"`
+
+	// AreaAverages is upstream's daily average price per delivery day
+	// (YYYY-MM-DD, market time), in cents/kWh, when the response carried one.
+	AreaAverages map[string]float64 `json:"area_averages,omitempty"`
+
+	// Warnings explains an empty horizon, a slot count clamped to a short
+	// horizon, or why no charge or no discharge slots were planned.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 type dayAheadResponse struct {
@@ -58,7 +168,11 @@ type dayAheadResponse struct {
 	Market          string   `json:"market"`
 	Currency        string   `json:"currency"`
 	ExchangeRate    float64  `json:"exchangeRate"`
-	AreaAverages    []struct {
+	AreaStates      []struct {
+		State string   `json:"state"`
+		Areas []string `json:"areas"`
+	} `json:"areaStates"`
+	AreaAverages []struct {
 		AreaCode string  `json:"areaCode"`
 		Price    float64 `json:"price"`
 	} `json:"areaAverages"`
@@ -69,98 +183,460 @@ type dayAheadResponse struct {
 	} `json:"multiAreaEntries"`
 }
 
-// getTodayAndTomorrowUTC returns midnight UTC for today and tomorrow.
-func getTodayAndTomorrowUTC() (time.Time, time.Time) {
-	now := time.Now().UTC()
+// areaAverage returns the area's daily average in EUR/MWh from areaAverages.
+func (r dayAheadResponse) areaAverage(area string) (float64, bool) {
+	for _, a := range r.AreaAverages {
+		if a.AreaCode == area {
+			return a.Price, true
+		}
+	}
+	return 0, false
+}
+
+// isPreliminary reports whether the response marks the area's prices as
+// anything other than final. A missing state is treated as final.
+func (r dayAheadResponse) isPreliminary(area string) bool {
+	for _, st := range r.AreaStates {
+		for _, a := range st.Areas {
+			if a == area {
+				return st.State != "" && !strings.EqualFold(st.State, "Final")
+			}
+		}
+	}
+	return false
+}
+
+// getTodayAndTomorrowUTC returns midnight UTC for the day containing now and
+// the day after.
+func getTodayAndTomorrowUTC(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 	tomorrow := today.Add(24 * time.Hour)
 	return today, tomorrow
 }
 
+const defaultBaseURL = "https://dataportal-api.nordpoolgroup.com/api/DayAheadPrices"
+
+// FetchOptions tunes how the DayAheadPrices endpoint is queried, so the fetcher
+// can target compatible mirrors. Zero values fall back to the Nordpool defaults.
+type FetchOptions struct {
+	BaseURL string
+
+	DateParam     string // default "date"
+	MarketParam   string // default "market"
+	AreaParam     string // default "deliveryArea"
+	CurrencyParam string // default "currency"
+	DateLayout    string // default "2006-01-02"
+
+	// APIKey, when set, is sent in APIKeyHeader (default "Authorization",
+	// which gets a "Bearer " prefix; custom headers carry the raw key).
+	APIKey       string
+	APIKeyHeader string
+
+	// Anchor replaces time.Now when choosing the "today" and "tomorrow"
+	// delivery dates (and, for the cache, judging freshness), e.g. to rebuild
+	// a past plan. Zero means now.
+	Anchor time.Time
+
+	// OnMissingArea is called when a day's response has entries without a
+	// price for the requested area; those entries are skipped. It tells a
+	// partial-area problem apart from an empty response; when every entry has
+	// an empty entryPerArea (stats.EmptyAreaMaps == stats.Entries) the day
+	// yields no slots (see ErrNoAreaPrices). May be called concurrently; may
+	// be nil.
+	OnMissingArea func(day time.Time, stats ParseStats)
+
+	// TomorrowRetries refetches tomorrow up to this many times when upstream
+	// answers successfully but with no slots, for runs around publish time
+	// when the data is about to land. Errors are not retried. The wait
+	// between attempts is TomorrowRetryDelay (default 5s).
+	TomorrowRetries    int
+	TomorrowRetryDelay time.Duration
+
+	// FillGapSlots, when > 0, interpolates holes of up to that many missing
+	// slots in the result (see FillGaps). The cached fetcher stores what
+	// upstream sent and fills only what it returns.
+	FillGapSlots int
+
+	// Logger receives a warning per fetched day whose response had entries
+	// skipped (bad timestamps, missing area price), with counts by reason.
+	// Nil discards them.
+	Logger *slog.Logger
+}
+
+// now returns the anchor, or the current time when none is set.
+func (o FetchOptions) now() time.Time {
+	if o.Anchor.IsZero() {
+		return time.Now().UTC()
+	}
+	return o.Anchor.UTC()
+}
+
+// FetchOptionsFromEnv returns options carrying credentials from
+// NORDPOOL_API_KEY and NORDPOOL_API_KEY_HEADER, if set.
+func FetchOptionsFromEnv() FetchOptions {
+	return FetchOptions{
+		APIKey:       os.Getenv("NORDPOOL_API_KEY"),
+		APIKeyHeader: os.Getenv("NORDPOOL_API_KEY_HEADER"),
+	}
+}
+
+func (o FetchOptions) withDefaults() FetchOptions {
+	if o.BaseURL == "" {
+		o.BaseURL = defaultBaseURL
+	}
+	if o.DateParam == "" {
+		o.DateParam = "date"
+	}
+	if o.MarketParam == "" {
+		o.MarketParam = "market"
+	}
+	if o.AreaParam == "" {
+		o.AreaParam = "deliveryArea"
+	}
+	if o.CurrencyParam == "" {
+		o.CurrencyParam = "currency"
+	}
+	if o.DateLayout == "" {
+		o.DateLayout = "2006-01-02"
+	}
+	if o.APIKeyHeader == "" {
+		o.APIKeyHeader = "Authorization"
+	}
+	if o.TomorrowRetryDelay <= 0 {
+		o.TomorrowRetryDelay = 5 * time.Second
+	}
+	return o
+}
+
+// CacheOptions tunes the SQLite-backed cached fetcher.
+type CacheOptions struct {
+	Fetch FetchOptions // upstream request settings used on refresh
+
+	// ReadOnly never contacts upstream and returns whatever is stored, even if
+	// stale or empty; for readers fed by a separate prefetch job.
+	ReadOnly bool
+
+	// Force skips the freshness check and always refetches and stores, e.g.
+	// after upstream corrected already-cached prices. A failed forced fetch
+	// returns the error rather than falling back to cached data.
+	Force bool
+
+	// OnStale is called with the upstream error when a refresh fails but
+	// cached slots exist; those are returned instead of failing. May be nil.
+	OnStale func(err error)
+
+	// PublishAt is the time of day (Europe/Oslo) when the next day's prices
+	// are published. Before then a missing "tomorrow" is expected and does not
+	// trigger a refetch. Zero means 12:45.
+	PublishAt time.Duration
+
+	// FallbackUncached fetches straight from upstream, without caching, when
+	// the cache database cannot be opened (ErrCacheUnavailable), instead of
+	// failing. The cause is logged to Fetch.Logger. Ignored when ReadOnly.
+	FallbackUncached bool
+}
+
 // FetchNordpoolPrices fetches today+tomorrow prices in EUR/MWh and converts to cents/kWh.
 func FetchNordpoolPrices(ctx context.Context, area, market, currency string) ([]PriceSlot, error) {
-	return FetchNordpoolPricesWithBase(ctx, "https://dataportal-api.nordpoolgroup.com/api/DayAheadPrices", area, market, currency)
+	return FetchNordpoolPricesWithOptions(ctx, area, market, currency, FetchOptions{})
 }
 
 // FetchNordpoolPricesWithBase is like FetchNordpoolPrices but allows overriding the base URL (useful for proxies/CORS).
 func FetchNordpoolPricesWithBase(ctx context.Context, baseURL, area, market, currency string) ([]PriceSlot, error) {
-	today, tomorrow := getTodayAndTomorrowUTC()
+	return FetchNordpoolPricesWithOptions(ctx, area, market, currency, FetchOptions{BaseURL: baseURL})
+}
+
+// FetchNordpoolPricesWithOptions is like FetchNordpoolPrices with full control over the request.
+// Dates are requested concurrently; the first failure cancels the others.
+func FetchNordpoolPricesWithOptions(ctx context.Context, area, market, currency string, opts FetchOptions) ([]PriceSlot, error) {
+	opts = opts.withDefaults()
+	today, tomorrow := getTodayAndTomorrowUTC(opts.now())
 	dates := []time.Time{today, tomorrow}
 
 	client := &http.Client{Timeout: 10 * time.Second}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dayResult struct {
+		slots []PriceSlot
+		err   error
+	}
+	results := make([]dayResult, len(dates))
+
+	var wg sync.WaitGroup
+	for i, d := range dates {
+		wg.Add(1)
+		go func(i int, d time.Time) {
+			defer wg.Done()
+			slots, err := fetchDay(ctx, client, opts, area, market, currency, d)
+			if d.Equal(tomorrow) {
+				for attempt := 0; err == nil && len(slots) == 0 && attempt < opts.TomorrowRetries; attempt++ {
+					if err = sleepContext(ctx, opts.TomorrowRetryDelay); err == nil {
+						slots, err = fetchDay(ctx, client, opts, area, market, currency, d)
+					}
+				}
+			}
+			if err != nil {
+				cancel()
+			}
+			results[i] = dayResult{slots: slots, err: err}
+		}(i, d)
+	}
+	wg.Wait()
+
 	var allSlots []PriceSlot
+	var firstErr error
+	for _, r := range results {
+		if r.err == nil {
+			allSlots = append(allSlots, r.slots...)
+			continue
+		}
+		// Prefer the root cause over context.Canceled from siblings we aborted.
+		if firstErr == nil || errors.Is(firstErr, context.Canceled) {
+			firstErr = r.err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	allSlots = dedupeSlots(allSlots)
+	sort.Slice(allSlots, func(i, j int) bool {
+		return allSlots[i].Timestamp.Before(allSlots[j].Timestamp)
+	})
+	if opts.FillGapSlots > 0 {
+		allSlots = FillGaps(allSlots, opts.FillGapSlots)
+	}
+
+	return allSlots, nil
+}
 
-	for _, d := range dates {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
-		if err != nil {
-			return nil, err
+// sleepContext waits for d or until ctx is done, returning ctx's error then.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// dedupeSlots drops repeated timestamps, keeping the last occurrence (e.g. an
+// appended correction), and otherwise preserves order.
+func dedupeSlots(slots []PriceSlot) []PriceSlot {
+	last := make(map[time.Time]int, len(slots))
+	for i, s := range slots {
+		last[s.Timestamp.UTC()] = i
+	}
+	if len(last) == len(slots) {
+		return slots
+	}
+	out := make([]PriceSlot, 0, len(last))
+	for i, s := range slots {
+		if last[s.Timestamp.UTC()] == i {
+			out = append(out, s)
 		}
-		q := req.URL.Query()
-		q.Add("date", d.Format("2006-01-02"))
-		q.Add("market", market)
-		q.Add("deliveryArea", area)
-		q.Add("currency", currency)
-		req.URL.RawQuery = q.Encode()
-		req.Header.Set("Accept", "application/json")
-		req.Header.Set("User-Agent", "gordpool/1.0 (+https://github.com/)")
+	}
+	return out
+}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("request failed for %s: %w", d.Format("2006-01-02"), err)
+// fetchDay requests a single delivery date and converts it to price slots.
+func fetchDay(ctx context.Context, client *http.Client, opts FetchOptions, area, market, currency string, d time.Time) ([]PriceSlot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.BaseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	q := req.URL.Query()
+	q.Add(opts.DateParam, d.Format(opts.DateLayout))
+	q.Add(opts.MarketParam, market)
+	q.Add(opts.AreaParam, area)
+	q.Add(opts.CurrencyParam, currency)
+	req.URL.RawQuery = q.Encode()
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "gordpool/1.0 (+https://github.com/)")
+	if opts.APIKey != "" {
+		value := opts.APIKey
+		if strings.EqualFold(opts.APIKeyHeader, "Authorization") {
+			value = "Bearer " + value
 		}
+		req.Header.Set(opts.APIKeyHeader, value)
+	}
 
-		func() {
-			defer resp.Body.Close()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed for %s: %w", d.Format("2006-01-02"), err)
+	}
+	defer resp.Body.Close()
 
-			if resp.StatusCode >= 300 {
-				err = fmt.Errorf("Nordpool API %s: %s", d.Format("2006-01-02"), resp.Status)
-				return
-			}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Nordpool API %s: %s", d.Format("2006-01-02"), resp.Status)
+	}
 
-			var raw dayAheadResponse
-			decErr := json.NewDecoder(resp.Body).Decode(&raw)
-			if decErr == io.EOF {
-				// No data yet for this date (e.g. tomorrow not published) – skip.
-				return
-			}
-			if decErr != nil {
-				err = fmt.Errorf("JSON decode failed for %s: %w", d.Format("2006-01-02"), decErr)
-				return
-			}
+	slots, stats, err := ParseDayAheadResponseWithStats(resp.Body, area)
+	if errors.Is(err, ErrNoAreaPrices) {
+		// Malformed or transitional upstream data: warn, like a partial area.
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d.Format("2006-01-02"), err)
+	}
+	if stats.Currency != "" && !strings.EqualFold(stats.Currency, currency) {
+		return nil, fmt.Errorf("%s: %w: requested %s, got %s", d.Format("2006-01-02"), ErrCurrencyMismatch, currency, stats.Currency)
+	}
+	if opts.Logger != nil && (stats.BadTimestamps > 0 || stats.MissingArea > 0) {
+		opts.Logger.Warn("skipped day-ahead entries",
+			"day", d.Format("2006-01-02"),
+			"area", area,
+			"entries", stats.Entries,
+			"bad_timestamps", stats.BadTimestamps,
+			"missing_area", stats.MissingArea,
+			"empty_area_maps", stats.EmptyAreaMaps)
+	}
+	if stats.MissingArea > 0 && opts.OnMissingArea != nil {
+		opts.OnMissingArea(d, stats)
+	}
+	return slots, nil
+}
 
-			for _, entry := range raw.MultiAreaEntries {
-				ts, parseErr := time.Parse(time.RFC3339, entry.DeliveryStart)
-				if parseErr != nil {
-					continue
-				}
-				priceEurPerMWh, ok := entry.EntryPerArea[area]
-				if !ok {
-					continue
-				}
+// ParseStats counts what ParseDayAheadResponseWithStats saw in a response.
+type ParseStats struct {
+	Entries       int    // multiAreaEntries in the response
+	BadTimestamps int    // entries skipped for an unparsable deliveryStart
+	MissingArea   int    // entries skipped because they had no price for the area
+	EmptyAreaMaps int    // of those, entries whose entryPerArea was empty
+	Currency      string // the response's currency; empty if not stated
+}
+
+// ErrNoAreaPrices reports a response whose entries all carry an empty
+// entryPerArea, as opposed to an empty response (no data published yet).
+var ErrNoAreaPrices = errors.New("response had no per-area prices")
+
+// ErrCurrencyMismatch reports a response priced in another currency than the
+// one requested (upstream may ignore unsupported currencies), whose prices
+// would otherwise be misread.
+var ErrCurrencyMismatch = errors.New("response currency differs from requested")
+
+// ErrCacheUnavailable reports a price cache that could not be opened (bad
+// path, read-only filesystem, or no working sqlite driver on the platform).
+// The uncached fetchers (FetchNordpoolPrices and friends) still work.
+var ErrCacheUnavailable = errors.New("price cache unavailable")
 
-				// Convert EUR/MWh → cents/kWh:
-				// EUR/MWh / 1000 = EUR/kWh; *100 = cents/kWh => divide by 10.
-				priceCentsPerKWh := priceEurPerMWh / 10.0
+// ParseDayAheadResponse decodes one DayAheadPrices response body (e.g. a saved
+// file) into the area's slots in cents/kWh, exactly as the fetcher does. An
+// empty body yields no slots and no error.
+func ParseDayAheadResponse(r io.Reader, area string) ([]PriceSlot, error) {
+	slots, _, err := ParseDayAheadResponseWithStats(r, area)
+	return slots, err
+}
+
+// ParseDayAheadResponseWithStats is like ParseDayAheadResponse and also
+// reports how many entries were skipped for lacking the area's price. When
+// every entry has an empty entryPerArea it returns ErrNoAreaPrices.
+func ParseDayAheadResponseWithStats(r io.Reader, area string) ([]PriceSlot, ParseStats, error) {
+	var stats ParseStats
+	var raw dayAheadResponse
+	decErr := json.NewDecoder(r).Decode(&raw)
+	if decErr == io.EOF {
+		// No data yet for this date (e.g. tomorrow not published) – skip.
+		return nil, stats, nil
+	}
+	if decErr != nil {
+		return nil, stats, fmt.Errorf("JSON decode failed: %w", decErr)
+	}
 
-				allSlots = append(allSlots, PriceSlot{
-					Timestamp: ts,
-					Price:     priceCentsPerKWh,
-				})
+	slots := raw.slots(area, &stats, nil)
+	if stats.Entries > 0 && stats.EmptyAreaMaps == stats.Entries {
+		return nil, stats, ErrNoAreaPrices
+	}
+	return slots, stats, nil
+}
+
+// entryIssueFunc receives a problem found in multiAreaEntries[i]; field is
+// relative to the entry, e.g. "deliveryStart".
+type entryIssueFunc func(i int, field, format string, args ...any)
+
+// slots converts the response's entries into the area's slots, counting
+// skipped entries in stats. When issue is non-nil it is also told about each
+// skipped entry and about entries that parse but look wrong (end not after
+// start, start not after the previous entry's).
+func (r dayAheadResponse) slots(area string, stats *ParseStats, issue entryIssueFunc) []PriceSlot {
+	if issue == nil {
+		issue = func(int, string, string, ...any) {}
+	}
+	var slots []PriceSlot
+	preliminary := r.isPreliminary(area)
+	avg, hasAverage := r.areaAverage(area)
+	average := EURPerMWhToCentsPerKWh(avg)
+	stats.Entries = len(r.MultiAreaEntries)
+	stats.Currency = r.Currency
+	var prev time.Time
+	for i, entry := range r.MultiAreaEntries {
+		ts, parseErr := time.Parse(time.RFC3339, entry.DeliveryStart)
+		switch {
+		case parseErr != nil:
+			issue(i, "deliveryStart", "bad timestamp %q", entry.DeliveryStart)
+		case !prev.IsZero() && !ts.After(prev):
+			issue(i, "deliveryStart", "%s is not after the previous entry (%s)", entry.DeliveryStart, prev.Format(time.RFC3339))
+		}
+		end, endErr := time.Parse(time.RFC3339, entry.DeliveryEnd)
+		switch {
+		case endErr != nil:
+			issue(i, "deliveryEnd", "bad timestamp %q", entry.DeliveryEnd)
+		case parseErr == nil && !end.After(ts):
+			issue(i, "deliveryEnd", "%s is not after deliveryStart", entry.DeliveryEnd)
+		}
+		if parseErr != nil {
+			stats.BadTimestamps++
+			continue
+		}
+		prev = ts
+		priceEurPerMWh, ok := entry.EntryPerArea[area]
+		if !ok {
+			stats.MissingArea++
+			if len(entry.EntryPerArea) == 0 {
+				stats.EmptyAreaMaps++
 			}
-		}()
-		if err != nil {
-			return nil, err
+			issue(i, "entryPerArea", "no price for area %s", area)
+			continue
 		}
+		duration := 0
+		if endErr == nil && end.After(ts) {
+			duration = int(end.Sub(ts) / time.Minute)
+		}
+
+		slots = append(slots, PriceSlot{
+			Timestamp:       ts,
+			Price:           EURPerMWhToCentsPerKWh(priceEurPerMWh),
+			Preliminary:     preliminary,
+			ExchangeRate:    r.ExchangeRate,
+			DurationMinutes: duration,
+			AreaAverage:     average,
+			HasAreaAverage:  hasAverage,
+		})
 	}
+	return slots
+}
 
-	sort.Slice(allSlots, func(i, j int) bool {
-		return allSlots[i].Timestamp.Before(allSlots[j].Timestamp)
-	})
+// EURPerMWhToCentsPerKWh converts an upstream price to the planner's unit:
+// EUR/MWh / 1000 = EUR/kWh; *100 = cents/kWh => divide by 10. The same holds
+// for any currency quoted per MWh.
+func EURPerMWhToCentsPerKWh(p float64) float64 {
+	return p / 10
+}
 
-	return allSlots, nil
+// CentsPerKWhToEURPerMWh is the inverse of EURPerMWhToCentsPerKWh.
+func CentsPerKWhToEURPerMWh(p float64) float64 {
+	return p * 10
 }
 
 func inferResolutionMinutes(prices []PriceSlot) int {
+	if d := reportedResolutionMinutes(prices); d > 0 {
+		return d
+	}
 	if len(prices) < 2 {
 		return 60
 	}
@@ -180,12 +656,54 @@ func inferResolutionMinutes(prices []PriceSlot) int {
 	return int(math.Round(median))
 }
 
-func slotsForHours(maxHours float64, resolutionMinutes int) int {
+// reportedResolutionMinutes returns the most common DurationMinutes among
+// slots that carry one (the shorter on a tie), or 0 when none do.
+func reportedResolutionMinutes(prices []PriceSlot) int {
+	counts := make(map[int]int)
+	for _, p := range prices {
+		if p.DurationMinutes > 0 {
+			counts[p.DurationMinutes]++
+		}
+	}
+	best := 0
+	for d, n := range counts {
+		if best == 0 || n > counts[best] || (n == counts[best] && d < best) {
+			best = d
+		}
+	}
+	return best
+}
+
+// SlotRounding controls how fractional MaxChargeHours/MaxDischargeHours map
+// to whole slots.
+type SlotRounding int
+
+const (
+	RoundCeil    SlotRounding = iota // 2.1h at 60 min -> 3 slots (default)
+	RoundFloor                       // 2.1h at 60 min -> 2 slots
+	RoundNearest                     // 2.1h -> 2, 2.9h -> 3 slots
+)
+
+func slotsForHours(maxHours float64, resolutionMinutes int, rounding SlotRounding) int {
+	// slotEpsilon keeps float noise (e.g. 4.1h at 6 min = 40.999…) from
+	// flooring a whole slot count down by one.
+	const slotEpsilon = 1e-9
+
 	totalMinutes := maxHours * 60
-	return int(math.Ceil(totalMinutes / float64(resolutionMinutes)))
+	slots := totalMinutes / float64(resolutionMinutes)
+	switch rounding {
+	case RoundFloor:
+		return int(math.Floor(slots + slotEpsilon))
+	case RoundNearest:
+		return int(math.Round(slots))
+	default:
+		return int(math.Ceil(slots))
+	}
 }
 
-func groupConsecutiveSlots(slots []PriceSlot, resolutionMinutes int) []IntervalJSON {
+// groupConsecutiveSlots merges back-to-back slots into intervals. With
+// splitAtDay, a run crossing UTC midnight is split into one interval per day.
+func groupConsecutiveSlots(slots []PriceSlot, resolutionMinutes int, splitAtDay bool) []IntervalJSON {
 	if len(slots) == 0 {
 		return nil
 	}
@@ -201,7 +719,8 @@ func groupConsecutiveSlots(slots []PriceSlot, resolutionMinutes int) []IntervalJ
 	for i := 1; i < len(slots); i++ {
 		prev := slots[i-1]
 		cur := slots[i]
-		if cur.Timestamp.Sub(prev.Timestamp) == step {
+		sameDay := !splitAtDay || utcDayKey(cur.Timestamp) == utcDayKey(prev.Timestamp)
+		if cur.Timestamp.Sub(prev.Timestamp) == step && sameDay {
 			group = append(group, cur)
 		} else {
 			groups = append(groups, group)
@@ -234,8 +753,194 @@ func groupConsecutiveSlots(slots []PriceSlot, resolutionMinutes int) []IntervalJ
 	return intervals
 }
 
+// applyActionGap walks charge and discharge slots in time order and drops any
+// slot starting within gapSlots slots after a kept slot of the other action.
+func applyActionGap(charge, discharge []PriceSlot, gapSlots, resolutionMinutes int) ([]PriceSlot, []PriceSlot) {
+	if gapSlots <= 0 || len(charge) == 0 || len(discharge) == 0 {
+		return charge, discharge
+	}
+	gap := time.Duration(gapSlots*resolutionMinutes) * time.Minute
+
+	type action struct {
+		slot   PriceSlot
+		charge bool
+	}
+	all := make([]action, 0, len(charge)+len(discharge))
+	for _, s := range charge {
+		all = append(all, action{slot: s, charge: true})
+	}
+	for _, s := range discharge {
+		all = append(all, action{slot: s})
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].slot.Timestamp.Before(all[j].slot.Timestamp) })
+
+	var outCharge, outDischarge []PriceSlot
+	var lastCharge, lastDischarge time.Time
+	for _, a := range all {
+		ts := a.slot.Timestamp
+		if a.charge {
+			if !lastDischarge.IsZero() && ts.Sub(lastDischarge) <= gap {
+				continue
+			}
+			lastCharge = ts
+			outCharge = append(outCharge, a.slot)
+			continue
+		}
+		if !lastCharge.IsZero() && ts.Sub(lastCharge) <= gap {
+			continue
+		}
+		lastDischarge = ts
+		outDischarge = append(outDischarge, a.slot)
+	}
+	return outCharge, outDischarge
+}
+
+// actionBlocked returns the slots the other action may not take: other's own
+// slots and those within gapSlots on either side, so adding one cannot break
+// applyActionGap.
+func actionBlocked(other []PriceSlot, gapSlots, resolutionMinutes int) map[time.Time]bool {
+	gapSlots = max(gapSlots, 0)
+	step := time.Duration(resolutionMinutes) * time.Minute
+	out := make(map[time.Time]bool, len(other)*(2*gapSlots+1))
+	for _, s := range other {
+		for k := -gapSlots; k <= gapSlots; k++ {
+			out[s.Timestamp.Add(time.Duration(k)*step)] = true
+		}
+	}
+	return out
+}
+
+// enforceMinRun makes every run of consecutive selected slots at least minRun
+// long. A short run is extended with the better of its two neighbours from pool
+// (qualifying slots not in exclude); at the slot cap the worst selected slot
+// outside the run and outside runs already kept makes room. An extension must
+// leave the selection acceptable to fits (nil accepts all). A run that cannot
+// be extended is dropped. Returns the selection in time order.
+func enforceMinRun(selected, pool []PriceSlot, exclude map[time.Time]bool, minRun, maxSlots, resolutionMinutes int, better func(a, b float64) bool, fits func([]PriceSlot) bool) []PriceSlot {
+	if minRun <= 1 || len(selected) == 0 {
+		return selected
+	}
+	step := time.Duration(resolutionMinutes) * time.Minute
+
+	poolAt := make(map[time.Time]PriceSlot, len(pool))
+	for _, p := range pool {
+		poolAt[p.Timestamp] = p
+	}
+	sel := make(map[time.Time]PriceSlot, len(selected))
+	for _, s := range selected {
+		sel[s.Timestamp] = s
+	}
+	order := append([]PriceSlot(nil), selected...)
+	sort.Slice(order, func(i, j int) bool {
+		return order[i].Timestamp.Before(order[j].Timestamp)
+	})
+
+	done := make(map[time.Time]bool)
+	for _, s := range order {
+		if _, ok := sel[s.Timestamp]; !ok || done[s.Timestamp] {
+			continue
+		}
+		for {
+			start, end := s.Timestamp, s.Timestamp
+			for _, ok := sel[start.Add(-step)]; ok; _, ok = sel[start.Add(-step)] {
+				start = start.Add(-step)
+			}
+			for _, ok := sel[end.Add(step)]; ok; _, ok = sel[end.Add(step)] {
+				end = end.Add(step)
+			}
+			if int(end.Sub(start)/step)+1 >= minRun {
+				for t := start; !t.After(end); t = t.Add(step) {
+					done[t] = true
+				}
+				break
+			}
+
+			var pick *PriceSlot
+			for _, ts := range []time.Time{start.Add(-step), end.Add(step)} {
+				p, ok := poolAt[ts]
+				if !ok || exclude[ts] {
+					continue
+				}
+				if pick == nil || better(p.Price, pick.Price) {
+					pick = &p
+				}
+			}
+			var worst *PriceSlot
+			if pick != nil && len(sel) >= maxSlots {
+				// swap the worst slot not yet in a kept run for the neighbour
+				for ts, p := range sel {
+					if done[ts] || (!ts.Before(start) && !ts.After(end)) {
+						continue
+					}
+					if worst == nil || better(worst.Price, p.Price) ||
+						(worst.Price == p.Price && ts.After(worst.Timestamp)) {
+						p := p
+						worst = &p
+					}
+				}
+				if worst == nil {
+					pick = nil
+				} else {
+					delete(sel, worst.Timestamp)
+				}
+			}
+			if pick != nil {
+				sel[pick.Timestamp] = *pick
+				if fits != nil && !fits(slotValues(sel)) {
+					delete(sel, pick.Timestamp)
+					if worst != nil {
+						sel[worst.Timestamp] = *worst
+					}
+					pick = nil
+				}
+			}
+			if pick == nil {
+				for t := start; !t.After(end); t = t.Add(step) {
+					delete(sel, t)
+				}
+				break
+			}
+		}
+	}
+
+	return slotValues(sel)
+}
+
+// slotValues returns the slots of m in time order.
+func slotValues(m map[time.Time]PriceSlot) []PriceSlot {
+	out := make([]PriceSlot, 0, len(m))
+	for _, s := range m {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Timestamp.Before(out[j].Timestamp)
+	})
+	return out
+}
+
+// smoothPrices returns the exponentially smoothed series (time order, seeded
+// with the first price) keyed by UTC timestamp. It is empty unless alpha is
+// in (0, 1).
+func smoothPrices(series []PriceSlot, alpha float64) map[time.Time]float64 {
+	out := make(map[time.Time]float64)
+	if alpha <= 0 || alpha >= 1 || len(series) == 0 {
+		return out
+	}
+	sorted := append([]PriceSlot(nil), series...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+	level := sorted[0].Price
+	for _, s := range sorted {
+		level = alpha*s.Price + (1-alpha)*level
+		out[s.Timestamp.UTC()] = level
+	}
+	return out
+}
+
 // BuildBatterySchedule builds a charge/discharge schedule for the next slots.
 func BuildBatterySchedule(prices []PriceSlot, params BatteryStrategyParams, now time.Time) ScheduleJSON {
+	if params.TomorrowOnly {
+		prices = TomorrowSlots(prices, now)
+	}
 	var future []PriceSlot
 	for _, p := range prices {
 		if !p.Timestamp.Before(now) {
@@ -252,14 +957,15 @@ func BuildBatterySchedule(prices []PriceSlot, params BatteryStrategyParams, now
 			DischargeSlots:     []SlotJSON{},
 			ChargeIntervals:    []IntervalJSON{},
 			DischargeIntervals: []IntervalJSON{},
+			Warnings:           []string{"no future price slots to plan"},
 		}
 	}
 
 	resolution := inferResolutionMinutes(future)
 	resPtr := &resolution
 
-	maxChargeSlots := slotsForHours(params.MaxChargeHours, resolution)
-	maxDischargeSlots := slotsForHours(params.MaxDischargeHours, resolution)
+	maxChargeSlots := slotsForHours(params.MaxChargeHours, resolution, params.HoursRounding)
+	maxDischargeSlots := slotsForHours(params.MaxDischargeHours, resolution, params.HoursRounding)
 
 	var chargeCandidates []PriceSlot
 	var dischargeCandidates []PriceSlot
@@ -271,25 +977,109 @@ func BuildBatterySchedule(prices []PriceSlot, params BatteryStrategyParams, now
 		dischargeThreshold = 8
 	}
 
+	exportAt := make(map[time.Time]float64, len(params.ExportPrices))
+	for _, e := range params.ExportPrices {
+		exportAt[e.Timestamp.UTC()] = e.Price
+	}
+
+	// selection prices by slot, smoothed when SmoothingAlpha is set and
+	// overridden by ForecastOverride; charge slots are judged by spot,
+	// discharge slots by export where present
+	chargeSel := smoothPrices(future, params.SmoothingAlpha)
+	for ts, p := range params.ForecastOverride {
+		chargeSel[ts.UTC()] = p
+	}
+	dischargeSel := smoothPrices(params.ExportPrices, params.SmoothingAlpha)
+	for ts, p := range chargeSel {
+		if _, ok := exportAt[ts]; !ok {
+			dischargeSel[ts] = p
+		}
+	}
+
+	// cmpPrice is the price used for selection; equal prices fall back to the
+	// earlier slot so ties are deterministic.
+	cmpPrice := func(s PriceSlot, sel map[time.Time]float64) float64 {
+		p := s.Price
+		if v, ok := sel[s.Timestamp.UTC()]; ok {
+			p = v
+		}
+		if params.RoundToDecimals == nil {
+			return p
+		}
+		scale := math.Pow(10, float64(*params.RoundToDecimals))
+		return math.Round(p*scale) / scale
+	}
+
 	for _, s := range future {
-		if params.LastPriceCharged-s.Price >= params.Epsilon {
+		if s.Filled {
+			continue
+		}
+		if params.LastPriceCharged-cmpPrice(s, chargeSel) >= params.Epsilon {
 			chargeCandidates = append(chargeCandidates, s)
 		}
-		if s.Price >= dischargeThreshold {
-			dischargeCandidates = append(dischargeCandidates, s)
+		d := s
+		if p, ok := exportAt[s.Timestamp.UTC()]; ok {
+			d.Price = p
+		}
+		if cmpPrice(d, dischargeSel) >= dischargeThreshold {
+			dischargeCandidates = append(dischargeCandidates, d)
 		}
 	}
 
-	sort.Slice(chargeCandidates, func(i, j int) bool {
-		return chargeCandidates[i].Price < chargeCandidates[j].Price
+	chargeRank := func(s PriceSlot) float64 {
+		return cmpPrice(s, chargeSel) + params.PreferEarly*s.Timestamp.Sub(now).Hours()
+	}
+	sort.SliceStable(chargeCandidates, func(i, j int) bool {
+		a, b := chargeRank(chargeCandidates[i]), chargeRank(chargeCandidates[j])
+		if a != b {
+			return a < b
+		}
+		return chargeCandidates[i].Timestamp.Before(chargeCandidates[j].Timestamp)
 	})
-	sort.Slice(dischargeCandidates, func(i, j int) bool {
-		return dischargeCandidates[i].Price > dischargeCandidates[j].Price
+	evening := eveningWindow(params)
+	dischargeRank := func(s PriceSlot) float64 {
+		if evening(s.Timestamp) {
+			return cmpPrice(s, dischargeSel) + params.EveningMargin
+		}
+		return cmpPrice(s, dischargeSel)
+	}
+	sort.SliceStable(dischargeCandidates, func(i, j int) bool {
+		a, b := dischargeRank(dischargeCandidates[i]), dischargeRank(dischargeCandidates[j])
+		if a != b {
+			return a > b
+		}
+		if ei, ej := evening(dischargeCandidates[i].Timestamp), evening(dischargeCandidates[j].Timestamp); ei != ej {
+			return ei
+		}
+		return dischargeCandidates[i].Timestamp.Before(dischargeCandidates[j].Timestamp)
 	})
 
+	var warnings []string
+	if maxChargeSlots > len(future) {
+		warnings = append(warnings, fmt.Sprintf("charge: MaxChargeHours asks for %d slots but the horizon has only %d; clamped to %d",
+			maxChargeSlots, len(future), len(future)))
+		maxChargeSlots = len(future)
+	}
+	if maxDischargeSlots > len(future) {
+		warnings = append(warnings, fmt.Sprintf("discharge: MaxDischargeHours asks for %d slots but the horizon has only %d; clamped to %d",
+			maxDischargeSlots, len(future), len(future)))
+		maxDischargeSlots = len(future)
+	}
+	if len(chargeCandidates) == 0 && maxChargeSlots > 0 {
+		warnings = append(warnings, fmt.Sprintf("no charge: no slot is at least epsilon (%.2f c/kWh) below the last price charged (%.2f c/kWh)",
+			params.Epsilon, params.LastPriceCharged))
+	}
+	if len(dischargeCandidates) == 0 && maxDischargeSlots > 0 {
+		warnings = append(warnings, fmt.Sprintf("no discharge: no slot reaches the discharge threshold (%.2f c/kWh)", dischargeThreshold))
+	}
+
+	chargePool := append([]PriceSlot(nil), chargeCandidates...)
+	dischargePool := append([]PriceSlot(nil), dischargeCandidates...)
+
 	if len(chargeCandidates) > maxChargeSlots {
 		chargeCandidates = chargeCandidates[:maxChargeSlots]
 	}
+	chargeCandidates = applyChargeBudget(chargeCandidates, params, resolution)
 	if len(dischargeCandidates) > maxDischargeSlots {
 		dischargeCandidates = dischargeCandidates[:maxDischargeSlots]
 	}
@@ -301,16 +1091,79 @@ func BuildBatterySchedule(prices []PriceSlot, params BatteryStrategyParams, now
 		return dischargeCandidates[i].Timestamp.Before(dischargeCandidates[j].Timestamp)
 	})
 
-	chargeIntervals := groupConsecutiveSlots(chargeCandidates, resolution)
-	dischargeIntervals := groupConsecutiveSlots(dischargeCandidates, resolution)
+	// The gap goes first: dropping slots may shorten runs, which min-run then
+	// extends (never into the gap) or drops.
+	chargeCandidates, dischargeCandidates = applyActionGap(chargeCandidates, dischargeCandidates, params.MinChargeDischargeGapSlots, resolution)
+
+	if params.MinRunSlots > 1 {
+		cheaper := func(a, b float64) bool { return a < b }
+		dearer := func(a, b float64) bool { return a > b }
+		gap := params.MinChargeDischargeGapSlots
+		withinBudget := func(charge []PriceSlot) bool { return chargeFitsBudget(charge, params, resolution) }
+		chargeCandidates = enforceMinRun(chargeCandidates, chargePool, actionBlocked(dischargeCandidates, gap, resolution), params.MinRunSlots, maxChargeSlots, resolution, cheaper, withinBudget)
+		dischargeCandidates = enforceMinRun(dischargeCandidates, dischargePool, actionBlocked(chargeCandidates, gap, resolution), params.MinRunSlots, maxDischargeSlots, resolution, dearer, nil)
+	}
+
+	// Reserve first: the end target then only makes reserve-safe changes, so
+	// neither undoes the other.
+	dischargeCandidates = applyReserve(future, chargeCandidates, dischargeCandidates, params, resolution)
+	chargeCandidates, dischargeCandidates = applyEndSoCTarget(future, chargeCandidates, dischargeCandidates, chargePool, dischargePool, params, resolution)
+
+	// Discharge candidates were ranked by their export price; report spot in
+	// Price like every other slot and the export price alongside.
+	spotAt := make(map[time.Time]float64, len(future))
+	for _, s := range future {
+		spotAt[s.Timestamp] = s.Price
+	}
+	for i, s := range dischargeCandidates {
+		dischargeCandidates[i].Price = spotAt[s.Timestamp]
+	}
+
+	var endSoC *float64
+	if capacity, _, ok := batteryModel(params, resolution); ok {
+		final := finalSoC(future, slotSet(chargeCandidates), slotSet(dischargeCandidates), params, resolution)
+		pct := final / capacity * 100
+		endSoC = &pct
+	}
+	if len(chargeCandidates) == 0 && len(chargePool) > 0 && maxChargeSlots > 0 {
+		warnings = append(warnings, "no charge: all candidates were dropped by the budget, run, gap or battery constraints")
+	}
+	if len(dischargeCandidates) == 0 && len(dischargePool) > 0 && maxDischargeSlots > 0 {
+		warnings = append(warnings, "no discharge: all candidates were dropped by the run, gap or battery constraints")
+	}
+
+	powerFractions := slotPowerFractions(future, slotSet(chargeCandidates), slotSet(dischargeCandidates), params, resolution)
+
+	preliminary := false
+	for _, s := range future {
+		if s.Preliminary {
+			preliminary = true
+			break
+		}
+	}
+
+	chargeIntervals := groupConsecutiveSlots(chargeCandidates, resolution, params.SplitAtDayBoundary)
+	dischargeIntervals := groupConsecutiveSlots(dischargeCandidates, resolution, params.SplitAtDayBoundary)
 
-	toSlotJSON := func(slots []PriceSlot) []SlotJSON {
+	toSlotJSON := func(slots []PriceSlot, discharge bool) []SlotJSON {
 		out := make([]SlotJSON, 0, len(slots))
 		for _, s := range slots {
-			out = append(out, SlotJSON{
+			sj := SlotJSON{
 				Timestamp: s.Timestamp.Format(time.RFC3339),
 				Price:     s.Price,
-			})
+			}
+			if params.SecondaryCurrency != "" {
+				if v, ok := SecondaryPrice(s, params.SecondaryRate); ok {
+					sj.SecondaryPrice = &v
+				}
+			}
+			if f, ok := powerFractions[s.Timestamp]; ok {
+				sj.PowerFraction = &f
+			}
+			if e, ok := exportAt[s.Timestamp.UTC()]; ok && discharge {
+				sj.ExportPrice = &e
+			}
+			out = append(out, sj)
 		}
 		return out
 	}
@@ -320,9 +1173,29 @@ func BuildBatterySchedule(prices []PriceSlot, params BatteryStrategyParams, now
 		LastPriceCharged:   params.LastPriceCharged,
 		Epsilon:            params.Epsilon,
 		ResolutionMinutes:  resPtr,
-		ChargeSlots:        toSlotJSON(chargeCandidates),
-		DischargeSlots:     toSlotJSON(dischargeCandidates),
+		ChargeSlots:        toSlotJSON(chargeCandidates, false),
+		DischargeSlots:     toSlotJSON(dischargeCandidates, true),
 		ChargeIntervals:    chargeIntervals,
 		DischargeIntervals: dischargeIntervals,
+		Preliminary:        preliminary,
+		EndSoC:             endSoC,
+		SecondaryCurrency:  params.SecondaryCurrency,
+		SecondaryRate:      params.SecondaryRate,
+
+		ChargeBudgetRemaining: budgetRemaining(future, chargeCandidates, params, resolution),
+		AreaAverages:          areaAverages(future),
+		Warnings:              warnings,
+	}
+}
+
+// SecondaryPrice converts a slot price using rate, or the slot's own exchange
+// rate when rate is 0. ok is false when no rate is known.
+func SecondaryPrice(s PriceSlot, rate float64) (float64, bool) {
+	if rate <= 0 {
+		rate = s.ExchangeRate
+	}
+	if rate <= 0 {
+		return 0, false
 	}
+	return s.Price * rate, true
 }
@@ -0,0 +1,1158 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testDay is the delivery day most tests plan on.
+var testDay = time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+// hourly returns one slot per price, an hour apart from start.
+func hourly(start time.Time, prices ...float64) []PriceSlot {
+	out := make([]PriceSlot, len(prices))
+	for i, p := range prices {
+		out[i] = PriceSlot{Timestamp: start.Add(time.Duration(i) * time.Hour), Price: p}
+	}
+	return out
+}
+
+// slotHours returns the UTC hour of each slot's timestamp.
+func slotHours(t *testing.T, slots []SlotJSON) []int {
+	t.Helper()
+	out := make([]int, len(slots))
+	for i, s := range slots {
+		ts, err := time.Parse(time.RFC3339, s.Timestamp)
+		if err != nil {
+			t.Fatalf("slot %d: %v", i, err)
+		}
+		out[i] = ts.UTC().Hour()
+	}
+	return out
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMinRunSlots(t *testing.T) {
+	tests := []struct {
+		name   string
+		prices []float64
+		params BatteryStrategyParams
+		charge []int
+	}{
+		{
+			name:   "isolated slot at the cap is extended",
+			prices: []float64{1, 5, 9, 9, 2, 9},
+			params: BatteryStrategyParams{MaxChargeHours: 2, LastPriceCharged: 8, Epsilon: 1, MinRunSlots: 2},
+			charge: []int{0, 1},
+		},
+		{
+			name:   "run already long enough is kept",
+			prices: []float64{1, 2, 9, 9, 9, 9},
+			params: BatteryStrategyParams{MaxChargeHours: 2, LastPriceCharged: 8, Epsilon: 1, MinRunSlots: 2},
+			charge: []int{0, 1},
+		},
+		{
+			name:   "run without qualifying neighbours is dropped",
+			prices: []float64{1, 9, 9, 9, 9, 9},
+			params: BatteryStrategyParams{MaxChargeHours: 2, LastPriceCharged: 8, Epsilon: 1, MinRunSlots: 2},
+			charge: []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := BuildBatterySchedule(hourly(testDay, tt.prices...), tt.params, testDay)
+			if got := slotHours(t, s.ChargeSlots); !equalInts(got, tt.charge) {
+				t.Errorf("charge hours = %v, want %v", got, tt.charge)
+			}
+		})
+	}
+}
+
+func TestActionGapKeepsMinRun(t *testing.T) {
+	// Charge 0-1 and discharge 3-4; a gap of 2 drops discharge 3, leaving a
+	// 1-slot run that must be extended away from the charge, not kept short.
+	prices := []float64{1, 1, 9, 20, 20, 19, 3}
+	params := BatteryStrategyParams{
+		MaxChargeHours: 2, MaxDischargeHours: 2,
+		LastPriceCharged: 5, Epsilon: 1,
+		MinRunSlots: 2, MinChargeDischargeGapSlots: 2,
+	}
+	s := BuildBatterySchedule(hourly(testDay, prices...), params, testDay)
+	if got, want := slotHours(t, s.ChargeSlots), []int{0, 1}; !equalInts(got, want) {
+		t.Errorf("charge hours = %v, want %v", got, want)
+	}
+	if got, want := slotHours(t, s.DischargeSlots), []int{4, 5}; !equalInts(got, want) {
+		t.Errorf("discharge hours = %v, want %v", got, want)
+	}
+}
+
+// fmtPtr prints an optional value for failure messages.
+func fmtPtr(p *float64) string {
+	if p == nil {
+		return "nil"
+	}
+	return strconv.FormatFloat(*p, 'f', -1, 64)
+}
+
+func TestSlotsForHours(t *testing.T) {
+	tests := []struct {
+		hours      float64
+		resolution int
+		rounding   SlotRounding
+		want       int
+	}{
+		{2.1, 60, RoundCeil, 3},
+		{2.1, 60, RoundFloor, 2},
+		{2.1, 60, RoundNearest, 2},
+		{2.9, 60, RoundNearest, 3},
+		{4.1, 6, RoundFloor, 41},
+		{1, 15, RoundFloor, 4},
+		{0, 15, RoundCeil, 0},
+	}
+	for _, tt := range tests {
+		if got := slotsForHours(tt.hours, tt.resolution, tt.rounding); got != tt.want {
+			t.Errorf("slotsForHours(%v, %d, %d) = %d, want %d", tt.hours, tt.resolution, tt.rounding, got, tt.want)
+		}
+	}
+}
+
+func TestParseAreaAverage(t *testing.T) {
+	const body = `{
+		"deliveryDateCET": "2025-01-15",
+		"market": "DayAhead",
+		"currency": "EUR",
+		"areaAverages": [{"areaCode": "SE3", "price": 0}, {"areaCode": "LV", "price": 112}],
+		"multiAreaEntries": [{
+			"deliveryStart": "2025-01-15T00:00:00Z",
+			"deliveryEnd": "2025-01-15T01:00:00Z",
+			"entryPerArea": {"SE3": 0, "LV": 95.5, "EE": 90}
+		}]
+	}`
+	tests := []struct {
+		area    string
+		wantAvg float64
+		wantHas bool
+	}{
+		{"LV", 11.2, true},
+		{"SE3", 0, true}, // a genuine zero average is still known
+		{"EE", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.area, func(t *testing.T) {
+			slots, err := ParseDayAheadResponse(strings.NewReader(body), tt.area)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(slots) != 1 {
+				t.Fatalf("got %d slots, want 1", len(slots))
+			}
+			s := slots[0]
+			if s.AreaAverage != tt.wantAvg || s.HasAreaAverage != tt.wantHas {
+				t.Fatalf("average = %v (known %t), want %v (known %t)", s.AreaAverage, s.HasAreaAverage, tt.wantAvg, tt.wantHas)
+			}
+			_, ok := areaAverages(slots)["2025-01-15"]
+			if ok != tt.wantHas {
+				t.Fatalf("areaAverages has day = %t, want %t", ok, tt.wantHas)
+			}
+		})
+	}
+}
+
+func TestPreferEveningDischarge(t *testing.T) {
+	// 10:00 UTC is midday; 17:00 UTC is 18:00 in market time, inside the
+	// default evening window.
+	tests := []struct {
+		name           string
+		midday, eve    float64
+		prefer         bool
+		margin         float64
+		wantDischarges []int
+	}{
+		{name: "tie goes to evening", midday: 20, eve: 20, prefer: true, wantDischarges: []int{17}},
+		{name: "tie without preference goes to earlier", midday: 20, eve: 20, wantDischarges: []int{10}},
+		{name: "pricier midday wins without margin", midday: 22, eve: 20, prefer: true, wantDischarges: []int{10}},
+		{name: "margin covers the difference", midday: 22, eve: 20, prefer: true, margin: 3, wantDischarges: []int{17}},
+		{name: "margin too small", midday: 24, eve: 20, prefer: true, margin: 3, wantDischarges: []int{10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices := make([]float64, 24)
+			for i := range prices {
+				prices[i] = 5
+			}
+			prices[10], prices[17] = tt.midday, tt.eve
+			params := BatteryStrategyParams{
+				MaxDischargeHours: 1, LastPriceCharged: 5, Epsilon: 1,
+				PreferEveningDischarge: tt.prefer, EveningMargin: tt.margin,
+			}
+			s := BuildBatterySchedule(hourly(testDay, prices...), params, testDay)
+			if got := slotHours(t, s.DischargeSlots); !equalInts(got, tt.wantDischarges) {
+				t.Errorf("discharge hours = %v, want %v", got, tt.wantDischarges)
+			}
+		})
+	}
+}
+
+func TestEURPerMWhToCentsPerKWh(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{100, 10},
+		{0, 0},
+		{-25, -2.5},
+		{-0.4, -0.04},
+		{1234.5, 123.45},
+	}
+	for _, tt := range tests {
+		if got := EURPerMWhToCentsPerKWh(tt.in); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("EURPerMWhToCentsPerKWh(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+		if back := CentsPerKWhToEURPerMWh(tt.want); math.Abs(back-tt.in) > 1e-9 {
+			t.Errorf("CentsPerKWhToEURPerMWh(%v) = %v, want %v", tt.want, back, tt.in)
+		}
+	}
+}
+
+func TestFillGaps(t *testing.T) {
+	full := hourly(testDay, 4, 0, 9, 9, 9)
+	in := []PriceSlot{full[0], full[2], full[3], full[4]} // 01:00 is missing
+
+	out := FillGaps(in, 1)
+	if len(out) != 5 || !out[1].Timestamp.Equal(full[1].Timestamp) {
+		t.Fatalf("got %d slots, want 01:00 inserted", len(out))
+	}
+	if !out[1].Filled || out[1].Price != 6.5 {
+		t.Fatalf("01:00 = %+v, want a filled slot at 6.5", out[1])
+	}
+	for _, i := range []int{0, 2, 3, 4} {
+		if out[i].Filled {
+			t.Errorf("slot %d flagged as filled", i)
+		}
+	}
+
+	// Planning skips the filled slot even though it would be the cheapest
+	// left after 00:00.
+	s := BuildBatterySchedule(out, BatteryStrategyParams{MaxChargeHours: 2, LastPriceCharged: 8, Epsilon: 0.5}, testDay)
+	if got := slotHours(t, s.ChargeSlots); !equalInts(got, []int{0}) {
+		t.Fatalf("charge hours = %v, want [0]", got)
+	}
+}
+
+func TestParsePreliminary(t *testing.T) {
+	const entries = `"multiAreaEntries": [{"deliveryStart": "2025-01-15T00:00:00Z", "deliveryEnd": "2025-01-15T01:00:00Z", "entryPerArea": {"LV": 90, "EE": 90}}]`
+	tests := []struct {
+		name   string
+		states string
+		area   string
+		want   bool
+	}{
+		{"preliminary", `[{"state": "Preliminary", "areas": ["LV"]}]`, "LV", true},
+		{"final", `[{"state": "Final", "areas": ["LV"]}]`, "LV", false},
+		{"other area preliminary", `[{"state": "Final", "areas": ["LV"]}, {"state": "Preliminary", "areas": ["EE"]}]`, "LV", false},
+		{"missing state", `[]`, "LV", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"areaStates": ` + tt.states + `, ` + entries + `}`
+			slots, err := ParseDayAheadResponse(strings.NewReader(body), tt.area)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(slots) != 1 || slots[0].Preliminary != tt.want {
+				t.Fatalf("slots = %+v, want one with Preliminary %t", slots, tt.want)
+			}
+		})
+	}
+}
+
+// dayAheadBody returns a DayAheadPrices response with one hourly entry per
+// price (EUR/MWh) for area, starting at day.
+func dayAheadBody(day time.Time, area string, prices ...float64) string {
+	entries := make([]string, len(prices))
+	for i, p := range prices {
+		start := day.Add(time.Duration(i) * time.Hour)
+		entries[i] = fmt.Sprintf(`{"deliveryStart": %q, "deliveryEnd": %q, "entryPerArea": {%q: %v}}`,
+			start.Format(time.RFC3339), start.Add(time.Hour).Format(time.RFC3339), area, p)
+	}
+	return fmt.Sprintf(`{"deliveryDateCET": %q, "currency": "EUR", "multiAreaEntries": [%s]}`,
+		day.Format("2006-01-02"), strings.Join(entries, ","))
+}
+
+// requestDay returns the delivery date a DayAheadPrices request asks for.
+func requestDay(t *testing.T, r *http.Request) time.Time {
+	t.Helper()
+	day, err := time.Parse("2006-01-02", r.URL.Query().Get("date"))
+	if err != nil {
+		t.Errorf("bad date param in %s: %v", r.URL, err)
+	}
+	return day
+}
+
+func TestFetchDaysConcurrently(t *testing.T) {
+	const latency = 200 * time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		day := requestDay(t, r)
+		time.Sleep(latency)
+		io.WriteString(w, dayAheadBody(day, "LV", 10, 20))
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	slots, err := FetchNordpoolPricesWithOptions(context.Background(), "LV", "DayAhead", "EUR", FetchOptions{BaseURL: srv.URL, Anchor: testDay})
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed >= 2*latency {
+		t.Errorf("fetch took %v; two days at %v each should overlap", elapsed, latency)
+	}
+	if len(slots) != 4 {
+		t.Fatalf("got %d slots, want 4", len(slots))
+	}
+	for i := 1; i < len(slots); i++ {
+		if !slots[i].Timestamp.After(slots[i-1].Timestamp) {
+			t.Fatalf("slots out of order at %d: %v after %v", i, slots[i].Timestamp, slots[i-1].Timestamp)
+		}
+	}
+	if !slots[0].Timestamp.Equal(testDay) || !slots[2].Timestamp.Equal(testDay.Add(24*time.Hour)) {
+		t.Errorf("slots start at %v and %v, want today then tomorrow", slots[0].Timestamp, slots[2].Timestamp)
+	}
+}
+
+func TestFetchCustomQueryFormat(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mu.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		mu.Unlock()
+		day, err := time.Parse("02.01.2006", q.Get("day"))
+		if err != nil || q.Get("mkt") != "DayAhead" || q.Get("zone") != "LV" || q.Get("cur") != "EUR" {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, dayAheadBody(day, "LV", 10))
+	}))
+	defer srv.Close()
+
+	opts := FetchOptions{
+		BaseURL:       srv.URL,
+		Anchor:        testDay,
+		DateParam:     "day",
+		MarketParam:   "mkt",
+		AreaParam:     "zone",
+		CurrencyParam: "cur",
+		DateLayout:    "02.01.2006",
+	}
+	slots, err := FetchNordpoolPricesWithOptions(context.Background(), "LV", "DayAhead", "EUR", opts)
+	if err != nil {
+		t.Fatalf("%v (queries %q)", err, queries)
+	}
+	if len(slots) != 2 {
+		t.Fatalf("got %d slots, want 2 (queries %q)", len(slots), queries)
+	}
+}
+
+func TestSecondaryCurrency(t *testing.T) {
+	prices := hourly(testDay, 2, 30)
+	for i := range prices {
+		prices[i].ExchangeRate = 11.5 // SEK per EUR
+	}
+	tests := []struct {
+		name     string
+		currency string
+		rate     float64
+		want     []float64 // secondary price of each charge and discharge slot; nil for none
+	}{
+		{"response rate", "SEK", 0, []float64{23, 345}},
+		{"override rate", "SEK", 10, []float64{20, 300}},
+		{"off", "", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := BatteryStrategyParams{
+				MaxChargeHours: 1, MaxDischargeHours: 1, LastPriceCharged: 10, Epsilon: 1,
+				SecondaryCurrency: tt.currency, SecondaryRate: tt.rate,
+			}
+			s := BuildBatterySchedule(prices, params, testDay)
+			if len(s.ChargeSlots) != 1 || len(s.DischargeSlots) != 1 {
+				t.Fatalf("got %d charge and %d discharge slots, want 1 each", len(s.ChargeSlots), len(s.DischargeSlots))
+			}
+			slots := []SlotJSON{s.ChargeSlots[0], s.DischargeSlots[0]}
+			for i, sj := range slots {
+				if tt.want == nil {
+					if sj.SecondaryPrice != nil {
+						t.Errorf("slot %d: secondary price %v, want none", i, *sj.SecondaryPrice)
+					}
+					continue
+				}
+				if sj.Price != prices[i].Price {
+					t.Errorf("slot %d: primary price %v changed from %v", i, sj.Price, prices[i].Price)
+				}
+				if sj.SecondaryPrice == nil || math.Abs(*sj.SecondaryPrice-tt.want[i]) > 1e-9 {
+					t.Errorf("slot %d: secondary price %v, want %v", i, fmtPtr(sj.SecondaryPrice), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFetchSendsAPIKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		header    string
+		wantName  string
+		wantValue string
+	}{
+		{"bearer by default", "s3cret", "", "Authorization", "Bearer s3cret"},
+		{"custom header carries the raw key", "s3cret", "X-Api-Key", "X-Api-Key", "s3cret"},
+		{"no key", "", "", "Authorization", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				got = append(got, r.Header.Get(tt.wantName))
+				mu.Unlock()
+				io.WriteString(w, dayAheadBody(requestDay(t, r), "LV", 10))
+			}))
+			defer srv.Close()
+
+			opts := FetchOptions{BaseURL: srv.URL, Anchor: testDay, APIKey: tt.key, APIKeyHeader: tt.header}
+			if _, err := FetchNordpoolPricesWithOptions(context.Background(), "LV", "DayAhead", "EUR", opts); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 2 {
+				t.Fatalf("got %d requests, want 2", len(got))
+			}
+			for _, v := range got {
+				if v != tt.wantValue {
+					t.Errorf("%s = %q, want %q", tt.wantName, v, tt.wantValue)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundToDecimals(t *testing.T) {
+	two, five := 2, 5
+	tests := []struct {
+		name   string
+		round  *int
+		charge []int
+	}{
+		{"raw", nil, []int{1}},
+		{"rounded to cents tie on the earlier slot", &two, []int{0}},
+		{"rounding finer than the difference", &five, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices := hourly(testDay, 3.00004, 3.00001, 9)
+			params := BatteryStrategyParams{MaxChargeHours: 1, LastPriceCharged: 10, Epsilon: 1, RoundToDecimals: tt.round}
+			s := BuildBatterySchedule(prices, params, testDay)
+			if got := slotHours(t, s.ChargeSlots); !equalInts(got, tt.charge) {
+				t.Fatalf("charge hours = %v, want %v", got, tt.charge)
+			}
+			if want := prices[tt.charge[0]].Price; s.ChargeSlots[0].Price != want {
+				t.Errorf("slot price = %v, want the unrounded %v", s.ChargeSlots[0].Price, want)
+			}
+		})
+	}
+}
+
+func TestParseDayAheadResponse(t *testing.T) {
+	start := time.Date(2025, 1, 14, 23, 0, 0, 0, time.UTC)
+	tests := []struct {
+		area string
+		want []float64 // cents/kWh from start, hourly
+	}{
+		{"LV", []float64{8.02, 10.15, 10.36}},
+		{"EE", []float64{8.02, 7.485, -0.13}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.area, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", "dayahead_2025-01-15.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			got, err := ParseDayAheadResponse(f, tt.area)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d slots, want %d", len(got), len(tt.want))
+			}
+			for i, s := range got {
+				if ts := start.Add(time.Duration(i) * time.Hour); !s.Timestamp.Equal(ts) {
+					t.Errorf("slot %d at %v, want %v", i, s.Timestamp, ts)
+				}
+				if math.Abs(s.Price-tt.want[i]) > 1e-9 {
+					t.Errorf("slot %d price = %v, want %v", i, s.Price, tt.want[i])
+				}
+			}
+		})
+	}
+
+	t.Run("empty body", func(t *testing.T) {
+		got, err := ParseDayAheadResponse(strings.NewReader(""), "LV")
+		if err != nil || len(got) != 0 {
+			t.Errorf("got %v, %v; want no slots and no error", got, err)
+		}
+	})
+}
+
+func TestPreferEarly(t *testing.T) {
+	tests := []struct {
+		name        string
+		prices      []float64
+		preferEarly float64
+		charge      []int
+	}{
+		{"equal prices", []float64{2, 9, 9, 2}, 0.1, []int{0}},
+		{"slightly cheaper later slot by price alone", []float64{2, 9, 9, 1.9}, 0, []int{3}},
+		{"slightly cheaper later slot preferring early", []float64{2, 9, 9, 1.9}, 0.1, []int{0}},
+		{"much cheaper later slot still wins", []float64{2, 9, 9, 1}, 0.1, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := BatteryStrategyParams{MaxChargeHours: 1, LastPriceCharged: 10, Epsilon: 1, PreferEarly: tt.preferEarly}
+			s := BuildBatterySchedule(hourly(testDay, tt.prices...), params, testDay)
+			if got := slotHours(t, s.ChargeSlots); !equalInts(got, tt.charge) {
+				t.Errorf("charge hours = %v, want %v", got, tt.charge)
+			}
+		})
+	}
+}
+
+func TestResolutionFromDeliveryEnd(t *testing.T) {
+	entry := func(start string, minutes int) string {
+		s, _ := time.Parse(time.RFC3339, start)
+		return fmt.Sprintf(`{"deliveryStart": %q, "deliveryEnd": %q, "entryPerArea": {"LV": 50}}`,
+			start, s.Add(time.Duration(minutes)*time.Minute).Format(time.RFC3339))
+	}
+	tests := []struct {
+		name    string
+		entries []string
+		want    int
+	}{
+		{"contiguous quarter hours", []string{
+			entry("2025-01-15T00:00:00Z", 15), entry("2025-01-15T00:15:00Z", 15), entry("2025-01-15T00:30:00Z", 15),
+		}, 15},
+		// Start deltas alone would suggest hourly slots.
+		{"quarter hours an hour apart", []string{
+			entry("2025-01-15T00:00:00Z", 15), entry("2025-01-15T01:00:00Z", 15), entry("2025-01-15T02:00:00Z", 15),
+		}, 15},
+		{"hourly", []string{
+			entry("2025-01-15T00:00:00Z", 60), entry("2025-01-15T01:00:00Z", 60),
+		}, 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"deliveryDateCET": "2025-01-15", "currency": "EUR", "multiAreaEntries": [` + strings.Join(tt.entries, ",") + `]}`
+			slots, err := ParseDayAheadResponse(strings.NewReader(body), "LV")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range slots {
+				if s.DurationMinutes != tt.want {
+					t.Errorf("slot at %v lasts %d minutes, want %d", s.Timestamp, s.DurationMinutes, tt.want)
+				}
+			}
+			if got := inferResolutionMinutes(slots); got != tt.want {
+				t.Errorf("resolution = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchAnchorDates(t *testing.T) {
+	tests := []struct {
+		name   string
+		anchor time.Time
+		want   []string
+	}{
+		{"midnight", time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), []string{"2024-03-10", "2024-03-11"}},
+		{"late evening", time.Date(2024, 3, 10, 23, 59, 0, 0, time.UTC), []string{"2024-03-10", "2024-03-11"}},
+		{"year end in another zone", time.Date(2025, 1, 1, 0, 30, 0, 0, time.FixedZone("CET", 3600)), []string{"2024-12-31", "2025-01-01"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var dates []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				day := requestDay(t, r)
+				mu.Lock()
+				dates = append(dates, day.Format("2006-01-02"))
+				mu.Unlock()
+				io.WriteString(w, dayAheadBody(day, "LV", 10))
+			}))
+			defer srv.Close()
+
+			if _, err := FetchNordpoolPricesWithOptions(context.Background(), "LV", "DayAhead", "EUR", FetchOptions{BaseURL: srv.URL, Anchor: tt.anchor}); err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(dates)
+			if strings.Join(dates, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("requested %v, want %v", dates, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitAtDayBoundary(t *testing.T) {
+	// Charge 22:00-02:00 across midnight into 2025-01-16.
+	start := testDay.Add(20 * time.Hour)
+	prices := hourly(start, 9, 9, 1, 2, 2, 1, 9, 9)
+	tests := []struct {
+		name  string
+		split bool
+		want  []IntervalJSON
+	}{
+		{"contiguous", false, []IntervalJSON{
+			{Start: "2025-01-15T22:00:00Z", End: "2025-01-16T02:00:00Z", AvgPrice: 1.5},
+		}},
+		{"split", true, []IntervalJSON{
+			{Start: "2025-01-15T22:00:00Z", End: "2025-01-16T00:00:00Z", AvgPrice: 1.5},
+			{Start: "2025-01-16T00:00:00Z", End: "2025-01-16T02:00:00Z", AvgPrice: 1.5},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := BatteryStrategyParams{MaxChargeHours: 4, LastPriceCharged: 8, Epsilon: 1, SplitAtDayBoundary: tt.split}
+			s := BuildBatterySchedule(prices, params, start)
+			if len(s.ChargeSlots) != 4 {
+				t.Fatalf("got %d charge slots, want 4", len(s.ChargeSlots))
+			}
+			if len(s.ChargeIntervals) != len(tt.want) {
+				t.Fatalf("intervals = %+v, want %+v", s.ChargeIntervals, tt.want)
+			}
+			for i, iv := range s.ChargeIntervals {
+				if iv != tt.want[i] {
+					t.Errorf("interval %d = %+v, want %+v", i, iv, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDedupeSlots(t *testing.T) {
+	h := func(n int) time.Time { return testDay.Add(time.Duration(n) * time.Hour) }
+	tests := []struct {
+		name  string
+		slots []PriceSlot
+		want  []PriceSlot
+	}{
+		{"no duplicates", hourly(testDay, 1, 2), hourly(testDay, 1, 2)},
+		{"appended correction", []PriceSlot{
+			{Timestamp: h(0), Price: 1}, {Timestamp: h(1), Price: 2}, {Timestamp: h(0), Price: 3},
+		}, []PriceSlot{{Timestamp: h(1), Price: 2}, {Timestamp: h(0), Price: 3}}},
+		{"same instant in another zone", []PriceSlot{
+			{Timestamp: h(0), Price: 1}, {Timestamp: h(0).In(time.FixedZone("CET", 3600)), Price: 4},
+		}, []PriceSlot{{Timestamp: h(0).In(time.FixedZone("CET", 3600)), Price: 4}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dedupeSlots(tt.slots)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Timestamp.Equal(tt.want[i].Timestamp) || got[i].Price != tt.want[i].Price {
+					t.Errorf("slot %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFetchDropsDuplicateTimestamps(t *testing.T) {
+	const body = `{"deliveryDateCET": "2025-01-15", "currency": "EUR", "multiAreaEntries": [
+		{"deliveryStart": "2025-01-15T00:00:00Z", "deliveryEnd": "2025-01-15T01:00:00Z", "entryPerArea": {"LV": 40}},
+		{"deliveryStart": "2025-01-15T01:00:00Z", "deliveryEnd": "2025-01-15T02:00:00Z", "entryPerArea": {"LV": 50}},
+		{"deliveryStart": "2025-01-15T00:00:00Z", "deliveryEnd": "2025-01-15T01:00:00Z", "entryPerArea": {"LV": 45}}
+	]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestDay(t, r).Equal(testDay) {
+			io.WriteString(w, body)
+		}
+	}))
+	defer srv.Close()
+
+	got, err := FetchNordpoolPricesWithOptions(context.Background(), "LV", "DayAhead", "EUR", FetchOptions{BaseURL: srv.URL, Anchor: testDay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[0].Timestamp.Equal(testDay) || got[0].Price != 4.5 || got[1].Price != 5 {
+		t.Fatalf("got %+v, want 00:00 at the corrected 4.5 then 01:00 at 5", got)
+	}
+}
+
+func TestParseMissingArea(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   string
+		wantSlots int
+		want      ParseStats
+	}{
+		{"all present", `
+			{"deliveryStart": "2025-01-15T00:00:00Z", "entryPerArea": {"LV": 40, "EE": 41}},
+			{"deliveryStart": "2025-01-15T01:00:00Z", "entryPerArea": {"LV": 50, "EE": 51}}`,
+			2, ParseStats{Entries: 2, Currency: "EUR"}},
+		{"area missing from some entries", `
+			{"deliveryStart": "2025-01-15T00:00:00Z", "entryPerArea": {"LV": 40, "EE": 41}},
+			{"deliveryStart": "2025-01-15T01:00:00Z", "entryPerArea": {"EE": 51}},
+			{"deliveryStart": "2025-01-15T02:00:00Z", "entryPerArea": {}}`,
+			1, ParseStats{Entries: 3, MissingArea: 2, EmptyAreaMaps: 1, Currency: "EUR"}},
+		{"area absent everywhere", `
+			{"deliveryStart": "2025-01-15T00:00:00Z", "entryPerArea": {"EE": 41}}`,
+			0, ParseStats{Entries: 1, MissingArea: 1, Currency: "EUR"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"deliveryDateCET": "2025-01-15", "currency": "EUR", "multiAreaEntries": [` + tt.entries + `]}`
+			slots, stats, err := ParseDayAheadResponseWithStats(strings.NewReader(body), "LV")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(slots) != tt.wantSlots || stats != tt.want {
+				t.Errorf("got %d slots and %+v, want %d and %+v", len(slots), stats, tt.wantSlots, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchReportsMissingArea(t *testing.T) {
+	const body = `{"deliveryDateCET": "2025-01-15", "currency": "EUR", "multiAreaEntries": [
+		{"deliveryStart": "2025-01-15T00:00:00Z", "deliveryEnd": "2025-01-15T01:00:00Z", "entryPerArea": {"LV": 40}},
+		{"deliveryStart": "2025-01-15T01:00:00Z", "deliveryEnd": "2025-01-15T02:00:00Z", "entryPerArea": {"EE": 50}}
+	]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestDay(t, r).Equal(testDay) {
+			io.WriteString(w, body)
+		}
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	reported := map[time.Time]ParseStats{}
+	opts := FetchOptions{BaseURL: srv.URL, Anchor: testDay, OnMissingArea: func(day time.Time, stats ParseStats) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported[day] = stats
+	}}
+	got, err := FetchNordpoolPricesWithOptions(context.Background(), "LV", "DayAhead", "EUR", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Errorf("got %d slots, want 1", len(got))
+	}
+	// Tomorrow's empty response is not a missing-area problem.
+	if len(reported) != 1 || reported[testDay].MissingArea != 1 {
+		t.Errorf("reported %+v, want one missing entry on %v", reported, testDay)
+	}
+}
+
+func TestEmptyAreaMaps(t *testing.T) {
+	const allEmpty = `{"deliveryDateCET": "2025-01-15", "currency": "EUR", "multiAreaEntries": [
+		{"deliveryStart": "2025-01-15T00:00:00Z", "deliveryEnd": "2025-01-15T01:00:00Z", "entryPerArea": {}},
+		{"deliveryStart": "2025-01-15T01:00:00Z", "deliveryEnd": "2025-01-15T02:00:00Z", "entryPerArea": {}}
+	]}`
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{"all maps empty", allEmpty, ErrNoAreaPrices},
+		{"no entries", `{"deliveryDateCET": "2025-01-15", "multiAreaEntries": []}`, nil},
+		{"empty body", "", nil},
+		{"one map empty", `{"multiAreaEntries": [
+			{"deliveryStart": "2025-01-15T00:00:00Z", "entryPerArea": {}},
+			{"deliveryStart": "2025-01-15T01:00:00Z", "entryPerArea": {"LV": 50}}
+		]}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDayAheadResponse(strings.NewReader(tt.body), "LV")
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("fetch warns", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requestDay(t, r).Equal(testDay) {
+				io.WriteString(w, allEmpty)
+			}
+		}))
+		defer srv.Close()
+
+		var mu sync.Mutex
+		var reported []ParseStats
+		opts := FetchOptions{BaseURL: srv.URL, Anchor: testDay, OnMissingArea: func(_ time.Time, stats ParseStats) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, stats)
+		}}
+		got, err := FetchNordpoolPricesWithOptions(context.Background(), "LV", "DayAhead", "EUR", opts)
+		if err != nil || len(got) != 0 {
+			t.Fatalf("got %d slots, err %v; want none and no error", len(got), err)
+		}
+		if len(reported) != 1 || reported[0].EmptyAreaMaps != reported[0].Entries {
+			t.Errorf("reported %+v, want one all-empty day", reported)
+		}
+	})
+}
+
+func TestExportPrices(t *testing.T) {
+	spot := hourly(testDay, 1, 20, 15, 9)
+	tests := []struct {
+		name      string
+		export    []PriceSlot
+		discharge []int
+		price     float64 // spot, always
+		exported  float64 // ExportPrice; 0 means unset
+	}{
+		{"spot only", nil, []int{1}, 20, 0},
+		{"export reorders", hourly(testDay.Add(time.Hour), 4, 12), []int{2}, 15, 12},
+		// 03:00 has no export price and falls back to its 9c spot.
+		{"missing export falls back", hourly(testDay.Add(time.Hour), 4, 5), []int{3}, 9, 0},
+		{"nothing worth exporting", hourly(testDay.Add(time.Hour), 2, 3, 4), []int{}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := BatteryStrategyParams{MaxChargeHours: 1, MaxDischargeHours: 1, LastPriceCharged: 5, Epsilon: 1, ExportPrices: tt.export}
+			s := BuildBatterySchedule(spot, params, testDay)
+			if got := slotHours(t, s.ChargeSlots); !equalInts(got, []int{0}) {
+				t.Errorf("charge hours = %v, want [0] from spot", got)
+			}
+			if s.ChargeSlots[0].ExportPrice != nil {
+				t.Errorf("charge slot has export price %v", *s.ChargeSlots[0].ExportPrice)
+			}
+			if got := slotHours(t, s.DischargeSlots); !equalInts(got, tt.discharge) {
+				t.Fatalf("discharge hours = %v, want %v", got, tt.discharge)
+			}
+			if len(s.DischargeSlots) == 0 {
+				return
+			}
+			d := s.DischargeSlots[0]
+			if d.Price != tt.price {
+				t.Errorf("discharge price = %v, want spot %v", d.Price, tt.price)
+			}
+			switch {
+			case tt.exported == 0 && d.ExportPrice != nil:
+				t.Errorf("export price = %v, want none", *d.ExportPrice)
+			case tt.exported != 0 && (d.ExportPrice == nil || *d.ExportPrice != tt.exported):
+				t.Errorf("export price = %v, want %v", fmtPtr(d.ExportPrice), tt.exported)
+			}
+			if iv := s.DischargeIntervals; len(iv) != 1 || iv[0].AvgPrice != tt.price {
+				t.Errorf("discharge intervals = %+v, want one at spot %v", iv, tt.price)
+			}
+		})
+	}
+}
+
+func TestScheduleWarnings(t *testing.T) {
+	tests := []struct {
+		name   string
+		prices []PriceSlot
+		now    time.Time
+		params BatteryStrategyParams
+		want   []string // prefixes, in order
+	}{
+		{
+			name:   "empty horizon",
+			prices: hourly(testDay, 1, 2),
+			now:    testDay.Add(3 * time.Hour),
+			params: BatteryStrategyParams{MaxChargeHours: 1, MaxDischargeHours: 1, LastPriceCharged: 5, Epsilon: 1},
+			want:   []string{"no future price slots to plan"},
+		},
+		{
+			name:   "epsilon too strict",
+			prices: hourly(testDay, 4, 5, 20),
+			now:    testDay,
+			params: BatteryStrategyParams{MaxChargeHours: 1, MaxDischargeHours: 1, LastPriceCharged: 5, Epsilon: 2},
+			want:   []string{"no charge: no slot is at least epsilon (2.00 c/kWh) below"},
+		},
+		{
+			// The threshold is min(lastPriceCharged+epsilon, 8).
+			name:   "threshold too high",
+			prices: hourly(testDay, 1, 5, 5.5),
+			now:    testDay,
+			params: BatteryStrategyParams{MaxChargeHours: 1, MaxDischargeHours: 1, LastPriceCharged: 5, Epsilon: 1},
+			want:   []string{"no discharge: no slot reaches the discharge threshold (6.00 c/kWh)"},
+		},
+		{
+			name:   "10h on a 6h horizon",
+			prices: hourly(testDay, 1, 2, 3, 20, 21, 22),
+			now:    testDay,
+			params: BatteryStrategyParams{MaxChargeHours: 10, MaxDischargeHours: 10, LastPriceCharged: 5, Epsilon: 1},
+			want: []string{
+				"charge: MaxChargeHours asks for 10 slots but the horizon has only 6; clamped to 6",
+				"discharge: MaxDischargeHours asks for 10 slots but the horizon has only 6; clamped to 6",
+			},
+		},
+		{
+			name:   "discharge only exceeds the horizon",
+			prices: hourly(testDay, 1, 2, 3, 20, 21, 22),
+			now:    testDay,
+			params: BatteryStrategyParams{MaxChargeHours: 3, MaxDischargeHours: 7, LastPriceCharged: 5, Epsilon: 1},
+			want:   []string{"discharge: MaxDischargeHours asks for 7 slots but the horizon has only 6"},
+		},
+		{
+			name:   "plan found",
+			prices: hourly(testDay, 1, 5, 20),
+			now:    testDay,
+			params: BatteryStrategyParams{MaxChargeHours: 1, MaxDischargeHours: 1, LastPriceCharged: 5, Epsilon: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildBatterySchedule(tt.prices, tt.params, tt.now).Warnings
+			if len(got) != len(tt.want) {
+				t.Fatalf("warnings = %q, want prefixes %q", got, tt.want)
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("warning %d = %q, want prefix %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTomorrowRetries(t *testing.T) {
+	tomorrow := testDay.Add(24 * time.Hour)
+	tests := []struct {
+		name          string
+		retries       int
+		emptyAttempts int32 // tomorrow answers empty this many times first
+		wantSlots     int
+		wantTomorrow  int32 // requests for tomorrow
+	}{
+		{"no retries", 0, 1, 1, 1},
+		{"published on the second attempt", 2, 1, 2, 2},
+		{"never published", 2, 10, 1, 3},
+		{"already published", 2, 0, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var today, tomorrowRequests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				day := requestDay(t, r)
+				if !day.Equal(tomorrow) {
+					today.Add(1)
+					io.WriteString(w, dayAheadBody(day, "LV", 10))
+					return
+				}
+				if tomorrowRequests.Add(1) <= tt.emptyAttempts {
+					return // valid but empty: not published yet
+				}
+				io.WriteString(w, dayAheadBody(day, "LV", 20))
+			}))
+			defer srv.Close()
+
+			opts := FetchOptions{BaseURL: srv.URL, Anchor: testDay, TomorrowRetries: tt.retries, TomorrowRetryDelay: time.Millisecond}
+			got, err := FetchNordpoolPricesWithOptions(context.Background(), "LV", "DayAhead", "EUR", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.wantSlots {
+				t.Errorf("got %d slots, want %d", len(got), tt.wantSlots)
+			}
+			if n := tomorrowRequests.Load(); n != tt.wantTomorrow {
+				t.Errorf("tomorrow requested %d times, want %d", n, tt.wantTomorrow)
+			}
+			if n := today.Load(); n != 1 {
+				t.Errorf("today requested %d times, want 1", n)
+			}
+		})
+	}
+}
+
+func TestFetchLogsSkippedEntries(t *testing.T) {
+	const body = `{"deliveryDateCET": "2025-01-15", "currency": "EUR", "multiAreaEntries": [
+		{"deliveryStart": "2025-01-15T00:00:00Z", "deliveryEnd": "2025-01-15T01:00:00Z", "entryPerArea": {"LV": 40}},
+		{"deliveryStart": "yesterday-ish", "deliveryEnd": "2025-01-15T02:00:00Z", "entryPerArea": {"LV": 50}},
+		{"deliveryStart": "2025-01-15T02:00:00Z", "deliveryEnd": "2025-01-15T03:00:00Z", "entryPerArea": {"EE": 60}}
+	]}`
+	tests := []struct {
+		name    string
+		body    string
+		wantLog []string
+	}{
+		{"bad timestamp and missing area", body, []string{
+			`level=WARN msg="skipped day-ahead entries" day=2025-01-15 area=LV entries=3 bad_timestamps=1 missing_area=1 empty_area_maps=0`,
+		}},
+		{"clean payload", dayAheadBody(testDay, "LV", 10), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requestDay(t, r).Equal(testDay) {
+					io.WriteString(w, tt.body)
+				}
+			}))
+			defer srv.Close()
+
+			// TextHandler serializes writes from the concurrent day fetches.
+			var buf strings.Builder
+			handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return a
+				},
+			})
+			opts := FetchOptions{BaseURL: srv.URL, Anchor: testDay, Logger: slog.New(handler)}
+			if _, err := FetchNordpoolPricesWithOptions(context.Background(), "LV", "DayAhead", "EUR", opts); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			if s := strings.TrimSpace(buf.String()); s != "" {
+				got = strings.Split(s, "\n")
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.wantLog, "\n") {
+				t.Errorf("log = %q, want %q", got, tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestFetchCurrencyMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Upstream ignores the currency param and always answers in EUR.
+		io.WriteString(w, dayAheadBody(requestDay(t, r), "SE3", 10, 20))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		currency string
+		wantErr  error
+	}{
+		{"EUR", nil},
+		{"eur", nil},
+		{"SEK", ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			opts := FetchOptions{BaseURL: srv.URL, Anchor: testDay}
+			got, err := FetchNordpoolPricesWithOptions(context.Background(), "SE3", "DayAhead", tt.currency, opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if got != nil {
+					t.Errorf("got %d slots alongside the error", len(got))
+				}
+				if !strings.Contains(err.Error(), "requested SEK, got EUR") {
+					t.Errorf("error %q does not name both currencies", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSmoothingAlpha(t *testing.T) {
+	// A single-slot spike at 01:00 and a sustained evening peak.
+	prices := hourly(testDay, 10, 30, 10, 10, 20, 22, 21, 10)
+	tests := []struct {
+		name      string
+		alpha     float64
+		wantHours []int
+		wantPrice float64
+	}{
+		{"raw", 0, []int{1}, 30},
+		{"out of range disables", 1, []int{1}, 30},
+		// Smoothed levels peak at 06:00 (18.30) rather than the spike (16).
+		{"smoothed", 0.3, []int{6}, 21},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := BatteryStrategyParams{MaxDischargeHours: 1, LastPriceCharged: 5, Epsilon: 1, SmoothingAlpha: tt.alpha}
+			got := BuildBatterySchedule(prices, params, testDay)
+			if hours := slotHours(t, got.DischargeSlots); !equalInts(hours, tt.wantHours) {
+				t.Fatalf("discharge hours = %v, want %v", hours, tt.wantHours)
+			}
+			// Output slots keep raw prices.
+			if p := got.DischargeSlots[0].Price; p != tt.wantPrice {
+				t.Errorf("discharge price = %v, want raw %v", p, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestForecastOverride(t *testing.T) {
+	riga, err := time.LoadLocation("Europe/Riga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prices := hourly(testDay, 1, 10, 20, 12)
+	at := func(h int) time.Time { return testDay.Add(time.Duration(h) * time.Hour) }
+	tests := []struct {
+		name          string
+		override      map[time.Time]float64
+		wantDischarge []int
+		wantCharge    []int
+	}{
+		{"none", nil, []int{2}, []int{0}},
+		{"higher forecast promotes a slot", map[time.Time]float64{at(3): 25}, []int{3}, []int{0}},
+		{"keys in any zone", map[time.Time]float64{at(3).In(riga): 25}, []int{3}, []int{0}},
+		{"lower forecast demotes a slot", map[time.Time]float64{at(2): 3}, []int{3}, []int{0}},
+		{"charge side too", map[time.Time]float64{at(0): 9, at(1): 2}, []int{2}, []int{1}},
+		{"unmatched keys ignored", map[time.Time]float64{at(9): 50}, []int{2}, []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := BatteryStrategyParams{MaxChargeHours: 1, MaxDischargeHours: 1, LastPriceCharged: 5, Epsilon: 1, ForecastOverride: tt.override}
+			got := BuildBatterySchedule(prices, params, testDay)
+			if hours := slotHours(t, got.DischargeSlots); !equalInts(hours, tt.wantDischarge) {
+				t.Errorf("discharge hours = %v, want %v", hours, tt.wantDischarge)
+			}
+			if hours := slotHours(t, got.ChargeSlots); !equalInts(hours, tt.wantCharge) {
+				t.Errorf("charge hours = %v, want %v", hours, tt.wantCharge)
+			}
+			// Output slots keep the day-ahead price.
+			for _, s := range append(got.ChargeSlots, got.DischargeSlots...) {
+				ts, _ := time.Parse(time.RFC3339, s.Timestamp)
+				if want := prices[ts.Sub(testDay)/time.Hour].Price; s.Price != want {
+					t.Errorf("slot %s price = %v, want day-ahead %v", s.Timestamp, s.Price, want)
+				}
+			}
+		})
+	}
+}
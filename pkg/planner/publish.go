@@ -0,0 +1,100 @@
+package planner
+
+import "time"
+
+// defaultPublishAt is when Nord Pool usually publishes day-ahead prices.
+const defaultPublishAt = 12*time.Hour + 45*time.Minute
+
+// marketLocation is the timezone publish times are expressed in. It falls
+// back to a fixed CET offset when tzdata is unavailable.
+var marketLocation = func() *time.Location {
+	if loc, err := time.LoadLocation("Europe/Oslo"); err == nil {
+		return loc
+	}
+	return time.FixedZone("CET", 3600)
+}()
+
+// publishTime returns when prices for the UTC delivery day containing day are
+// expected: publishAt on the previous calendar day in market time. A zero
+// publishAt means defaultPublishAt.
+func publishTime(day time.Time, publishAt time.Duration) time.Time {
+	if publishAt <= 0 {
+		publishAt = defaultPublishAt
+	}
+	d := day.UTC()
+	return time.Date(d.Year(), d.Month(), d.Day()-1, 0, 0, 0, int(publishAt), marketLocation)
+}
+
+// TimeToNextPublish returns the time from now until the next day-ahead
+// publication at publishHourCET:00 market time (Europe/Oslo, so CET or CEST):
+// today's if now is not past it yet, otherwise tomorrow's.
+func TimeToNextPublish(now time.Time, publishHourCET int) time.Duration {
+	n := now.In(marketLocation)
+	next := time.Date(n.Year(), n.Month(), n.Day(), publishHourCET, 0, 0, 0, marketLocation)
+	if next.Before(n) {
+		next = time.Date(n.Year(), n.Month(), n.Day()+1, publishHourCET, 0, 0, 0, marketLocation)
+	}
+	return next.Sub(now)
+}
+
+// TomorrowSlots returns the slots delivered on the calendar day after now's,
+// both taken in market time, keeping their order.
+func TomorrowSlots(prices []PriceSlot, now time.Time) []PriceSlot {
+	n := now.In(marketLocation)
+	start := time.Date(n.Year(), n.Month(), n.Day()+1, 0, 0, 0, 0, marketLocation)
+	end := time.Date(n.Year(), n.Month(), n.Day()+2, 0, 0, 0, 0, marketLocation)
+	var out []PriceSlot
+	for _, p := range prices {
+		if !p.Timestamp.Before(start) && p.Timestamp.Before(end) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Default evening window for BatteryStrategyParams.PreferEveningDischarge.
+const (
+	defaultEveningStartHour = 17
+	defaultEveningEndHour   = 21
+)
+
+// eveningWindow reports whether a timestamp falls in params' evening window
+// in market time; it always reports false unless PreferEveningDischarge is set.
+func eveningWindow(params BatteryStrategyParams) func(time.Time) bool {
+	if !params.PreferEveningDischarge {
+		return func(time.Time) bool { return false }
+	}
+	start, end := params.EveningStartHour, params.EveningEndHour
+	if start == 0 && end == 0 {
+		start, end = defaultEveningStartHour, defaultEveningEndHour
+	}
+	return func(ts time.Time) bool {
+		h := ts.In(marketLocation).Hour()
+		if start <= end {
+			return h >= start && h < end
+		}
+		return h >= start || h < end
+	}
+}
+
+// MarketDayKey returns the delivery day (YYYY-MM-DD) of ts in market time,
+// as used for ScheduleJSON.AreaAverages.
+func MarketDayKey(ts time.Time) string {
+	return ts.In(marketLocation).Format("2006-01-02")
+}
+
+// areaAverages collects the upstream daily averages carried by prices per
+// market delivery day; nil when none are known.
+func areaAverages(prices []PriceSlot) map[string]float64 {
+	var out map[string]float64
+	for _, p := range prices {
+		if !p.HasAreaAverage {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]float64)
+		}
+		out[MarketDayKey(p.Timestamp)] = p.AreaAverage
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package planner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublishTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		day       time.Time
+		publishAt time.Duration
+		want      time.Time
+	}{
+		// 12:45 CET is 11:45 UTC in winter and 10:45 UTC in summer (CEST).
+		{"winter default", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC), 0, time.Date(2025, 1, 15, 11, 45, 0, 0, time.UTC)},
+		{"summer default", time.Date(2025, 7, 16, 0, 0, 0, 0, time.UTC), 0, time.Date(2025, 7, 15, 10, 45, 0, 0, time.UTC)},
+		{"custom hour", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC), 14 * time.Hour, time.Date(2025, 1, 15, 13, 0, 0, 0, time.UTC)},
+		{"any time of day", time.Date(2025, 1, 16, 23, 0, 0, 0, time.UTC), 0, time.Date(2025, 1, 15, 11, 45, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := publishTime(tt.day, tt.publishAt); !got.Equal(tt.want) {
+				t.Errorf("publishTime = %v, want %v", got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTomorrowOnly(t *testing.T) {
+	// Two UTC days: today's remaining hours are the cheapest, tomorrow has a
+	// cheap night and an evening peak. Tomorrow in market time (CET) runs
+	// from 23:00 UTC on testDay to 23:00 UTC the next day.
+	prices := make([]float64, 48)
+	for i := range prices {
+		prices[i] = 10
+	}
+	for i := 18; i < 23; i++ {
+		prices[i] = 1
+	}
+	prices[26], prices[27] = 3, 3
+	prices[41], prices[42] = 20, 20
+	slots := hourly(testDay, prices...)
+	now := testDay.Add(18 * time.Hour)
+	tomorrowStart, tomorrowEnd := testDay.Add(23*time.Hour), testDay.Add(47*time.Hour)
+
+	if got := TomorrowSlots(slots, now); len(got) != 24 || !got[0].Timestamp.Equal(tomorrowStart) {
+		t.Fatalf("TomorrowSlots: %d slots from %v, want 24 from %v", len(got), got[0].Timestamp, tomorrowStart)
+	}
+
+	tests := []struct {
+		name   string
+		only   bool
+		charge []int
+	}{
+		{"whole horizon", false, []int{18, 19}},
+		{"tomorrow only", true, []int{2, 3}}, // 02:00 and 03:00 UTC on 01-16
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := BatteryStrategyParams{MaxChargeHours: 2, MaxDischargeHours: 2, LastPriceCharged: 5, Epsilon: 1, TomorrowOnly: tt.only}
+			s := BuildBatterySchedule(slots, params, now)
+			if got := slotHours(t, s.ChargeSlots); !equalInts(got, tt.charge) {
+				t.Errorf("charge hours = %v, want %v", got, tt.charge)
+			}
+			if !tt.only {
+				return
+			}
+			for _, sl := range append(s.ChargeSlots, s.DischargeSlots...) {
+				ts, _ := time.Parse(time.RFC3339, sl.Timestamp)
+				if ts.Before(tomorrowStart) || !ts.Before(tomorrowEnd) {
+					t.Errorf("slot %s is outside tomorrow", sl.Timestamp)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeToNextPublish(t *testing.T) {
+	riga, err := time.LoadLocation("Europe/Riga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(mo time.Month, d, h, m int) time.Time { return time.Date(2025, mo, d, h, m, 0, 0, time.UTC) }
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Duration
+	}{
+		// 13:00 market time is 12:00 UTC in winter and 11:00 UTC in summer.
+		{"winter before", utc(time.January, 15, 10, 0), 2 * time.Hour},
+		{"winter at", utc(time.January, 15, 12, 0), 0},
+		{"winter after", utc(time.January, 15, 12, 30), 23*time.Hour + 30*time.Minute},
+		{"summer before", utc(time.July, 1, 10, 0), time.Hour},
+		{"summer after", utc(time.July, 1, 11, 1), 23*time.Hour + 59*time.Minute},
+		// Across a DST change the next publication is 23h or 25h later.
+		{"spring forward", utc(time.March, 29, 12, 1), 22*time.Hour + 59*time.Minute},
+		{"fall back", utc(time.October, 25, 11, 30), 24*time.Hour + 30*time.Minute},
+		{"zone of now ignored", utc(time.January, 15, 10, 0).In(riga), 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TimeToNextPublish(tt.now, 13); got != tt.want {
+				t.Errorf("TimeToNextPublish(%v) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
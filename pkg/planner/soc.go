@@ -0,0 +1,291 @@
+package planner
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// socTolerance absorbs float noise when comparing modeled SoC to a target.
+const socTolerance = 1e-9
+
+// batteryModel returns usable capacity and per-slot energy (kWh). ok is false
+// when no capacity is configured and SoC modeling is disabled. PowerKW
+// defaults to one full capacity per hour.
+func batteryModel(params BatteryStrategyParams, resolutionMinutes int) (capacity, perSlot float64, ok bool) {
+	if params.CapacityKWh <= 0 {
+		return 0, 0, false
+	}
+	power := params.PowerKW
+	if power <= 0 {
+		power = params.CapacityKWh
+	}
+	return params.CapacityKWh, power * float64(resolutionMinutes) / 60, true
+}
+
+// socWalk models the battery's state of charge (kWh) across future slots in
+// time order and returns the level after each slot. Charging is capped at
+// capacity and discharging at empty.
+func socWalk(future []PriceSlot, charge, discharge map[time.Time]bool, params BatteryStrategyParams, resolutionMinutes int) []float64 {
+	capacity, perSlot, ok := batteryModel(params, resolutionMinutes)
+	if !ok {
+		return nil
+	}
+	soc := capacity * clampPercent(params.InitialSoCPercent) / 100
+	out := make([]float64, len(future))
+	for i, s := range future {
+		if charge[s.Timestamp] {
+			soc = math.Min(capacity, soc+perSlot)
+		}
+		if discharge[s.Timestamp] {
+			soc = math.Max(0, soc-perSlot)
+		}
+		out[i] = soc
+	}
+	return out
+}
+
+// slotPowerFractions returns, per charge/discharge slot, the share of full
+// power the modeled battery can actually use (below 1 when it fills up or
+// runs empty mid-slot). Nil without a battery model.
+func slotPowerFractions(future []PriceSlot, charge, discharge map[time.Time]bool, params BatteryStrategyParams, resolutionMinutes int) map[time.Time]float64 {
+	_, perSlot, ok := batteryModel(params, resolutionMinutes)
+	if !ok || perSlot <= 0 {
+		return nil
+	}
+	walk := socWalk(future, charge, discharge, params, resolutionMinutes)
+	prev := params.CapacityKWh * clampPercent(params.InitialSoCPercent) / 100
+	out := make(map[time.Time]float64)
+	for i, s := range future {
+		if charge[s.Timestamp] || discharge[s.Timestamp] {
+			out[s.Timestamp] = math.Min(1, math.Abs(walk[i]-prev)/perSlot)
+		}
+		prev = walk[i]
+	}
+	return out
+}
+
+// finalSoC returns the modeled SoC (kWh) at the end of the horizon.
+func finalSoC(future []PriceSlot, charge, discharge map[time.Time]bool, params BatteryStrategyParams, resolutionMinutes int) float64 {
+	walk := socWalk(future, charge, discharge, params, resolutionMinutes)
+	if len(walk) == 0 {
+		capacity, _, _ := batteryModel(params, resolutionMinutes)
+		return capacity * clampPercent(params.InitialSoCPercent) / 100
+	}
+	return walk[len(walk)-1]
+}
+
+// applyEndSoCTarget adjusts the selected slots so the modeled SoC ends as close
+// to params.EndSoCTarget as whole slots allow: a slot that would overshoot the
+// target by more than the current shortfall or surplus is left alone. Short of
+// energy, it adds the cheapest idle charge candidates (chargePool) as charge,
+// then drops the cheapest discharge slots; with a surplus it adds the dearest
+// idle discharge candidates (dischargePool) as discharge, then drops the
+// dearest charge slots. The pools already exclude filled slots and apply
+// epsilon and the discharge threshold. Changes that would take the SoC below
+// params.ReserveSoCPercent, add a slot within MinChargeDischargeGapSlots of the
+// opposite action, leave a run shorter than MinRunSlots, or overspend
+// DailyChargeBudget are skipped.
+func applyEndSoCTarget(future, charge, discharge, chargePool, dischargePool []PriceSlot, params BatteryStrategyParams, resolutionMinutes int) ([]PriceSlot, []PriceSlot) {
+	capacity, _, ok := batteryModel(params, resolutionMinutes)
+	if !ok || params.EndSoCTarget == nil {
+		return charge, discharge
+	}
+	target := capacity * clampPercent(*params.EndSoCTarget) / 100
+
+	chargeSet := slotSet(charge)
+	dischargeSet := slotSet(discharge)
+	final := func() float64 {
+		return finalSoC(future, chargeSet, dischargeSet, params, resolutionMinutes)
+	}
+	reserve := capacity * clampPercent(params.ReserveSoCPercent) / 100
+	belowReserve := func() bool {
+		if reserve <= 0 {
+			return false
+		}
+		for _, soc := range socWalk(future, chargeSet, dischargeSet, params, resolutionMinutes) {
+			if soc < reserve-socTolerance {
+				return true
+			}
+		}
+		return false
+	}
+
+	// selected returns the slots of future in set, in time order.
+	selected := func(set map[time.Time]bool) []PriceSlot {
+		var out []PriceSlot
+		for _, s := range future {
+			if set[s.Timestamp] {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	idle := func(pool []PriceSlot) []PriceSlot {
+		var out []PriceSlot
+		for _, s := range pool {
+			if !chargeSet[s.Timestamp] && !dischargeSet[s.Timestamp] {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	step := time.Duration(resolutionMinutes) * time.Minute
+	gap := max(params.MinChargeDischargeGapSlots, 0)
+	// nearOther reports whether ts lies within the action gap of a slot in other.
+	nearOther := func(other map[time.Time]bool, ts time.Time) bool {
+		for k := -gap; k <= gap; k++ {
+			if other[ts.Add(time.Duration(k)*step)] {
+				return true
+			}
+		}
+		return false
+	}
+	cheapestFirst := func(slots []PriceSlot) []PriceSlot {
+		out := append([]PriceSlot(nil), slots...)
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Price < out[j].Price })
+		return out
+	}
+	dearestFirst := func(slots []PriceSlot) []PriceSlot {
+		out := append([]PriceSlot(nil), slots...)
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Price > out[j].Price })
+		return out
+	}
+
+	// toggle flips a slot in set and keeps the change only if it brings the
+	// final SoC closer to the target without breaching the reserve, the gap to
+	// the other action, or the minimum run length.
+	toggle := func(set, other map[time.Time]bool, ts time.Time, add, wantMore bool) {
+		if add && nearOther(other, ts) {
+			return
+		}
+		before := final()
+		set[ts] = add
+		after := final()
+		if math.Abs(after-target) >= math.Abs(before-target) || (!wantMore && belowReserve()) ||
+			!runsAtLeast(set, params.MinRunSlots, step) || !chargeFitsBudget(selected(chargeSet), params, resolutionMinutes) {
+			set[ts] = !add
+		}
+	}
+
+	if final() < target-socTolerance {
+		for _, s := range cheapestFirst(idle(chargePool)) {
+			if final() >= target-socTolerance {
+				break
+			}
+			toggle(chargeSet, dischargeSet, s.Timestamp, true, true)
+		}
+		for _, s := range cheapestFirst(discharge) {
+			if final() >= target-socTolerance {
+				break
+			}
+			toggle(dischargeSet, chargeSet, s.Timestamp, false, true)
+		}
+	} else if final() > target+socTolerance {
+		for _, s := range dearestFirst(idle(dischargePool)) {
+			if final() <= target+socTolerance {
+				break
+			}
+			toggle(dischargeSet, chargeSet, s.Timestamp, true, false)
+		}
+		for _, s := range dearestFirst(charge) {
+			if final() <= target+socTolerance {
+				break
+			}
+			toggle(chargeSet, dischargeSet, s.Timestamp, false, false)
+		}
+	}
+
+	return selected(chargeSet), selected(dischargeSet)
+}
+
+// runsAtLeast reports whether every run of consecutive slots in set is at
+// least minRun slots long; minRun <= 1 always holds.
+func runsAtLeast(set map[time.Time]bool, minRun int, step time.Duration) bool {
+	if minRun <= 1 {
+		return true
+	}
+	for ts, on := range set {
+		if !on || set[ts.Add(-step)] {
+			continue
+		}
+		n := 1
+		for set[ts.Add(time.Duration(n)*step)] {
+			n++
+		}
+		if n < minRun {
+			return false
+		}
+	}
+	return true
+}
+
+// applyReserve drops discharge slots, in time order, that would take the
+// modeled SoC below params.ReserveSoCPercent.
+func applyReserve(future, charge, discharge []PriceSlot, params BatteryStrategyParams, resolutionMinutes int) []PriceSlot {
+	capacity, perSlot, ok := batteryModel(params, resolutionMinutes)
+	if !ok || params.ReserveSoCPercent <= 0 || len(discharge) == 0 {
+		return discharge
+	}
+	reserve := capacity * clampPercent(params.ReserveSoCPercent) / 100
+	chargeSet := slotSet(charge)
+	dischargeSet := slotSet(discharge)
+
+	soc := capacity * clampPercent(params.InitialSoCPercent) / 100
+	var out []PriceSlot
+	for _, s := range future {
+		if chargeSet[s.Timestamp] {
+			soc = math.Min(capacity, soc+perSlot)
+		}
+		if dischargeSet[s.Timestamp] {
+			if soc-perSlot < reserve-socTolerance {
+				continue
+			}
+			soc -= perSlot
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ScheduleCycles returns the equivalent full battery cycles a schedule
+// implies: discharged energy (scaled by each slot's PowerFraction, if any)
+// over CapacityKWh. Without a battery model one cycle is a discharge lasting
+// MaxDischargeHours; it is 0 when neither is set.
+func ScheduleCycles(schedule ScheduleJSON, params BatteryStrategyParams) float64 {
+	resolution := 60
+	if schedule.ResolutionMinutes != nil && *schedule.ResolutionMinutes > 0 {
+		resolution = *schedule.ResolutionMinutes
+	}
+	var hours float64
+	for _, s := range schedule.DischargeSlots {
+		f := 1.0
+		if s.PowerFraction != nil {
+			f = *s.PowerFraction
+		}
+		hours += f * float64(resolution) / 60
+	}
+	if capacity, _, ok := batteryModel(params, resolution); ok {
+		power := params.PowerKW
+		if power <= 0 {
+			power = capacity
+		}
+		return hours * power / capacity
+	}
+	if params.MaxDischargeHours <= 0 {
+		return 0
+	}
+	return hours / params.MaxDischargeHours
+}
+
+func slotSet(slots []PriceSlot) map[time.Time]bool {
+	out := make(map[time.Time]bool, len(slots))
+	for _, s := range slots {
+		out[s.Timestamp] = true
+	}
+	return out
+}
+
+func clampPercent(p float64) float64 {
+	return math.Max(0, math.Min(100, p))
+}
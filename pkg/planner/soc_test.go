@@ -0,0 +1,121 @@
+package planner
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReserveAndEndSoCTarget(t *testing.T) {
+	target := 25.0
+	params := BatteryStrategyParams{
+		MaxDischargeHours: 3,
+		Epsilon:           1,
+		CapacityKWh:       4,
+		PowerKW:           1,
+		InitialSoCPercent: 50,
+		ReserveSoCPercent: 25,
+		EndSoCTarget:      &target,
+	}
+	s := BuildBatterySchedule(hourly(testDay, 20, 20, 20, 1), params, testDay)
+	if got, want := slotHours(t, s.DischargeSlots), []int{0}; !equalInts(got, want) {
+		t.Errorf("discharge hours = %v, want %v", got, want)
+	}
+	if s.EndSoC == nil || math.Abs(*s.EndSoC-target) > 1e-9 {
+		t.Errorf("end SoC = %v, want %v", fmtPtr(s.EndSoC), target)
+	}
+}
+
+func TestEndSoCTargetClosest(t *testing.T) {
+	tests := []struct {
+		name   string
+		target float64
+		want   float64
+	}{
+		// 1.5 kWh per slot on a 4 kWh battery starting at 2 kWh (50%); at
+		// 5 c/kWh with LastPriceCharged 5 and no epsilon every slot may both
+		// charge and discharge.
+		{"overshoot larger than shortfall", 56.25, 50},
+		{"overshoot smaller than shortfall", 75, 87.5},
+		{"surplus overshoot larger than surplus", 43.75, 50},
+		{"surplus overshoot smaller than surplus", 25, 12.5},
+		{"full target", 100, 100},
+		{"empty target", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			params := BatteryStrategyParams{
+				LastPriceCharged:  5,
+				CapacityKWh:       4,
+				PowerKW:           1.5,
+				InitialSoCPercent: 50,
+				EndSoCTarget:      &target,
+			}
+			s := BuildBatterySchedule(hourly(testDay, 5, 5, 5, 5), params, testDay)
+			if s.EndSoC == nil || math.Abs(*s.EndSoC-tt.want) > 1e-9 {
+				t.Errorf("end SoC = %v, want %v", fmtPtr(s.EndSoC), tt.want)
+			}
+		})
+	}
+}
+
+func TestEndSoCTargetRespectsSelectionRules(t *testing.T) {
+	gapped := FillGaps([]PriceSlot{
+		{Timestamp: testDay, Price: 1},
+		{Timestamp: testDay.Add(2 * time.Hour), Price: 1},
+		{Timestamp: testDay.Add(3 * time.Hour), Price: 1},
+		{Timestamp: testDay.Add(4 * time.Hour), Price: 20},
+	}, 1)
+	tests := []struct {
+		name          string
+		prices        []PriceSlot
+		tweak         func(*BatteryStrategyParams)
+		wantCharge    []int
+		wantDischarge []int
+		wantEnd       float64
+	}{
+		// 01:00 is interpolated by FillGaps and must never be charged.
+		{"filled slots", gapped, nil, []int{0, 2, 3}, nil, 75},
+		// 20 c/kWh is not epsilon below LastPriceCharged.
+		{"epsilon", hourly(testDay, 1, 1, 20, 20), nil, []int{0, 1}, nil, 50},
+		// Draining a full battery towards 0%: 01:00 sits next to the 00:00
+		// charge, so only 02:00 and 03:00 may discharge.
+		{"action gap", hourly(testDay, 1, 20, 20, 20), func(p *BatteryStrategyParams) {
+			zero := 0.0
+			p.EndSoCTarget = &zero
+			p.InitialSoCPercent = 100
+			p.MaxChargeHours = 1
+			p.MinChargeDischargeGapSlots = 1
+		}, []int{0}, []int{2, 3}, 50},
+		// Single cheap slots would form runs shorter than MinRunSlots.
+		{"min run", hourly(testDay, 1, 20, 1, 20), func(p *BatteryStrategyParams) {
+			p.MinRunSlots = 2
+		}, nil, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := 100.0
+			params := BatteryStrategyParams{
+				LastPriceCharged: 10,
+				Epsilon:          1,
+				CapacityKWh:      4,
+				PowerKW:          1,
+				EndSoCTarget:     &target,
+			}
+			if tt.tweak != nil {
+				tt.tweak(&params)
+			}
+			s := BuildBatterySchedule(tt.prices, params, testDay)
+			if got := slotHours(t, s.ChargeSlots); !equalInts(got, tt.wantCharge) {
+				t.Errorf("charge hours = %v, want %v", got, tt.wantCharge)
+			}
+			if got := slotHours(t, s.DischargeSlots); !equalInts(got, tt.wantDischarge) {
+				t.Errorf("discharge hours = %v, want %v", got, tt.wantDischarge)
+			}
+			if s.EndSoC == nil || math.Abs(*s.EndSoC-tt.wantEnd) > 1e-9 {
+				t.Errorf("end SoC = %v, want %v", fmtPtr(s.EndSoC), tt.wantEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package planner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// ValidationIssue is one structural problem in a saved file. Field is a JSON
+// path such as "multiAreaEntries[3].deliveryStart"; Line is set for syntax
+// errors.
+type ValidationIssue struct {
+	Field   string
+	Line    int
+	Message string
+}
+
+func (i ValidationIssue) String() string {
+	switch {
+	case i.Line > 0:
+		return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+	case i.Field != "":
+		return fmt.Sprintf("%s: %s", i.Field, i.Message)
+	default:
+		return i.Message
+	}
+}
+
+// ValidateDayAheadResponse checks a saved DayAheadPrices body with the same
+// walk ParseDayAheadResponse uses, but reports every problem instead of
+// skipping entries: missing fields, bad or out-of-order timestamps, and
+// entries without a price for area. No issues means the file is valid.
+func ValidateDayAheadResponse(r io.Reader, area string) ([]ValidationIssue, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	var raw dayAheadResponse
+	if issue, ok := decodeIssue(body, &raw); ok {
+		return []ValidationIssue{issue}, nil
+	}
+
+	var issues []ValidationIssue
+	add := func(field, format string, args ...any) {
+		issues = append(issues, ValidationIssue{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if raw.DeliveryDateCET == "" {
+		add("deliveryDateCET", "missing")
+	}
+	if raw.Currency == "" {
+		add("currency", "missing")
+	}
+	if len(raw.MultiAreaEntries) == 0 {
+		add("multiAreaEntries", "missing or empty")
+	}
+
+	var stats ParseStats
+	raw.slots(area, &stats, func(i int, field, format string, args ...any) {
+		add(fmt.Sprintf("multiAreaEntries[%d].%s", i, field), format, args...)
+	})
+	return issues, nil
+}
+
+// ValidateScheduleJSON checks a saved ScheduleJSON: required fields, slot
+// and interval timestamps, and time order within each slot list.
+func ValidateScheduleJSON(r io.Reader) ([]ValidationIssue, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	var schedule ScheduleJSON
+	if issue, ok := decodeIssue(body, &schedule); ok {
+		return []ValidationIssue{issue}, nil
+	}
+
+	var issues []ValidationIssue
+	add := func(field, format string, args ...any) {
+		issues = append(issues, ValidationIssue{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if schedule.Area == "" {
+		add("area", "missing")
+	}
+	if schedule.ChargeSlots == nil {
+		add("charge_slots", "missing")
+	}
+	if schedule.DischargeSlots == nil {
+		add("discharge_slots", "missing")
+	}
+
+	checkSlots := func(name string, slots []SlotJSON) {
+		var prev time.Time
+		for i, s := range slots {
+			field := fmt.Sprintf("%s[%d].timestamp", name, i)
+			ts, err := time.Parse(time.RFC3339, s.Timestamp)
+			if err != nil {
+				add(field, "bad timestamp %q", s.Timestamp)
+				continue
+			}
+			if !prev.IsZero() && !ts.After(prev) {
+				add(field, "%s is not after the previous slot (%s)", s.Timestamp, prev.Format(time.RFC3339))
+			}
+			prev = ts
+		}
+	}
+	checkSlots("charge_slots", schedule.ChargeSlots)
+	checkSlots("discharge_slots", schedule.DischargeSlots)
+
+	checkIntervals := func(name string, intervals []IntervalJSON) {
+		for i, iv := range intervals {
+			field := fmt.Sprintf("%s[%d]", name, i)
+			start, errStart := time.Parse(time.RFC3339, iv.Start)
+			if errStart != nil {
+				add(field+".start", "bad timestamp %q", iv.Start)
+			}
+			end, errEnd := time.Parse(time.RFC3339, iv.End)
+			if errEnd != nil {
+				add(field+".end", "bad timestamp %q", iv.End)
+			}
+			if errStart == nil && errEnd == nil && !end.After(start) {
+				add(field+".end", "%s is not after start", iv.End)
+			}
+		}
+	}
+	checkIntervals("charge_intervals", schedule.ChargeIntervals)
+	checkIntervals("discharge_intervals", schedule.DischargeIntervals)
+	return issues, nil
+}
+
+// decodeIssue decodes body into v and turns a failure into an issue, with
+// the line of a syntax error or the field of a type mismatch.
+func decodeIssue(body []byte, v any) (ValidationIssue, bool) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ValidationIssue{Message: "empty file"}, true
+	}
+	err := json.Unmarshal(body, v)
+	if err == nil {
+		return ValidationIssue{}, false
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return ValidationIssue{Line: lineAt(body, syntaxErr.Offset), Message: syntaxErr.Error()}, true
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return ValidationIssue{Field: typeErr.Field, Message: fmt.Sprintf("expected %s, got %s", jsonKind(typeErr.Type), typeErr.Value)}, true
+	}
+	return ValidationIssue{Message: err.Error()}, true
+}
+
+// jsonKind names the JSON value a Go type decodes from, in the terms
+// UnmarshalTypeError uses for the value found ("array", "number", ...).
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Pointer:
+		return jsonKind(t.Elem())
+	default:
+		return t.String()
+	}
+}
+
+// lineAt returns the 1-based line of byte offset in body.
+func lineAt(body []byte, offset int64) int {
+	offset = min(offset, int64(len(body)))
+	return bytes.Count(body[:offset], []byte("\n")) + 1
+}
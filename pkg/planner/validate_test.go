@@ -0,0 +1,102 @@
+package planner
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// issueStrings renders issues the way cmd output shows them.
+func issueStrings(issues []ValidationIssue) []string {
+	out := make([]string, len(issues))
+	for i, is := range issues {
+		out[i] = is.String()
+	}
+	return out
+}
+
+func TestValidateDayAheadResponse(t *testing.T) {
+	entry := func(start, end, area string) string {
+		return `{"deliveryStart": "` + start + `", "deliveryEnd": "` + end + `", "entryPerArea": {"` + area + `": 40}}`
+	}
+	body := func(entries ...string) string {
+		return `{"deliveryDateCET": "2025-01-15", "currency": "EUR", "multiAreaEntries": [` + strings.Join(entries, ",") + `]}`
+	}
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"valid", body(
+			entry("2025-01-15T00:00:00Z", "2025-01-15T01:00:00Z", "LV"),
+			entry("2025-01-15T01:00:00Z", "2025-01-15T02:00:00Z", "LV"),
+		), nil},
+		{"out of order", body(
+			entry("2025-01-15T01:00:00Z", "2025-01-15T02:00:00Z", "LV"),
+			entry("2025-01-15T00:00:00Z", "2025-01-15T01:00:00Z", "LV"),
+		), []string{"multiAreaEntries[1].deliveryStart: 2025-01-15T00:00:00Z is not after the previous entry (2025-01-15T01:00:00Z)"}},
+		{"end before start", body(
+			entry("2025-01-15T01:00:00Z", "2025-01-15T00:00:00Z", "LV"),
+		), []string{"multiAreaEntries[0].deliveryEnd: 2025-01-15T00:00:00Z is not after deliveryStart"}},
+		{"bad timestamp and missing area", body(
+			entry("yesterday", "2025-01-15T01:00:00Z", "LV"),
+			entry("2025-01-15T01:00:00Z", "2025-01-15T02:00:00Z", "EE"),
+		), []string{
+			`multiAreaEntries[0].deliveryStart: bad timestamp "yesterday"`,
+			"multiAreaEntries[1].entryPerArea: no price for area LV",
+		}},
+		{"missing fields", `{"multiAreaEntries": []}`, []string{
+			"deliveryDateCET: missing",
+			"currency: missing",
+			"multiAreaEntries: missing or empty",
+		}},
+		{"syntax error", "{\n\"currency\": \"EUR\",\n}", []string{"line 3: invalid character '}' looking for beginning of object key string"}},
+		{"wrong type", `{"multiAreaEntries": {}}`, []string{"multiAreaEntries: expected array, got object"}},
+		{"wrong scalar type", `{"currency": 978}`, []string{"currency: expected string, got number"}},
+		{"empty", "  \n", []string{"empty file"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := ValidateDayAheadResponse(strings.NewReader(tt.body), "LV")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := issueStrings(issues); !slices.Equal(got, tt.want) {
+				t.Errorf("issues:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateScheduleJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"valid", `{"area": "LV", "charge_slots": [{"timestamp": "2025-01-15T02:00:00Z"}], "discharge_slots": [],
+			"charge_intervals": [{"start": "2025-01-15T02:00:00Z", "end": "2025-01-15T03:00:00Z"}]}`, nil},
+		{"missing fields", `{"charge_slots": []}`, []string{"area: missing", "discharge_slots: missing"}},
+		{"out of order slots", `{"area": "LV", "charge_slots": [], "discharge_slots": [
+			{"timestamp": "2025-01-15T19:00:00Z"}, {"timestamp": "2025-01-15T18:00:00Z"}, {"timestamp": "soon"}]}`, []string{
+			"discharge_slots[1].timestamp: 2025-01-15T18:00:00Z is not after the previous slot (2025-01-15T19:00:00Z)",
+			`discharge_slots[2].timestamp: bad timestamp "soon"`,
+		}},
+		{"bad interval", `{"area": "LV", "charge_slots": [], "discharge_slots": [],
+			"discharge_intervals": [{"start": "2025-01-15T19:00:00Z", "end": "2025-01-15T18:00:00Z"}, {"start": "x", "end": "2025-01-15T18:00:00Z"}]}`, []string{
+			"discharge_intervals[0].end: 2025-01-15T18:00:00Z is not after start",
+			`discharge_intervals[1].start: bad timestamp "x"`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := ValidateScheduleJSON(strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := issueStrings(issues); !slices.Equal(got, tt.want) {
+				t.Errorf("issues:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
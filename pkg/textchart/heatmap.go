@@ -0,0 +1,89 @@
+package textchart
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gordpool/pkg/planner"
+)
+
+// heatShades runs from cheapest to dearest.
+var heatShades = []string{"░", "▒", "▓", "█"}
+
+// heatColors pairs with heatShades when colorizing.
+var heatColors = []string{"[lime]", "[yellow]", "[orange]", "[red]"}
+
+// BuildHeatmap renders prices as a grid of UTC days (rows) by hour-of-day
+// (columns), each cell shaded by the hour's average price relative to the
+// overall min/max. Hours without data show a dot.
+func BuildHeatmap(prices []planner.PriceSlot, opts Options) string {
+	def := defaultOptions()
+	if opts.CurrencyLabel == "" {
+		opts.CurrencyLabel = def.CurrencyLabel
+	}
+	if len(prices) == 0 {
+		return colorize("[red]No prices available.[-:-:-]\n", opts.Colorize)
+	}
+
+	type cell struct {
+		sum   float64
+		count int
+	}
+	days := make(map[time.Time]*[24]cell)
+	for _, s := range prices {
+		ts := s.Timestamp.UTC()
+		day := time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC)
+		row, ok := days[day]
+		if !ok {
+			row = &[24]cell{}
+			days[day] = row
+		}
+		row[ts.Hour()].sum += s.Price
+		row[ts.Hour()].count++
+	}
+	order := make([]time.Time, 0, len(days))
+	for day := range days {
+		order = append(order, day)
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i].Before(order[j]) })
+
+	minP, maxP := math.Inf(1), math.Inf(-1)
+	for _, row := range days {
+		for _, c := range row {
+			if c.count > 0 {
+				avg := c.sum / float64(c.count)
+				minP = math.Min(minP, avg)
+				maxP = math.Max(maxP, avg)
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", colorize(fmt.Sprintf("[yellow]Price heatmap by hour (UTC, %s)[-:-:-]", opts.CurrencyLabel), opts.Colorize))
+	b.WriteString("     ")
+	for h := 0; h < 24; h++ {
+		fmt.Fprintf(&b, " %02d", h)
+	}
+	b.WriteString("\n")
+	for _, day := range order {
+		b.WriteString(day.Format("01-02"))
+		for _, c := range days[day] {
+			if c.count == 0 {
+				b.WriteString("  ·")
+				continue
+			}
+			rel := relPrice(c.sum/float64(c.count), minP, maxP)
+			idx := min(len(heatShades)-1, int(rel*float64(len(heatShades))))
+			b.WriteString(" ")
+			b.WriteString(wrap(strings.Repeat(heatShades[idx], 2), heatColors[idx], opts.Colorize))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Legend: %s low %s  …  %s high %s\n",
+		wrap(heatShades[0], heatColors[0], opts.Colorize), formatPrice(minP, 0, opts),
+		wrap(heatShades[len(heatShades)-1], heatColors[len(heatColors)-1], opts.Colorize), formatPrice(maxP, 0, opts))
+	return b.String()
+}
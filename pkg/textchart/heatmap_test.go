@@ -0,0 +1,55 @@
+package textchart
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"gordpool/pkg/planner"
+)
+
+func TestBuildHeatmap(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	// A full first day and a second day ending at 12:00.
+	var prices []planner.PriceSlot
+	for h := 0; h < 36; h++ {
+		prices = append(prices, planner.PriceSlot{Timestamp: day.Add(time.Duration(h) * time.Hour), Price: float64(h % 24)})
+	}
+
+	got := BuildHeatmap(prices, Options{})
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	// Title, hour header, one row per day, legend.
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5:\n%s", len(lines), got)
+	}
+	header, rows := lines[1], lines[2:4]
+	if cols := strings.Fields(header); len(cols) != 24 || cols[0] != "00" || cols[23] != "23" {
+		t.Errorf("header has columns %v, want 00..23", cols)
+	}
+	tests := []struct {
+		row     string
+		label   string
+		missing int
+	}{
+		{rows[0], "01-15", 0},
+		{rows[1], "01-16", 12},
+	}
+	for _, tt := range tests {
+		if !strings.HasPrefix(tt.row, tt.label) {
+			t.Errorf("row %q, want label %s", tt.row, tt.label)
+		}
+		if cells := strings.Fields(tt.row)[1:]; len(cells) != 24 {
+			t.Errorf("%s has %d cells, want 24", tt.label, len(cells))
+		}
+		if n := strings.Count(tt.row, "·"); n != tt.missing {
+			t.Errorf("%s has %d empty cells, want %d", tt.label, n, tt.missing)
+		}
+		if w := utf8.RuneCountInString(tt.row); w != utf8.RuneCountInString(header) {
+			t.Errorf("%s is %d wide, header %d", tt.label, w, utf8.RuneCountInString(header))
+		}
+	}
+	if !strings.Contains(lines[4], "0.00") || !strings.Contains(lines[4], "23.00") {
+		t.Errorf("legend %q should span 0.00..23.00", lines[4])
+	}
+}
@@ -0,0 +1,103 @@
+package textchart
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+
+	"gordpool/pkg/planner"
+)
+
+// htmlRow is one future slot in the HTML report.
+type htmlRow struct {
+	Time     string
+	Price    string
+	Action   string
+	Color    string
+	BarWidth int // percent of the bar column
+}
+
+type htmlReport struct {
+	Title       string
+	Now         string
+	Rows        []htmlRow
+	Charge      []planner.IntervalJSON
+	Discharge   []planner.IntervalJSON
+	Preliminary bool
+}
+
+var htmlTmpl = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body{font-family:Helvetica,Arial,sans-serif;color:#222;margin:16px}
+table{border-collapse:collapse}
+td,th{padding:2px 8px;text-align:left;font-size:13px}
+th{border-bottom:1px solid #999}
+.bar{height:10px}
+</style>
+</head>
+<body>
+<h2>{{.Title}}</h2>
+<p>Generated {{.Now}} (UTC){{if .Preliminary}} &mdash; includes preliminary prices{{end}}</p>
+{{if .Charge}}<p><b>Charge:</b>{{range .Charge}} {{.Start}}&ndash;{{.End}};{{end}}</p>{{end}}
+{{if .Discharge}}<p><b>Discharge:</b>{{range .Discharge}} {{.Start}}&ndash;{{.End}};{{end}}</p>{{end}}
+<table>
+<thead><tr><th>Time</th><th>c/kWh</th><th>Action</th><th style="width:300px">Price</th></tr></thead>
+<tbody>
+{{range .Rows}}<tr><td>{{.Time}}</td><td>{{.Price}}</td><td style="color:{{.Color}}">{{.Action}}</td><td><div class="bar" style="width:{{.BarWidth}}%;background:{{.Color}}"></div></td></tr>
+{{end}}</tbody>
+</table>
+</body>
+</html>
+`))
+
+// BuildHTML renders the future slots and schedule as a standalone HTML
+// document (inline CSS, no external assets) suitable for email.
+func BuildHTML(prices []planner.PriceSlot, schedule planner.ScheduleJSON, now time.Time, opts Options) (string, error) {
+	future := filterFuture(prices, now, opts.TomorrowOnly)
+	minP, maxP := priceBounds(future)
+
+	chargeSet := setFromSlots(schedule.ChargeSlots)
+	dischargeSet := setFromSlots(schedule.DischargeSlots)
+
+	report := htmlReport{
+		Title:       fmt.Sprintf("Nord Pool plan for %s", schedule.Area),
+		Now:         now.UTC().Format("2006-01-02 15:04"),
+		Charge:      schedule.ChargeIntervals,
+		Discharge:   schedule.DischargeIntervals,
+		Preliminary: schedule.Preliminary,
+	}
+	for _, s := range future {
+		row := htmlRow{
+			Time:   s.Timestamp.Format("01-02 15:04"),
+			Price:  fmt.Sprintf("%.2f", s.Price),
+			Action: "idle",
+			Color:  "#1e90ff",
+		}
+		if s.Preliminary {
+			row.Price += "*"
+		}
+		switch {
+		case chargeSet[s.Timestamp]:
+			row.Action, row.Color = "charge", "#32cd32"
+		case dischargeSet[s.Timestamp]:
+			row.Action, row.Color = "discharge", "#ff0000"
+		}
+		row.BarWidth = int(math.Round(relPrice(s.Price, minP, maxP) * 100))
+		if row.BarWidth < 1 {
+			row.BarWidth = 1
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	var b strings.Builder
+	if err := htmlTmpl.Execute(&b, report); err != nil {
+		return "", fmt.Errorf("render html: %w", err)
+	}
+	return b.String(), nil
+}
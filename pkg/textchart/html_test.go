@@ -0,0 +1,68 @@
+package textchart
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildHTML(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	prices, schedule := fixture(now)
+	out, err := BuildHTML(prices, schedule, now, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "<!DOCTYPE html>") {
+		t.Fatalf("output does not start with a doctype: %.40q", out)
+	}
+
+	dec := xml.NewDecoder(strings.NewReader(out))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	var rows, depth int
+	inBody := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("malformed HTML: %v", err)
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch el.Name.Local {
+			case "script", "link", "img", "iframe":
+				t.Errorf("report pulls in an external resource via <%s>", el.Name.Local)
+			case "tbody":
+				inBody = true
+			case "tr":
+				if inBody {
+					rows++
+				}
+			}
+			for _, a := range el.Attr {
+				if a.Name.Local == "src" || a.Name.Local == "href" {
+					t.Errorf("<%s %s=%q> is not self-contained", el.Name.Local, a.Name.Local, a.Value)
+				}
+			}
+		case xml.EndElement:
+			depth--
+			if el.Name.Local == "tbody" {
+				inBody = false
+			}
+		}
+	}
+	if depth != 0 {
+		t.Errorf("unbalanced elements: depth %d at the end", depth)
+	}
+	// 12:00..23:00 are the future slots of the fixture day.
+	if rows != 12 {
+		t.Errorf("table has %d rows, want 12", rows)
+	}
+}
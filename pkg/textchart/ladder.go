@@ -0,0 +1,70 @@
+package textchart
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gordpool/pkg/planner"
+)
+
+// BuildLadder lists future slots from cheapest to dearest with the
+// schedule's action marker, to check the greedy selection at a glance. A cut
+// line follows the dearest charge slot and precedes the cheapest discharge
+// slot. Ties keep time order.
+func BuildLadder(future []planner.PriceSlot, schedule planner.ScheduleJSON, opts Options) string {
+	def := defaultOptions()
+	if opts.CurrencyLabel == "" {
+		opts.CurrencyLabel = def.CurrencyLabel
+	}
+	if len(future) == 0 {
+		return colorize("[red]No future slots available.[-:-:-]\n", opts.Colorize)
+	}
+
+	sorted := append([]planner.PriceSlot(nil), future...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Price != sorted[j].Price {
+			return sorted[i].Price < sorted[j].Price
+		}
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	chargeSet := setFromSlots(schedule.ChargeSlots)
+	dischargeSet := setFromSlots(schedule.DischargeSlots)
+	lastCharge, firstDischarge := -1, -1
+	for i, s := range sorted {
+		if chargeSet[s.Timestamp] {
+			lastCharge = i
+		}
+		if dischargeSet[s.Timestamp] && firstDischarge < 0 {
+			firstDischarge = i
+		}
+	}
+
+	chargeSym, dischargeSym, idleSym := opts.symbols()
+	rankWidth := len(fmt.Sprint(len(sorted)))
+	cut := func(label string) string {
+		return colorize(fmt.Sprintf("[gray]%s %s[-:-:-]", strings.Repeat("─", 4), label), opts.Colorize) + "\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", colorize(fmt.Sprintf("[yellow]Price ladder for %s, cheapest first (%s)[-:-:-]", schedule.Area, opts.CurrencyLabel), opts.Colorize))
+	for i, s := range sorted {
+		if i == firstDischarge {
+			b.WriteString(cut("discharge from here"))
+		}
+		mark := wrap(string(idleSym), "[dodgerblue]", opts.Colorize)
+		switch {
+		case chargeSet[s.Timestamp]:
+			mark = wrap(string(chargeSym), "[lime]", opts.Colorize)
+		case dischargeSet[s.Timestamp]:
+			mark = wrap(string(dischargeSym), "[red]", opts.Colorize)
+		}
+		fmt.Fprintf(&b, "%*d %s | %s %s | %s\n", rankWidth, i+1, s.Timestamp.Format("01-02 15:04"),
+			formatPrice(s.Price, 6, opts), opts.CurrencyLabel, mark)
+		if i == lastCharge {
+			b.WriteString(cut("charge up to here"))
+		}
+	}
+	return b.String()
+}
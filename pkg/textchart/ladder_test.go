@@ -0,0 +1,62 @@
+package textchart
+
+import (
+	"testing"
+	"time"
+
+	"gordpool/pkg/planner"
+)
+
+func TestBuildLadder(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	var future []planner.PriceSlot
+	for h, p := range []float64{5, 1, 20, 3, 5} {
+		future = append(future, planner.PriceSlot{Timestamp: day.Add(time.Duration(h) * time.Hour), Price: p})
+	}
+	at := func(h int) planner.SlotJSON {
+		return planner.SlotJSON{Timestamp: day.Add(time.Duration(h) * time.Hour).Format(time.RFC3339)}
+	}
+	schedule := planner.ScheduleJSON{
+		Area:           "LV",
+		ChargeSlots:    []planner.SlotJSON{at(1), at(3)},
+		DischargeSlots: []planner.SlotJSON{at(2)},
+	}
+
+	tests := []struct {
+		name     string
+		future   []planner.PriceSlot
+		schedule planner.ScheduleJSON
+		opts     Options
+		want     string
+	}{
+		{"cuts and ties in time order", future, schedule, Options{},
+			"Price ladder for LV, cheapest first (c/kWh)\n" +
+				"1 01-15 01:00 |   1.00 c/kWh | C\n" +
+				"2 01-15 03:00 |   3.00 c/kWh | C\n" +
+				"──── charge up to here\n" +
+				"3 01-15 00:00 |   5.00 c/kWh | .\n" +
+				"4 01-15 04:00 |   5.00 c/kWh | .\n" +
+				"──── discharge from here\n" +
+				"5 01-15 02:00 |  20.00 c/kWh | D\n"},
+		{"no plan, no cuts", future, planner.ScheduleJSON{Area: "LV"}, Options{IdleSymbol: '_'},
+			"Price ladder for LV, cheapest first (c/kWh)\n" +
+				"1 01-15 01:00 |   1.00 c/kWh | _\n" +
+				"2 01-15 03:00 |   3.00 c/kWh | _\n" +
+				"3 01-15 00:00 |   5.00 c/kWh | _\n" +
+				"4 01-15 04:00 |   5.00 c/kWh | _\n" +
+				"5 01-15 02:00 |  20.00 c/kWh | _\n"},
+		{"empty", nil, schedule, Options{}, "No future slots available.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BuildLadder(tt.future, tt.schedule, tt.opts); got != tt.want {
+				t.Errorf("BuildLadder:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	// The input order is left alone.
+	if future[0].Price != 5 || future[2].Price != 20 {
+		t.Errorf("BuildLadder reordered its input: %+v", future)
+	}
+}
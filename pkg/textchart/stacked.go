@@ -0,0 +1,80 @@
+package textchart
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gordpool/pkg/planner"
+)
+
+// BuildStacked renders one action row per schedule (planner.ScheduleToBitmap)
+// on a shared time axis of the future slots, e.g. to compare the scenarios of
+// planner.SweepEpsilon. A final row marks slots where the scenarios disagree.
+// Missing labels fall back to the scenario number. Rows use opts' action
+// symbols.
+func BuildStacked(prices []planner.PriceSlot, schedules []planner.ScheduleJSON, labels []string, now time.Time, opts Options) string {
+	future := filterFuture(prices, now, opts.TomorrowOnly)
+	if len(future) == 0 {
+		return colorize("[red]No future slots available.[-:-:-]\n", opts.Colorize)
+	}
+	if len(schedules) == 0 {
+		return colorize("[red]No schedules to compare.[-:-:-]\n", opts.Colorize)
+	}
+
+	names := make([]string, len(schedules))
+	width := len("diff")
+	for i := range schedules {
+		names[i] = fmt.Sprintf("#%d", i+1)
+		if i < len(labels) && labels[i] != "" {
+			names[i] = labels[i]
+		}
+		width = max(width, len([]rune(names[i])))
+	}
+	rows := make([]string, len(schedules))
+	for i, s := range schedules {
+		rows[i] = planner.ScheduleToBitmap(s, future, now)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", colorize(fmt.Sprintf("[yellow]Scenarios from %s (one column per slot)[-:-:-]", future[0].Timestamp.Format("01-02 15:04")), opts.Colorize))
+
+	// axis: the UTC hour at every 6-hour mark
+	axis := []rune(strings.Repeat(" ", len(future)))
+	for i, s := range future {
+		ts := s.Timestamp.UTC()
+		if ts.Minute() == 0 && ts.Hour()%6 == 0 && i+2 <= len(axis) {
+			copy(axis[i:], []rune(ts.Format("15")))
+		}
+	}
+	fmt.Fprintf(&b, "%-*s %s\n", width, "", strings.TrimRight(string(axis), " "))
+
+	chargeSym, dischargeSym, idleSym := opts.symbols()
+	for i, row := range rows {
+		fmt.Fprintf(&b, "%-*s ", width, names[i])
+		for _, c := range row {
+			switch c {
+			case 'C':
+				b.WriteString(wrap(string(chargeSym), "[lime]", opts.Colorize))
+			case 'D':
+				b.WriteString(wrap(string(dischargeSym), "[red]", opts.Colorize))
+			default:
+				b.WriteString(wrap(string(idleSym), "[dodgerblue]", opts.Colorize))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	diff := make([]byte, len(future))
+	for j := range diff {
+		diff[j] = ' '
+		for _, row := range rows[1:] {
+			if row[j] != rows[0][j] {
+				diff[j] = '^'
+				break
+			}
+		}
+	}
+	fmt.Fprintf(&b, "%-*s %s\n", width, "diff", wrap(strings.TrimRight(string(diff), " "), "[yellow]", opts.Colorize))
+	return b.String()
+}
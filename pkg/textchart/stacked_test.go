@@ -0,0 +1,50 @@
+package textchart
+
+import (
+	"testing"
+	"time"
+
+	"gordpool/pkg/planner"
+)
+
+func TestBuildStacked(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	var prices []planner.PriceSlot
+	for h := 0; h < 8; h++ {
+		prices = append(prices, planner.PriceSlot{Timestamp: day.Add(time.Duration(h) * time.Hour), Price: 5})
+	}
+	at := func(h int) planner.SlotJSON {
+		return planner.SlotJSON{Timestamp: day.Add(time.Duration(h) * time.Hour).Format(time.RFC3339)}
+	}
+	// The scenarios differ only in the 03:00 charge.
+	strict := planner.ScheduleJSON{ChargeSlots: []planner.SlotJSON{at(1), at(2)}, DischargeSlots: []planner.SlotJSON{at(6)}}
+	loose := planner.ScheduleJSON{ChargeSlots: []planner.SlotJSON{at(1), at(2), at(3)}, DischargeSlots: []planner.SlotJSON{at(6)}}
+
+	tests := []struct {
+		name   string
+		labels []string
+		opts   Options
+		want   string
+	}{
+		{"labels", []string{"eps=2", "eps=1"}, Options{},
+			"Scenarios from 01-15 00:00 (one column per slot)\n" +
+				"      00    06\n" +
+				"eps=2 .CC...D.\n" +
+				"eps=1 .CCC..D.\n" +
+				"diff     ^\n"},
+		{"default labels and symbols", nil, Options{ChargeSymbol: '+', DischargeSymbol: '-', IdleSymbol: '_'},
+			"Scenarios from 01-15 00:00 (one column per slot)\n" +
+				"     00    06\n" +
+				"#1   _++___-_\n" +
+				"#2   _+++__-_\n" +
+				"diff    ^\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildStacked(prices, []planner.ScheduleJSON{strict, loose}, tt.labels, day, tt.opts)
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"math"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"gordpool/pkg/planner"
 )
@@ -18,23 +19,158 @@ const (
 	FilterDischargeOnly
 )
 
+// SparkColor selects what colors the sparkline's price blocks.
+type SparkColor int
+
+const (
+	SparkColorAction SparkColor = iota // charge/discharge/idle, like the rows
+	SparkColorPrice                    // absolute price band: cheap/mid/expensive
+)
+
+// Default band bounds (c/kWh) for SparkColorPrice.
+const (
+	defaultCheapBelow     = 5
+	defaultExpensiveAbove = 15
+)
+
 // Options controls rendering details.
 type Options struct {
 	Colorize  bool // when true, use tview color tags
 	MaxWidth  int  // bar width
 	MaxPoints int  // sparkline downsample limit
+
+	// History holds past slots (e.g. from planner.LoadRecentPrices). When set,
+	// each row shows the 7-day average for its hour-of-day as a faint marker.
+	History []planner.PriceSlot
+
+	// IncludePast renders slots before now (dimmed, without action markers)
+	// ahead of a "now" marker. Only applies to FilterAll. Lookback limits how
+	// far back to go; zero means since midnight UTC of now's day.
+	IncludePast bool
+	Lookback    time.Duration
+
+	// TomorrowOnly limits the chart to slots delivered on the next calendar
+	// day in market time (planner.TomorrowSlots); past slots are not shown.
+	// Pair it with BatteryStrategyParams.TomorrowOnly.
+	TomorrowOnly bool
+
+	// ClampMin/ClampMax bound the bar scale so a single outlier does not
+	// flatten every other bar. Prices beyond a bound are drawn empty/full and
+	// marked; the price column always shows the true value. Nil means no clamp.
+	ClampMin *float64
+	ClampMax *float64
+
+	// DistinctFills draws charge, discharge and idle bars with different
+	// block glyphs so actions stay readable without color.
+	DistinctFills bool
+
+	// CurrencyLabel replaces the "c/kWh" unit and DecimalSeparator the "."
+	// in printed prices, for non-English locales. Empty keeps the defaults.
+	CurrencyLabel    string
+	DecimalSeparator string
+
+	// ShowPower draws charge/discharge bars by the slot's modeled power
+	// (planner.SlotJSON.PowerFraction of MaxWidth) instead of its price, so
+	// partial-power slots stand out. Slots without a fraction keep price bars.
+	ShowPower bool
+
+	// IdleMinBar and ActionMinBar are the shortest bar drawn for idle and
+	// for charge/discharge rows. Nil means 1, so every row shows a bar; 0
+	// leaves the row blank at the day's minimum price.
+	IdleMinBar   *int
+	ActionMinBar *int
+
+	// HighlightCheapestHours, when > 0, marks the rows of the cheapest
+	// contiguous window of that many hours (planner.CheapestWindow) with ★.
+	HighlightCheapestHours float64
+
+	// MaxRows caps the rendered future slot rows (past rows from IncludePast
+	// are not counted); the rest are summarised in a single "… and N more"
+	// line. 0 means unlimited.
+	MaxRows int
+
+	// ShowDelta adds a column with each row's signed difference from the
+	// mean price of the shown future rows, e.g. "-1.5", in the price
+	// column's unit (CurrencyLabel).
+	ShowDelta bool
+
+	// RelativeTimes shows each row's start as an offset from now, e.g.
+	// "+2h15m" (negative for past rows), instead of the date and time.
+	RelativeTimes bool
+
+	// SparkColorBy colors the sparkline blocks by action (default) or by
+	// price band: green below CheapBelow, red above ExpensiveAbove, yellow
+	// between. The mode line keeps action colors. Both bounds zero means
+	// 5 and 15 c/kWh.
+	SparkColorBy   SparkColor
+	CheapBelow     float64
+	ExpensiveAbove float64
+
+	// ChargeSymbol, DischargeSymbol and IdleSymbol mark slot actions in the
+	// rows, the sparkline and the stacked view. Zero means C, D and '.'.
+	ChargeSymbol    rune
+	DischargeSymbol rune
+	IdleSymbol      rune
+}
+
+// symbols returns the action markers, falling back to C/D/. when unset.
+func (o Options) symbols() (charge, discharge, idle rune) {
+	charge, discharge, idle = 'C', 'D', '.'
+	if o.ChargeSymbol != 0 {
+		charge = o.ChargeSymbol
+	}
+	if o.DischargeSymbol != 0 {
+		discharge = o.DischargeSymbol
+	}
+	if o.IdleSymbol != 0 {
+		idle = o.IdleSymbol
+	}
+	return charge, discharge, idle
 }
 
+// baselineDays is the lookback used for the hour-of-day average overlay.
+const baselineDays = 7
+
 func defaultOptions() Options {
 	return Options{
 		Colorize:  false,
 		MaxWidth:  30,
 		MaxPoints: 80,
+
+		CurrencyLabel:    "c/kWh",
+		DecimalSeparator: ".",
 	}
 }
 
 // Build renders a textual chart similar to the TUI view.
 func Build(prices []planner.PriceSlot, schedule planner.ScheduleJSON, now time.Time, mode FilterMode, opts Options) string {
+	out, _ := build(prices, schedule, now, mode, opts)
+	return out
+}
+
+// ChartInfo describes a rendered chart for callers laying out around it.
+type ChartInfo struct {
+	Width int // longest line in cells, color tags excluded
+	Lines int // total lines
+	Rows  int // slot rows (excluding headers, sparkline and summaries)
+}
+
+// BuildWithInfo is like Build and also reports the chart's dimensions.
+func BuildWithInfo(prices []planner.PriceSlot, schedule planner.ScheduleJSON, now time.Time, mode FilterMode, opts Options) (string, ChartInfo) {
+	out, rows := build(prices, schedule, now, mode, opts)
+	info := ChartInfo{Rows: rows}
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if opts.Colorize {
+			line = stripTags(line)
+		}
+		info.Width = max(info.Width, utf8.RuneCountInString(line))
+		info.Lines++
+	}
+	return out, info
+}
+
+// build renders the chart and returns it with the number of slot rows.
+func build(prices []planner.PriceSlot, schedule planner.ScheduleJSON, now time.Time, mode FilterMode, opts Options) (string, int) {
 	def := defaultOptions()
 	if opts.MaxWidth == 0 {
 		opts.MaxWidth = def.MaxWidth
@@ -42,35 +178,83 @@ func Build(prices []planner.PriceSlot, schedule planner.ScheduleJSON, now time.T
 	if opts.MaxPoints == 0 {
 		opts.MaxPoints = def.MaxPoints
 	}
+	if opts.CurrencyLabel == "" {
+		opts.CurrencyLabel = def.CurrencyLabel
+	}
+	if opts.DecimalSeparator == "" {
+		opts.DecimalSeparator = def.DecimalSeparator
+	}
 
-	future := filterFuture(prices, now)
+	future := filterFuture(prices, now, opts.TomorrowOnly)
 	if len(future) == 0 {
-		return colorize("[red]No future slots available.[-:-:-]\n", opts.Colorize)
+		return colorize("[red]No future slots available.[-:-:-]\n", opts.Colorize), 0
 	}
 
-	minP := future[0].Price
-	maxP := future[0].Price
-	for _, s := range future {
-		if s.Price < minP {
-			minP = s.Price
-		}
-		if s.Price > maxP {
-			maxP = s.Price
-		}
+	var past []planner.PriceSlot
+	if opts.IncludePast && mode == FilterAll && !opts.TomorrowOnly {
+		past = filterPast(prices, now, opts.Lookback)
+	}
+
+	minP, maxP := priceBounds(past, future)
+	clampedLow, clampedHigh := false, false
+	if opts.ClampMin != nil && *opts.ClampMin > minP {
+		minP, clampedLow = *opts.ClampMin, true
+	}
+	if opts.ClampMax != nil && *opts.ClampMax < maxP {
+		maxP, clampedHigh = *opts.ClampMax, true
 	}
 
 	chargeSet := setFromSlots(schedule.ChargeSlots)
 	dischargeSet := setFromSlots(schedule.DischargeSlots)
+	power := powerFractions(schedule)
+	baseline := hourlyAverages(opts.History, now, baselineDays)
 
 	var b strings.Builder
-	fmt.Fprintf(&b, "%s\n", colorize(fmt.Sprintf("[yellow]Nord Pool chart for %s (c/kWh)[-:-:-]", schedule.Area), opts.Colorize))
+	fmt.Fprintf(&b, "%s\n", colorize(fmt.Sprintf("[yellow]Nord Pool chart for %s (%s)[-:-:-]", schedule.Area, opts.CurrencyLabel), opts.Colorize))
+	chargeSym, dischargeSym, idleSym := opts.symbols()
 	b.WriteString("Legend: ")
-	b.WriteString(colorize("[lime]C[-:-:-]", opts.Colorize))
+	b.WriteString(colorize(fmt.Sprintf("[lime]%c[-:-:-]", chargeSym), opts.Colorize))
 	b.WriteString("=charge  ")
-	b.WriteString(colorize("[red]D[-:-:-]", opts.Colorize))
+	b.WriteString(colorize(fmt.Sprintf("[red]%c[-:-:-]", dischargeSym), opts.Colorize))
 	b.WriteString("=discharge  ")
-	b.WriteString(colorize("[dodgerblue].[−][-:-:-]", opts.Colorize))
-	b.WriteString("=idle\n")
+	b.WriteString(colorize(fmt.Sprintf("[dodgerblue]%c[−][-:-:-]", idleSym), opts.Colorize))
+	b.WriteString("=idle")
+	if len(baseline) > 0 {
+		b.WriteString("  ")
+		b.WriteString(colorize("[gray]┊[-:-:-]", opts.Colorize))
+		b.WriteString("=7d avg")
+	}
+	b.WriteString("\n")
+
+	if schedule.Preliminary {
+		b.WriteString(colorize("[orange]* preliminary prices (not final yet)[-:-:-]\n", opts.Colorize))
+	}
+	if avg, ok := schedule.AreaAverages[planner.MarketDayKey(now)]; ok {
+		fmt.Fprintf(&b, "Today's average: %s %s\n", formatPrice(avg, 0, opts), opts.CurrencyLabel)
+	}
+	for _, w := range schedule.Warnings {
+		fmt.Fprintf(&b, "%s\n", colorize("[orange]! "+w+"[-:-:-]", opts.Colorize))
+	}
+	for _, s := range future {
+		if s.Filled {
+			b.WriteString(colorize("[orange]~ filled gap (interpolated, not planned)[-:-:-]\n", opts.Colorize))
+			break
+		}
+	}
+	var cheapStart, cheapEnd time.Time
+	if opts.HighlightCheapestHours > 0 {
+		if w, ok := planner.CheapestWindow(future, opts.HighlightCheapestHours); ok {
+			cheapStart, _ = time.Parse(time.RFC3339, w.Start)
+			cheapEnd, _ = time.Parse(time.RFC3339, w.End)
+			fmt.Fprintf(&b, "%s\n", colorize(fmt.Sprintf("[yellow]★ cheapest %gh window %s–%s, avg %s %s[-:-:-]",
+				opts.HighlightCheapestHours, cheapStart.Format("01-02 15:04"), cheapEnd.Format("15:04"),
+				formatPrice(w.AvgPrice, 0, opts), opts.CurrencyLabel), opts.Colorize))
+		}
+	}
+	if clampedLow || clampedHigh {
+		fmt.Fprintf(&b, "%s\n", colorize(fmt.Sprintf("[orange]bars clamped to %s..%s %s (▲ above, ▼ below)[-:-:-]",
+			formatPrice(minP, 0, opts), formatPrice(maxP, 0, opts), opts.CurrencyLabel), opts.Colorize))
+	}
 
 	b.WriteString("Filter: ")
 	switch mode {
@@ -85,12 +269,15 @@ func Build(prices []planner.PriceSlot, schedule planner.ScheduleJSON, now time.T
 
 	b.WriteString(buildSparkline(future, chargeSet, dischargeSet, minP, maxP, mode, opts))
 
-	// lineInfo: type 0=idle,1=charge,2=discharge
+	// lineInfo: type -1=past,0=idle,1=charge,2=discharge
 	type lineInfo struct {
 		slot planner.PriceSlot
 		typ  int
 	}
 	var lines []lineInfo
+	for _, s := range past {
+		lines = append(lines, lineInfo{slot: s, typ: -1})
+	}
 	for _, s := range future {
 		_, isC := chargeSet[s.Timestamp]
 		_, isD := dischargeSet[s.Timestamp]
@@ -114,16 +301,41 @@ func Build(prices []planner.PriceSlot, schedule planner.ScheduleJSON, now time.T
 
 	if len(lines) == 0 {
 		b.WriteString(colorize("[red]No slots for this filter.[-:-:-]\n", opts.Colorize))
-		return b.String()
+		return b.String(), 0
 	}
 
-	for i, ln := range lines {
+	shown := len(lines)
+	if opts.MaxRows > 0 && len(past)+opts.MaxRows < shown {
+		shown = len(past) + opts.MaxRows
+	}
+	var mean float64
+	if opts.ShowDelta {
+		var sum float64
+		var n int
+		for _, ln := range lines[:shown] {
+			if ln.typ != -1 {
+				sum += ln.slot.Price
+				n++
+			}
+		}
+		if n > 0 {
+			mean = sum / float64(n)
+		}
+		fmt.Fprintf(&b, "Δ vs mean %s %s\n", formatPrice(mean, 0, opts), opts.CurrencyLabel)
+	}
+	for i, ln := range lines[:shown] {
 		s := ln.slot
 		typ := ln.typ
 
+		if typ != -1 && i > 0 && lines[i-1].typ == -1 {
+			fmt.Fprintf(&b, "%s\n", colorize(fmt.Sprintf("[yellow]  ── now %s ──[-:-:-]", now.Format("01-02 15:04")), opts.Colorize))
+		}
+
 		color := ""
 		if opts.Colorize {
 			switch typ {
+			case -1:
+				color = "[gray]"
 			case 1:
 				color = "[lime]"
 			case 2:
@@ -134,9 +346,9 @@ func Build(prices []planner.PriceSlot, schedule planner.ScheduleJSON, now time.T
 		}
 
 		frame := " "
-		if typ != 0 {
+		if typ > 0 {
 			prevSame := i > 0 && lines[i-1].typ == typ
-			nextSame := i < len(lines)-1 && lines[i+1].typ == typ
+			nextSame := i < shown-1 && lines[i+1].typ == typ
 
 			var sym string
 			switch {
@@ -152,16 +364,21 @@ func Build(prices []planner.PriceSlot, schedule planner.ScheduleJSON, now time.T
 			frame = wrap(sym, color, opts.Colorize)
 		}
 
-		markChar := '.'
+		markChar := idleSym
 		markColor := ""
 		switch typ {
+		case -1:
+			markChar = ' '
+			if opts.Colorize {
+				markColor = "[gray]"
+			}
 		case 1:
-			markChar = 'C'
+			markChar = chargeSym
 			if opts.Colorize {
 				markColor = "[lime]"
 			}
 		case 2:
-			markChar = 'D'
+			markChar = dischargeSym
 			if opts.Colorize {
 				markColor = "[red]"
 			}
@@ -171,32 +388,87 @@ func Build(prices []planner.PriceSlot, schedule planner.ScheduleJSON, now time.T
 			}
 		}
 
-		rel := 0.0
-		if maxP > minP {
-			rel = (s.Price - minP) / (maxP - minP)
-		}
+		rel := math.Max(0, math.Min(1, relPrice(s.Price, minP, maxP)))
 		length := int(math.Round(rel * float64(opts.MaxWidth)))
-		if length < 1 {
-			length = 1
+		if f, ok := power[s.Timestamp]; ok && opts.ShowPower && typ > 0 {
+			length = int(math.Round(float64(opts.MaxWidth) * f))
+		}
+		// Prices below a ClampMin are drawn empty, as documented; the
+		// minimum bar only keeps in-scale rows visible.
+		belowClamp := clampedLow && s.Price < minP
+		if minBar := minBarLength(typ, opts); length < minBar && !belowClamp {
+			length = minBar
+		}
+		fill := fillGlyph(typ, opts)
+		bar := wrap(strings.Repeat(fill, length), color, opts.Colorize)
+		if avg, ok := baseline[s.Timestamp.UTC().Hour()]; ok {
+			bar = overlayBaseline(length, avg, minP, maxP, fill, color, opts)
+		}
+		if !cheapStart.IsZero() && !s.Timestamp.Before(cheapStart) && s.Timestamp.Before(cheapEnd) {
+			bar += colorize("[yellow] ★[-:-:-]", opts.Colorize)
+		}
+		switch {
+		case s.Price > maxP && clampedHigh:
+			bar += colorize("[orange]▲[-:-:-]", opts.Colorize)
+		case belowClamp:
+			bar += colorize("[orange]▼[-:-:-]", opts.Colorize)
 		}
-		bar := wrap(strings.Repeat("█", length), color, opts.Colorize)
 
 		ts := s.Timestamp.Format("01-02 15:04")
+		if opts.RelativeTimes {
+			ts = fmt.Sprintf("%11s", formatRelative(s.Timestamp.Sub(now)))
+		}
+
+		// filled (interpolated) prices get a tilde, preliminary ones an asterisk
+		prelim := " "
+		switch {
+		case s.Filled:
+			prelim = "~"
+		case s.Preliminary:
+			prelim = "*"
+		}
+
+		// optional converted price column, e.g. local-currency cents
+		secondary := ""
+		if schedule.SecondaryCurrency != "" {
+			if v, ok := planner.SecondaryPrice(s, schedule.SecondaryRate); ok {
+				secondary = fmt.Sprintf(" %s c %s |", formatPrice(v, 7, opts), schedule.SecondaryCurrency)
+			} else {
+				secondary = fmt.Sprintf(" %7s c %s |", "-", schedule.SecondaryCurrency)
+			}
+		}
+
+		// optional difference from the mean of the shown future rows
+		delta := ""
+		if opts.ShowDelta {
+			if typ == -1 {
+				delta = fmt.Sprintf(" %7s |", "")
+			} else {
+				delta = fmt.Sprintf(" %s |", formatDelta(s.Price-mean, 7, opts))
+			}
+		}
 
 		fmt.Fprintf(
 			&b,
-			"%s %s | %6.2f c/kWh | %s%c%s | %s\n",
+			"%s %s | %s %s%s|%s%s %s%c%s | %s\n",
 			frame,
 			ts,
-			s.Price,
+			formatPrice(s.Price, 6, opts),
+			opts.CurrencyLabel,
+			prelim,
+			secondary,
+			delta,
 			markColor,
 			markChar,
 			reset(opts.Colorize),
 			bar,
 		)
 	}
+	if hidden := len(lines) - shown; hidden > 0 {
+		fmt.Fprintf(&b, "%s\n", colorize(fmt.Sprintf("[gray]  … and %d more slots[-:-:-]", hidden), opts.Colorize))
+	}
 
-	return b.String()
+	return b.String(), shown
 }
 
 func buildSparkline(slots []planner.PriceSlot, chargeSet, dischargeSet map[time.Time]bool, minP, maxP float64, mode FilterMode, opts Options) string {
@@ -206,7 +478,10 @@ func buildSparkline(slots []planner.PriceSlot, chargeSet, dischargeSet map[time.
 
 	blocks := []rune("▁▂▃▄▅▆▇█")
 	n := len(blocks) - 1
+	chargeSym, dischargeSym, idleSym := opts.symbols()
 
+	// Buckets are averaged; a bucket takes the action of any member slot.
+	points := planner.DownsamplePrices(slots, opts.MaxPoints)
 	step := 1
 	if len(slots) > opts.MaxPoints {
 		step = int(math.Ceil(float64(len(slots)) / float64(opts.MaxPoints)))
@@ -215,11 +490,12 @@ func buildSparkline(slots []planner.PriceSlot, chargeSet, dischargeSet map[time.
 	var line1 strings.Builder
 	var line2 strings.Builder
 
-	for i := 0; i < len(slots); i += step {
-		s := slots[i]
-
-		_, isC := chargeSet[s.Timestamp]
-		_, isD := dischargeSet[s.Timestamp]
+	for k, p := range points {
+		isC, isD := false, false
+		for _, s := range slots[k*step : min((k+1)*step, len(slots))] {
+			isC = isC || chargeSet[s.Timestamp]
+			isD = isD || dischargeSet[s.Timestamp]
+		}
 		if mode == FilterChargeOnly && !isC {
 			continue
 		}
@@ -227,10 +503,7 @@ func buildSparkline(slots []planner.PriceSlot, chargeSet, dischargeSet map[time.
 			continue
 		}
 
-		rel := 0.0
-		if maxP > minP {
-			rel = (s.Price - minP) / (maxP - minP)
-		}
+		rel := relPrice(p.Price, minP, maxP)
 		idx := int(math.Round(rel * float64(n)))
 		if idx < 0 {
 			idx = 0
@@ -241,21 +514,25 @@ func buildSparkline(slots []planner.PriceSlot, chargeSet, dischargeSet map[time.
 		ch := blocks[idx]
 
 		color := ""
-		mark := "."
+		mark := string(idleSym)
 		switch {
 		case isC:
 			color = "[lime]"
-			mark = "C"
+			mark = string(chargeSym)
 		case isD:
 			color = "[red]"
-			mark = "D"
+			mark = string(dischargeSym)
 		default:
 			if opts.Colorize {
 				color = "[dodgerblue]"
 			}
 		}
 
-		line1.WriteString(wrap(string(ch), color, opts.Colorize))
+		blockColor := color
+		if opts.SparkColorBy == SparkColorPrice {
+			blockColor = priceBandColor(p.Price, opts)
+		}
+		line1.WriteString(wrap(string(ch), blockColor, opts.Colorize))
 		line2.WriteString(wrap(mark, color, opts.Colorize))
 	}
 
@@ -264,7 +541,12 @@ func buildSparkline(slots []planner.PriceSlot, chargeSet, dischargeSet map[time.
 	}
 
 	var b strings.Builder
-	b.WriteString("Sparkline: prices (blocks) / mode (C/D/.)\n")
+	legend := fmt.Sprintf("mode (%c/%c/%c)", chargeSym, dischargeSym, idleSym)
+	if opts.SparkColorBy == SparkColorPrice {
+		b.WriteString("Sparkline: prices (blocks, colored by price band) / " + legend + "\n")
+	} else {
+		b.WriteString("Sparkline: prices (blocks) / " + legend + "\n")
+	}
 	b.WriteString(line1.String())
 	b.WriteString("\n")
 	b.WriteString(line2.String())
@@ -272,6 +554,132 @@ func buildSparkline(slots []planner.PriceSlot, chargeSet, dischargeSet map[time.
 	return b.String()
 }
 
+// priceBandColor returns the SparkColorPrice color for an absolute price.
+func priceBandColor(p float64, opts Options) string {
+	cheap, expensive := opts.CheapBelow, opts.ExpensiveAbove
+	if cheap == 0 && expensive == 0 {
+		cheap, expensive = defaultCheapBelow, defaultExpensiveAbove
+	}
+	switch {
+	case p < cheap:
+		return "[green]"
+	case p > expensive:
+		return "[red]"
+	default:
+		return "[yellow]"
+	}
+}
+
+// hourlyAverages averages history by UTC hour-of-day over the given number of
+// days before now. Hours without data are absent from the result.
+func hourlyAverages(history []planner.PriceSlot, now time.Time, days int) map[int]float64 {
+	if len(history) == 0 {
+		return nil
+	}
+	start := now.Add(-time.Duration(days) * 24 * time.Hour)
+	sums := make(map[int]float64)
+	counts := make(map[int]int)
+	for _, s := range history {
+		if s.Timestamp.Before(start) || !s.Timestamp.Before(now) {
+			continue
+		}
+		h := s.Timestamp.UTC().Hour()
+		sums[h] += s.Price
+		counts[h]++
+	}
+	out := make(map[int]float64, len(sums))
+	for h, sum := range sums {
+		out[h] = sum / float64(counts[h])
+	}
+	return out
+}
+
+// fillGlyph returns the bar glyph for a row type (see lineInfo).
+func fillGlyph(typ int, opts Options) string {
+	if !opts.DistinctFills {
+		return "█"
+	}
+	switch typ {
+	case 1:
+		return "█"
+	case 2:
+		return "▓"
+	default:
+		return "░"
+	}
+}
+
+// minBarLength returns the shortest bar for a row of action typ (1 charge,
+// 2 discharge, otherwise idle).
+func minBarLength(typ int, opts Options) int {
+	minBar := opts.IdleMinBar
+	if typ > 0 {
+		minBar = opts.ActionMinBar
+	}
+	if minBar == nil {
+		return 1
+	}
+	return max(0, *minBar)
+}
+
+// overlayBaseline draws a bar of the given length with a faint marker at the
+// position of avg on the same scale.
+func overlayBaseline(length int, avg, minP, maxP float64, fill, color string, opts Options) string {
+	rel := relPrice(avg, minP, maxP)
+	pos := int(math.Round(rel * float64(opts.MaxWidth)))
+	if pos < 0 {
+		pos = 0
+	}
+	if pos > opts.MaxWidth {
+		pos = opts.MaxWidth
+	}
+	marker := wrap("┊", "[gray]", opts.Colorize)
+	if pos < length {
+		return wrap(strings.Repeat(fill, pos), color, opts.Colorize) +
+			marker +
+			wrap(strings.Repeat(fill, length-pos-1), color, opts.Colorize)
+	}
+	return wrap(strings.Repeat(fill, length), color, opts.Colorize) +
+		strings.Repeat(" ", pos-length) +
+		marker
+}
+
+// priceBounds returns the min and max price across all groups.
+func priceBounds(groups ...[]planner.PriceSlot) (float64, float64) {
+	minP, maxP := math.Inf(1), math.Inf(-1)
+	for _, group := range groups {
+		for _, s := range group {
+			minP = math.Min(minP, s.Price)
+			maxP = math.Max(maxP, s.Price)
+		}
+	}
+	return minP, maxP
+}
+
+// relPrice maps p onto [0,1] within [minP,maxP]; a flat range maps to 0.
+func relPrice(p, minP, maxP float64) float64 {
+	if maxP > minP {
+		return (p - minP) / (maxP - minP)
+	}
+	return 0
+}
+
+// powerFractions maps charge/discharge slot times to their PowerFraction.
+func powerFractions(schedule planner.ScheduleJSON) map[time.Time]float64 {
+	out := make(map[time.Time]float64)
+	for _, group := range [][]planner.SlotJSON{schedule.ChargeSlots, schedule.DischargeSlots} {
+		for _, s := range group {
+			if s.PowerFraction == nil {
+				continue
+			}
+			if ts, err := time.Parse(time.RFC3339, s.Timestamp); err == nil {
+				out[ts] = *s.PowerFraction
+			}
+		}
+	}
+	return out
+}
+
 func setFromSlots(slots []planner.SlotJSON) map[time.Time]bool {
 	out := make(map[time.Time]bool, len(slots))
 	for _, s := range slots {
@@ -283,7 +691,12 @@ func setFromSlots(slots []planner.SlotJSON) map[time.Time]bool {
 	return out
 }
 
-func filterFuture(prices []planner.PriceSlot, now time.Time) []planner.PriceSlot {
+// filterFuture returns slots from now on, or only tomorrow's when
+// tomorrowOnly is set.
+func filterFuture(prices []planner.PriceSlot, now time.Time, tomorrowOnly bool) []planner.PriceSlot {
+	if tomorrowOnly {
+		prices = planner.TomorrowSlots(prices, now)
+	}
 	var future []planner.PriceSlot
 	for _, p := range prices {
 		if !p.Timestamp.Before(now) {
@@ -293,6 +706,53 @@ func filterFuture(prices []planner.PriceSlot, now time.Time) []planner.PriceSlot
 	return future
 }
 
+// filterPast returns slots in [now-lookback, now). A zero lookback starts at
+// midnight UTC of now's day.
+func filterPast(prices []planner.PriceSlot, now time.Time, lookback time.Duration) []planner.PriceSlot {
+	start := now.Add(-lookback)
+	if lookback <= 0 {
+		u := now.UTC()
+		start = time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	var past []planner.PriceSlot
+	for _, p := range prices {
+		if !p.Timestamp.Before(start) && p.Timestamp.Before(now) {
+			past = append(past, p)
+		}
+	}
+	return past
+}
+
+// formatPrice prints p with two decimals, right-aligned to width, using
+// opts.DecimalSeparator.
+func formatPrice(p float64, width int, opts Options) string {
+	out := fmt.Sprintf("%*.2f", width, p)
+	if opts.DecimalSeparator != "" && opts.DecimalSeparator != "." {
+		out = strings.Replace(out, ".", opts.DecimalSeparator, 1)
+	}
+	return out
+}
+
+// formatRelative prints d as a signed hours-and-minutes offset, e.g. "+2h15m".
+func formatRelative(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%s%dh%02dm", sign, int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
+
+// formatDelta prints d signed with one decimal, right-aligned to width, using
+// opts.DecimalSeparator.
+func formatDelta(d float64, width int, opts Options) string {
+	out := fmt.Sprintf("%+*.1f", width, d)
+	if opts.DecimalSeparator != "" && opts.DecimalSeparator != "." {
+		out = strings.Replace(out, ".", opts.DecimalSeparator, 1)
+	}
+	return out
+}
+
 func colorize(s string, colorize bool) string {
 	if !colorize {
 		return stripTags(s)
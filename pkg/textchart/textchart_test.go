@@ -0,0 +1,716 @@
+package textchart
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"gordpool/pkg/planner"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files instead of comparing")
+
+// fixture returns a deterministic day of hourly prices with a cheap night and
+// an evening peak, planned with fixed params at now.
+func fixture(now time.Time) ([]planner.PriceSlot, planner.ScheduleJSON) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	curve := []float64{
+		4.2, 3.8, 3.1, 2.9, 3.0, 4.5, 7.8, 11.2,
+		12.5, 10.1, 8.4, 7.9, 7.2, 6.8, 7.5, 9.3,
+		13.6, 16.8, 18.2, 15.4, 11.9, 8.7, 6.1, 5.0,
+	}
+	prices := make([]planner.PriceSlot, len(curve))
+	for i, p := range curve {
+		prices[i] = planner.PriceSlot{Timestamp: day.Add(time.Duration(i) * time.Hour), Price: p}
+	}
+	params := planner.BatteryStrategyParams{
+		Area:              "LV",
+		MaxChargeHours:    3,
+		MaxDischargeHours: 3,
+		LastPriceCharged:  8,
+		Epsilon:           0.5,
+		Market:            "DayAhead",
+		Currency:          "EUR",
+	}
+	return prices, planner.BuildBatterySchedule(prices, params, now)
+}
+
+func TestBuildGolden(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		now  time.Time
+		mode FilterMode
+		opts Options
+	}{
+		{"all", day, FilterAll, Options{}},
+		{"charge_only", day, FilterChargeOnly, Options{}},
+		{"include_past", day.Add(14 * time.Hour), FilterAll, Options{IncludePast: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices, schedule := fixture(tt.now)
+			got := Build(prices, schedule, tt.now, tt.mode, tt.opts)
+			if strings.Contains(got, "[-:-:-]") {
+				t.Fatalf("output contains color tags:\n%s", got)
+			}
+			path := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if string(want) != got {
+				t.Errorf("output differs from %s\n--- want\n%s--- got\n%s", path, want, got)
+			}
+		})
+	}
+}
+
+func TestClampMinDrawsEmptyBar(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, schedule := fixture(day)
+	clamp := 5.0
+	got := Build(prices, schedule, day, FilterAll, Options{ClampMin: &clamp})
+
+	tests := []struct {
+		row     string
+		wantBar string
+	}{
+		{"01-15 03:00", "▼"}, // 2.90, below the clamp: no bar, just the marker
+		{"01-15 05:00", "▼"}, // 4.50, below the clamp
+		{"01-15 23:00", "█"}, // 5.00, at the clamp: still gets the minimum bar
+	}
+	for _, tt := range tests {
+		line := rowFor(t, got, tt.row)
+		bar := strings.TrimSpace(line[strings.LastIndex(line, "|")+1:])
+		if bar != tt.wantBar {
+			t.Errorf("%s: bar = %q, want %q", tt.row, bar, tt.wantBar)
+		}
+	}
+}
+
+func rowFor(t *testing.T, chart, ts string) string {
+	t.Helper()
+	for _, line := range strings.Split(chart, "\n") {
+		if strings.Contains(line, ts) {
+			return line
+		}
+	}
+	t.Fatalf("no row for %s in\n%s", ts, chart)
+	return ""
+}
+
+func TestShowDeltaUnit(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, schedule := fixture(day)
+
+	tests := []struct {
+		name  string
+		label string
+	}{
+		{"default", ""},
+		{"custom", "öre/kWh"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Build(prices, schedule, day, FilterAll, Options{ShowDelta: true, CurrencyLabel: tt.label})
+			line := rowFor(t, got, "01-15 18:00")
+			cols := strings.Split(line, "|")
+			if len(cols) < 4 {
+				t.Fatalf("unexpected row %q", line)
+			}
+			// 18.20 against a mean of 8.58 over the day, with no unit suffix.
+			if delta := strings.TrimSpace(cols[2]); delta != "+9.6" {
+				t.Errorf("delta column = %q, want %q", delta, "+9.6")
+			}
+		})
+	}
+}
+
+func TestHourlyAverages(t *testing.T) {
+	now := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	// A week of history where day d (1..7 back) prices hour h at d+h, plus
+	// one slot older than the window and one at now, both ignored.
+	var history []planner.PriceSlot
+	for d := 1; d <= 7; d++ {
+		day := now.Add(-time.Duration(d) * 24 * time.Hour)
+		for h := 0; h < 24; h++ {
+			history = append(history, planner.PriceSlot{Timestamp: day.Add(time.Duration(h) * time.Hour), Price: float64(d + h)})
+		}
+	}
+	history = append(history,
+		planner.PriceSlot{Timestamp: now.Add(-8 * 24 * time.Hour), Price: 1000},
+		planner.PriceSlot{Timestamp: now, Price: 1000},
+	)
+
+	got := hourlyAverages(history, now, baselineDays)
+	if len(got) != 24 {
+		t.Fatalf("got %d hours, want 24", len(got))
+	}
+	// The mean of d over 1..7 is 4, so hour h averages 4+h.
+	for _, h := range []int{0, 7, 23} {
+		if want := float64(4 + h); got[h] != want {
+			t.Errorf("hour %d = %v, want %v", h, got[h], want)
+		}
+	}
+	if hourlyAverages(nil, now, baselineDays) != nil {
+		t.Error("empty history should yield no overlay")
+	}
+}
+
+func TestBaselineOverlayUsesUTCHour(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	riga, err := time.LoadLocation("Europe/Riga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// History only covers 17:00 UTC, so exactly one row gets the marker.
+	history := []planner.PriceSlot{{Timestamp: day.Add(-7 * time.Hour), Price: 10}}
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		row  string // label of the 17:00 UTC slot
+	}{
+		{"utc", time.UTC, "01-15 17:00"},
+		{"riga", riga, "01-15 19:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices, schedule := fixture(day)
+			for i := range prices {
+				prices[i].Timestamp = prices[i].Timestamp.In(tt.loc)
+			}
+			got := Build(prices, schedule, day, FilterAll, Options{History: history})
+			if line := rowFor(t, got, tt.row); !strings.Contains(line, "┊") {
+				t.Errorf("%s has no baseline marker:\n%s", tt.row, got)
+			}
+			marked := 0
+			for _, line := range strings.Split(got, "\n") {
+				if strings.Contains(line, "c/kWh |") && strings.Contains(line, "┊") {
+					marked++
+				}
+			}
+			if marked != 1 {
+				t.Errorf("%d rows have a baseline marker, want 1:\n%s", marked, got)
+			}
+		})
+	}
+}
+
+func TestClampMaxOutlier(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, schedule := fixture(day)
+	prices[18].Price = 500 // the 18:00 spike
+
+	// distinctBars counts the different bar lengths among the normal rows.
+	distinctBars := func(chart string) int {
+		lengths := map[int]bool{}
+		for h := 0; h < 24; h++ {
+			if h == 18 {
+				continue
+			}
+			line := rowFor(t, chart, fmt.Sprintf("01-15 %02d:00", h))
+			lengths[strings.Count(line[strings.LastIndex(line, "|"):], "█")] = true
+		}
+		return len(lengths)
+	}
+
+	clamp := 20.0
+	tests := []struct {
+		name     string
+		opts     Options
+		minBars  int
+		maxBars  int
+		wantMark bool
+	}{
+		{"unclamped", Options{}, 1, 3, false},
+		{"clamped", Options{ClampMax: &clamp}, 10, 24, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Build(prices, schedule, day, FilterAll, tt.opts)
+			if n := distinctBars(got); n < tt.minBars || n > tt.maxBars {
+				t.Errorf("%d distinct bar lengths, want %d..%d\n%s", n, tt.minBars, tt.maxBars, got)
+			}
+			spike := rowFor(t, got, "01-15 18:00")
+			if !strings.Contains(spike, "500.00") {
+				t.Errorf("spike row %q should show the true price", spike)
+			}
+			if strings.HasSuffix(spike, "▲") != tt.wantMark {
+				t.Errorf("spike row %q: ▲ marker = %t, want %t", spike, !tt.wantMark, tt.wantMark)
+			}
+		})
+	}
+}
+
+func TestDistinctFills(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, schedule := fixture(day)
+
+	tests := []struct {
+		row       string
+		wantGlyph string
+	}{
+		{"01-15 03:00", "█"}, // charge
+		{"01-15 18:00", "▓"}, // discharge
+		{"01-15 08:00", "░"}, // idle
+	}
+	for _, distinct := range []bool{false, true} {
+		got := Build(prices, schedule, day, FilterAll, Options{DistinctFills: distinct})
+		for _, tt := range tests {
+			line := rowFor(t, got, tt.row)
+			bar := strings.TrimSpace(line[strings.LastIndex(line, "|")+1:])
+			want := "█"
+			if distinct {
+				want = tt.wantGlyph
+			}
+			if bar == "" || strings.Trim(bar, want) != "" {
+				t.Errorf("DistinctFills=%t %s: bar %q, want only %q", distinct, tt.row, bar, want)
+			}
+		}
+	}
+}
+
+func TestLocaleFormatting(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, schedule := fixture(day)
+
+	tests := []struct {
+		name      string
+		opts      Options
+		wantTitle string
+		wantRow   string
+	}{
+		{"default", Options{}, "(c/kWh)", "  18.20 c/kWh |"},
+		{"comma and custom label", Options{CurrencyLabel: "snt/kWh", DecimalSeparator: ","}, "(snt/kWh)", "  18,20 snt/kWh |"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Build(prices, schedule, day, FilterAll, tt.opts)
+			title := strings.SplitN(got, "\n", 2)[0]
+			if !strings.HasSuffix(title, tt.wantTitle) {
+				t.Errorf("title %q, want suffix %q", title, tt.wantTitle)
+			}
+			if row := rowFor(t, got, "01-15 18:00"); !strings.Contains(row, tt.wantRow) {
+				t.Errorf("row %q, want %q", row, tt.wantRow)
+			}
+			if tt.opts.DecimalSeparator == "," && strings.Contains(got, "18.20") {
+				t.Errorf("output still uses '.' decimals:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestMaxRows(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		opts     Options
+		wantRows int
+		wantMore string
+		wantNow  bool
+	}{
+		{"unlimited", day, Options{}, 24, "", false},
+		{"capped", day, Options{MaxRows: 5}, 5, "  … and 19 more slots", false},
+		{"cap above the row count", day, Options{MaxRows: 30}, 24, "", false},
+		// Three past rows (07-09) plus the next five (10-14).
+		{"past rows not counted", day.Add(10 * time.Hour), Options{MaxRows: 5, IncludePast: true, Lookback: 3 * time.Hour},
+			8, "  … and 9 more slots", true},
+		// The cut falls inside the 17-19 discharge run; 18:00 closes the frame.
+		{"cut inside a run", day.Add(10 * time.Hour), Options{MaxRows: 9, IncludePast: true, Lookback: 3 * time.Hour},
+			12, "  … and 5 more slots", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices, schedule := fixture(tt.now)
+			got, info := BuildWithInfo(prices, schedule, tt.now, FilterAll, tt.opts)
+			rows := strings.Count(got, "c/kWh |")
+			if rows != tt.wantRows || info.Rows != tt.wantRows {
+				t.Errorf("rendered %d rows, info %d, want %d", rows, info.Rows, tt.wantRows)
+			}
+			lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+			last := lines[len(lines)-1]
+			if hasMore := strings.Contains(got, "more slots"); hasMore != (tt.wantMore != "") {
+				t.Fatalf("summary line present = %t, want %t:\n%s", hasMore, tt.wantMore != "", got)
+			}
+			if tt.wantMore != "" && last != tt.wantMore {
+				t.Errorf("last line %q, want %q", last, tt.wantMore)
+			}
+			if hasNow := strings.Contains(got, "── now"); hasNow != tt.wantNow {
+				t.Errorf("now marker present = %t, want %t:\n%s", hasNow, tt.wantNow, got)
+			}
+			// The last rendered row never leaves a frame open.
+			var lastRow string
+			for _, line := range lines {
+				if strings.Contains(line, "c/kWh |") {
+					lastRow = line
+				}
+			}
+			if frame := strings.TrimSpace(lastRow)[:len("╭")]; frame == "╭" || frame == "│" {
+				t.Errorf("last row %q leaves its frame open", lastRow)
+			}
+		})
+	}
+}
+
+func TestShowPower(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, _ := fixture(day)
+	// 10 kW into a 15 kWh battery: the first charge hour runs at full power,
+	// the second only has room for half.
+	params := planner.BatteryStrategyParams{
+		Area: "LV", MaxChargeHours: 2, LastPriceCharged: 8, Epsilon: 0.5,
+		CapacityKWh: 15, PowerKW: 10,
+	}
+	schedule := planner.BuildBatterySchedule(prices, params, day)
+
+	barLen := func(chart, row string) int {
+		line := rowFor(t, chart, row)
+		return strings.Count(line[strings.LastIndex(line, "|"):], "█")
+	}
+	tests := []struct {
+		name      string
+		showPower bool
+		full      int
+		half      int
+	}{
+		// By price both charge hours (2.90 and 3.00) sit at the minimum bar.
+		{"by price", false, 1, 1},
+		{"by power", true, 30, 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Build(prices, schedule, day, FilterAll, Options{ShowPower: tt.showPower})
+			full, half := barLen(got, "01-15 03:00"), barLen(got, "01-15 04:00")
+			if full != tt.full || half != tt.half {
+				t.Errorf("full-power bar %d, half-power bar %d; want %d and %d\n%s", full, half, tt.full, tt.half, got)
+			}
+		})
+	}
+}
+
+func TestHighlightCheapestHours(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, schedule := fixture(day)
+
+	tests := []struct {
+		name   string
+		hours  float64
+		marked []string
+	}{
+		{"off", 0, nil},
+		// 03:00-05:00 averages 2.95, just under 02:00-04:00 at 3.00.
+		{"two hours", 2, []string{"01-15 03:00", "01-15 04:00"}},
+		{"one hour", 1, []string{"01-15 03:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Build(prices, schedule, day, FilterAll, Options{HighlightCheapestHours: tt.hours})
+			var marked []string
+			for _, line := range strings.Split(got, "\n") {
+				if strings.Contains(line, " c/kWh |") && strings.HasSuffix(line, " ★") {
+					i := strings.Index(line, "01-15 ")
+					marked = append(marked, line[i:i+len("01-15 00:00")])
+				}
+			}
+			if strings.Join(marked, ",") != strings.Join(tt.marked, ",") {
+				t.Errorf("marked rows %v, want %v", marked, tt.marked)
+			}
+			if hasLegend := strings.Contains(got, "★ cheapest"); hasLegend != (tt.hours > 0) {
+				t.Errorf("legend line present = %t, want %t", hasLegend, tt.hours > 0)
+			}
+		})
+	}
+}
+
+func TestTomorrowOnly(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	var prices []planner.PriceSlot
+	for h := 0; h < 48; h++ {
+		prices = append(prices, planner.PriceSlot{Timestamp: day.Add(time.Duration(h) * time.Hour), Price: float64(5 + h%7)})
+	}
+	now := day.Add(18 * time.Hour)
+	params := planner.BatteryStrategyParams{Area: "LV", MaxChargeHours: 2, LastPriceCharged: 8, Epsilon: 1, TomorrowOnly: true}
+	schedule := planner.BuildBatterySchedule(prices, params, now)
+
+	tests := []struct {
+		name     string
+		only     bool
+		wantRows int
+		first    string
+	}{
+		{"rest of horizon", false, 30, "01-15 18:00"},
+		// Tomorrow in market time (CET) starts at 23:00 UTC.
+		{"tomorrow only", true, 24, "01-15 23:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, info := BuildWithInfo(prices, schedule, now, FilterAll, Options{TomorrowOnly: tt.only})
+			if info.Rows != tt.wantRows {
+				t.Errorf("rows = %d, want %d", info.Rows, tt.wantRows)
+			}
+			for _, line := range strings.Split(got, "\n") {
+				if strings.Contains(line, " c/kWh |") {
+					if !strings.Contains(line, tt.first) {
+						t.Errorf("first row %q, want %s", line, tt.first)
+					}
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestShowDeltaSumsToZero(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, schedule := fixture(day)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		opts Options
+	}{
+		{"whole day", day, Options{ShowDelta: true}},
+		{"capped rows", day, Options{ShowDelta: true, MaxRows: 7}},
+		// Past rows show no delta and are left out of the mean.
+		{"with past rows", day.Add(14 * time.Hour), Options{ShowDelta: true, IncludePast: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Build(prices, schedule, tt.now, FilterAll, tt.opts)
+			var sum float64
+			var n int
+			for _, line := range strings.Split(got, "\n") {
+				cols := strings.Split(line, "|")
+				if len(cols) < 4 || !strings.Contains(cols[1], "c/kWh") {
+					continue
+				}
+				field := strings.TrimSpace(cols[2])
+				if field == "" {
+					continue
+				}
+				v, err := strconv.ParseFloat(field, 64)
+				if err != nil {
+					t.Fatalf("delta %q in %q: %v", field, line, err)
+				}
+				sum += v
+				n++
+			}
+			if n == 0 {
+				t.Fatal("no deltas rendered")
+			}
+			// Each delta is rounded to 0.1, so allow half a step per row.
+			if math.Abs(sum) > 0.05*float64(n) {
+				t.Errorf("deltas over %d rows sum to %.2f, want ~0", n, sum)
+			}
+		})
+	}
+}
+
+func TestBuildWithInfoWidth(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, schedule := fixture(day)
+
+	longest := func(s string) int {
+		w := 0
+		for _, line := range strings.Split(s, "\n") {
+			w = max(w, utf8.RuneCountInString(line))
+		}
+		return w
+	}
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"default", Options{}},
+		{"narrow bars", Options{MaxWidth: 8}},
+		{"wide bars", Options{MaxWidth: 80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain, info := BuildWithInfo(prices, schedule, day, FilterAll, tt.opts)
+			if want := longest(plain); info.Width != want {
+				t.Errorf("width = %d, longest line is %d", info.Width, want)
+			}
+			if want := strings.Count(plain, "\n"); info.Lines != want {
+				t.Errorf("lines = %d, want %d", info.Lines, want)
+			}
+
+			// Color tags take no cells.
+			colored := tt.opts
+			colored.Colorize = true
+			if _, cinfo := BuildWithInfo(prices, schedule, day, FilterAll, colored); cinfo.Width != info.Width {
+				t.Errorf("colorized width = %d, want %d", cinfo.Width, info.Width)
+			}
+		})
+	}
+}
+
+func TestRelativeTimes(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, schedule := fixture(day)
+	now := day.Add(10 * time.Hour)
+	got := Build(prices, schedule, now, FilterAll, Options{RelativeTimes: true, IncludePast: true, Lookback: 2 * time.Hour})
+
+	tests := []struct {
+		price string // identifies the row
+		want  string
+	}{
+		{"  12.50 c/kWh", "-2h00m"}, // 08:00
+		{"   8.40 c/kWh", "+0h00m"}, // 10:00, current slot
+		{"   7.20 c/kWh", "+2h00m"}, // 12:00, two hours ahead
+		{"   5.00 c/kWh", "+13h00m"},
+	}
+	for _, tt := range tests {
+		line := rowFor(t, got, tt.price)
+		if ts := strings.TrimSpace(strings.Split(line, "|")[0]); !strings.HasSuffix(ts, tt.want) {
+			t.Errorf("row %q: time %q, want %q", line, ts, tt.want)
+		}
+	}
+}
+
+func TestFormatRelative(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{2 * time.Hour, "+2h00m"},
+		{2*time.Hour + 15*time.Minute, "+2h15m"},
+		{-30 * time.Minute, "-0h30m"},
+		{0, "+0h00m"},
+		{89*time.Second + 30*time.Minute, "+0h31m"},
+	}
+	for _, tt := range tests {
+		if got := formatRelative(tt.d); got != tt.want {
+			t.Errorf("formatRelative(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestSparkColorByPrice(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	slots := []planner.PriceSlot{
+		{Timestamp: day, Price: 2},
+		{Timestamp: day.Add(time.Hour), Price: 10},
+		{Timestamp: day.Add(2 * time.Hour), Price: 20},
+	}
+	charge := map[time.Time]bool{day: true}
+	discharge := map[time.Time]bool{day.Add(2 * time.Hour): true}
+
+	tests := []struct {
+		name   string
+		opts   Options
+		blocks string
+	}{
+		{"action", Options{Colorize: true, MaxPoints: 48},
+			"[lime]▁[-:-:-][dodgerblue]▄[-:-:-][red]█[-:-:-]"},
+		{"price default bands", Options{Colorize: true, MaxPoints: 48, SparkColorBy: SparkColorPrice},
+			"[green]▁[-:-:-][yellow]▄[-:-:-][red]█[-:-:-]"},
+		{"price custom bands", Options{Colorize: true, MaxPoints: 48, SparkColorBy: SparkColorPrice, CheapBelow: 12, ExpensiveAbove: 25},
+			"[green]▁[-:-:-][green]▄[-:-:-][yellow]█[-:-:-]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := strings.Split(buildSparkline(slots, charge, discharge, 2, 20, FilterAll, tt.opts), "\n")
+			if len(lines) < 3 {
+				t.Fatalf("sparkline too short: %q", lines)
+			}
+			if lines[1] != tt.blocks {
+				t.Errorf("blocks = %q, want %q", lines[1], tt.blocks)
+			}
+			// The mode line keeps action colors in both modes.
+			if want := "[lime]C[-:-:-][dodgerblue].[-:-:-][red]D[-:-:-]"; lines[2] != want {
+				t.Errorf("mode line = %q, want %q", lines[2], want)
+			}
+		})
+	}
+}
+
+func TestArrowSymbols(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, schedule := fixture(day)
+	opts := Options{ChargeSymbol: '↑', DischargeSymbol: '↓', IdleSymbol: '·'}
+	got := Build(prices, schedule, day, FilterAll, opts)
+
+	tests := []struct {
+		price string // identifies the row
+		mark  string
+	}{
+		{"   2.90 c/kWh", "| ↑ |"},
+		{"  18.20 c/kWh", "| ↓ |"},
+		{"  12.50 c/kWh", "| · |"},
+	}
+	for _, tt := range tests {
+		if line := rowFor(t, got, tt.price); !strings.Contains(line, tt.mark) {
+			t.Errorf("row %q lacks marker %q", line, tt.mark)
+		}
+	}
+	for _, want := range []string{
+		"Legend: ↑=charge  ↓=discharge  ·=idle",
+		"Sparkline: prices (blocks) / mode (↑/↓/·)",
+		"··↑↑↑············↓↓↓····",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("chart lacks %q:\n%s", want, got)
+		}
+	}
+	for _, old := range []string{"| C |", "| D |", "| . |"} {
+		if strings.Contains(got, old) {
+			t.Errorf("chart still uses %q:\n%s", old, got)
+		}
+	}
+}
+
+func TestMinBar(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	prices, _ := fixture(day)
+	// 03:00 carries the day's minimum price (2.90).
+	idle := planner.ScheduleJSON{Area: "LV"}
+	charged := planner.ScheduleJSON{Area: "LV", ChargeSlots: []planner.SlotJSON{
+		{Timestamp: day.Add(3 * time.Hour).Format(time.RFC3339), Price: 2.9},
+	}}
+	zero, two := 0, 2
+
+	tests := []struct {
+		name     string
+		schedule planner.ScheduleJSON
+		opts     Options
+		want     int // bar cells on the 03:00 row
+	}{
+		{"idle default", idle, Options{}, 1},
+		{"idle blank", idle, Options{IdleMinBar: &zero}, 0},
+		{"idle two", idle, Options{IdleMinBar: &two}, 2},
+		{"action ignores idle setting", charged, Options{IdleMinBar: &zero}, 1},
+		{"action blank", charged, Options{ActionMinBar: &zero}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Build(prices, tt.schedule, day, FilterAll, tt.opts)
+			row := rowFor(t, got, "01-15 03:00")
+			bar := row[strings.LastIndex(row, "|")+1:]
+			if n := strings.Count(bar, "█"); n != tt.want {
+				t.Errorf("row %q has %d bar cells, want %d", row, n, tt.want)
+			}
+			// Rows above the minimum keep their scaled bars.
+			if n := strings.Count(rowFor(t, got, "01-15 18:00"), "█"); n != defaultOptions().MaxWidth {
+				t.Errorf("18:00 row has %d bar cells, want %d", n, defaultOptions().MaxWidth)
+			}
+		})
+	}
+}
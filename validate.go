@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gordpool/pkg/planner"
+)
+
+// runValidate implements "gordpool validate": it lints saved price responses
+// and schedules, e.g. captured data in CI, printing one line per problem. It
+// returns the process exit code: 0 when every file is valid, 1 when any has
+// a problem, 2 for usage errors.
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		area = fs.String("area", "LV", "delivery area whose prices must be present")
+		kind = fs.String("kind", "auto", "file kind: prices (DayAheadPrices response), schedule (ScheduleJSON) or auto")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gordpool validate [flags] file...\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *kind != "auto" && *kind != "prices" && *kind != "schedule" {
+		fmt.Fprintf(stderr, "invalid -kind %q (want prices, schedule or auto)\n", *kind)
+		return 2
+	}
+
+	code := 0
+	for _, path := range fs.Args() {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "read %s: %v\n", path, err)
+			code = 1
+			continue
+		}
+		k := *kind
+		if k == "auto" {
+			k = "prices"
+			if bytes.Contains(body, []byte(`"charge_slots"`)) {
+				k = "schedule"
+			}
+		}
+
+		var issues []planner.ValidationIssue
+		if k == "schedule" {
+			issues, err = planner.ValidateScheduleJSON(bytes.NewReader(body))
+		} else {
+			issues, err = planner.ValidateDayAheadResponse(bytes.NewReader(body), *area)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			code = 1
+			continue
+		}
+		for _, issue := range issues {
+			fmt.Fprintf(stdout, "%s: %s\n", path, issue)
+		}
+		if len(issues) > 0 {
+			code = 1
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: ok (%s)\n", path, k)
+	}
+	return code
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunValidate(t *testing.T) {
+	tests := []struct {
+		file     string
+		wantCode int
+		wantOut  string
+	}{
+		{"valid.json", 0, "ok (prices)"},
+		{"bad_timestamp.json", 1, `multiAreaEntries[1].deliveryStart: bad timestamp "2025-01-15 00:00"`},
+		{"missing_area.json", 1, "multiAreaEntries[2].entryPerArea: no price for area LV"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			path := filepath.Join("testdata", "validate", tt.file)
+			code := runValidate([]string{"-area", "LV", path}, &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (stderr %q)", code, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantOut) {
+				t.Errorf("output = %q, want it to contain %q", stdout.String(), tt.wantOut)
+			}
+		})
+	}
+}